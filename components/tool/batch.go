@@ -0,0 +1,97 @@
+/*
+ * Copyright 2026 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tool
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"runtime/debug"
+
+	"github.com/cloudwego/eino/internal/safe"
+	"github.com/cloudwego/eino/schema"
+)
+
+// StreamableRunBatch runs t with each of argumentsInJSON and returns the chunks of all calls in one stream,
+// each tagged with the index of its arguments, so that the caller can demux them.
+// If t implements BatchStreamableTool, its StreamableRunBatch is used.
+// Otherwise, the calls are run sequentially, and the chunks of a call are all emitted before the ones of the next call.
+// An error from a call is surfaced by Recv and ends the stream.
+// e.g.
+//
+//	sr, err := tool.StreamableRunBatch(ctx, t, []string{`{"q":"a"}`, `{"q":"b"}`})
+//	if err != nil {...}
+//	defer sr.Close()
+//	for {
+//		chunk, err := sr.Recv()
+//		if err == io.EOF {
+//			break
+//		}
+//		if err != nil {...}
+//		outputs[chunk.Index] += chunk.Chunk
+//	}
+func StreamableRunBatch(ctx context.Context, t StreamableTool, argumentsInJSON []string, opts ...Option) (*schema.StreamReader[IndexedChunk], error) {
+	if bt, ok := t.(BatchStreamableTool); ok {
+		return bt.StreamableRunBatch(ctx, argumentsInJSON, opts...)
+	}
+
+	sr, sw := schema.Pipe[IndexedChunk](0)
+
+	go func() {
+		var src *schema.StreamReader[string]
+		defer func() {
+			panicErr := recover()
+			if panicErr != nil {
+				_ = sw.Send(IndexedChunk{}, safe.NewPanicErr(panicErr, debug.Stack()))
+			}
+
+			if src != nil {
+				src.Close()
+			}
+			sw.Close()
+		}()
+
+		for i, arguments := range argumentsInJSON {
+			var err error
+			src, err = t.StreamableRun(ctx, arguments, opts...)
+			if err != nil {
+				_ = sw.Send(IndexedChunk{}, fmt.Errorf("batch call %d failed: %w", i, err))
+				return
+			}
+
+			for {
+				var chunk string
+				chunk, err = src.Recv()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					_ = sw.Send(IndexedChunk{}, fmt.Errorf("batch call %d failed: %w", i, err))
+					return
+				}
+				if sw.Send(IndexedChunk{Index: i, Chunk: chunk}, nil) {
+					return
+				}
+			}
+
+			src.Close()
+			src = nil
+		}
+	}()
+
+	return sr, nil
+}
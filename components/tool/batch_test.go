@@ -0,0 +1,95 @@
+/*
+ * Copyright 2026 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tool
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+type echoStreamTool struct{}
+
+func (e *echoStreamTool) Info(ctx context.Context) (*schema.ToolInfo, error) {
+	return &schema.ToolInfo{Name: "echo"}, nil
+}
+
+func (e *echoStreamTool) StreamableRun(ctx context.Context, argumentsInJSON string, opts ...Option) (*schema.StreamReader[string], error) {
+	if argumentsInJSON == "bad" {
+		return nil, errors.New("bad arguments")
+	}
+	return schema.StreamReaderFromArray(strings.Split(argumentsInJSON, "")), nil
+}
+
+type nativeBatchTool struct {
+	echoStreamTool
+}
+
+func (n *nativeBatchTool) StreamableRunBatch(ctx context.Context, argumentsInJSON []string, opts ...Option) (*schema.StreamReader[IndexedChunk], error) {
+	return schema.StreamReaderFromArray([]IndexedChunk{{Index: 1, Chunk: "native"}}), nil
+}
+
+func TestStreamableRunBatch(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("sequential fallback", func(t *testing.T) {
+		sr, err := StreamableRunBatch(ctx, &echoStreamTool{}, []string{"abc", "xy"})
+		assert.NoError(t, err)
+		defer sr.Close()
+
+		outputs := make([]string, 2)
+		for {
+			chunk, err := sr.Recv()
+			if err == io.EOF {
+				break
+			}
+			assert.NoError(t, err)
+			outputs[chunk.Index] += chunk.Chunk
+		}
+		assert.Equal(t, []string{"abc", "xy"}, outputs)
+	})
+
+	t.Run("error ends the stream", func(t *testing.T) {
+		sr, err := StreamableRunBatch(ctx, &echoStreamTool{}, []string{"a", "bad", "c"})
+		assert.NoError(t, err)
+		defer sr.Close()
+
+		chunk, err := sr.Recv()
+		assert.NoError(t, err)
+		assert.Equal(t, IndexedChunk{Index: 0, Chunk: "a"}, chunk)
+		_, err = sr.Recv()
+		assert.ErrorContains(t, err, "batch call 1 failed: bad arguments")
+		_, err = sr.Recv()
+		assert.Equal(t, io.EOF, err)
+	})
+
+	t.Run("native batch", func(t *testing.T) {
+		sr, err := StreamableRunBatch(ctx, &nativeBatchTool{}, []string{"a", "b"})
+		assert.NoError(t, err)
+		defer sr.Close()
+
+		chunk, err := sr.Recv()
+		assert.NoError(t, err)
+		assert.Equal(t, IndexedChunk{Index: 1, Chunk: "native"}, chunk)
+	})
+}
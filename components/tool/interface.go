@@ -56,3 +56,20 @@ type EnhancedStreamableTool interface {
 	BaseTool
 	StreamableRun(ctx context.Context, toolArgument *schema.ToolArgument, opts ...Option) (*schema.StreamReader[*schema.ToolResult], error)
 }
+
+// IndexedChunk is a chunk of the output stream of StreamableRunBatch,
+// tagged with the index of the arguments in the batch which produced it.
+type IndexedChunk struct {
+	Index int
+	Chunk string
+}
+
+// BatchStreamableTool is an optional interface for a StreamableTool which can run several calls natively,
+// e.g. when the model requests multiple calls to the same tool in one message.
+// The chunks of all calls are emitted in one stream, each tagged with the index of its arguments.
+// Use StreamableRunBatch to run a batch on any StreamableTool, which falls back to sequential calls for tools not implementing it.
+type BatchStreamableTool interface {
+	StreamableTool
+
+	StreamableRunBatch(ctx context.Context, argumentsInJSON []string, opts ...Option) (*schema.StreamReader[IndexedChunk], error)
+}
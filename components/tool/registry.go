@@ -0,0 +1,111 @@
+/*
+ * Copyright 2026 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tool
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+// Registry looks up tools by name, e.g. to execute the tool calls returned by a chat model.
+type Registry struct {
+	tools map[string]BaseTool
+}
+
+// NewRegistry creates a Registry of tools, keyed by the names returned by their Info.
+// It returns an error if the info of a tool fails, or two tools share the same name.
+func NewRegistry(ctx context.Context, tools ...BaseTool) (*Registry, error) {
+	r := &Registry{tools: make(map[string]BaseTool, len(tools))}
+	for i, t := range tools {
+		info, err := t.Info(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get info of tool %d: %w", i, err)
+		}
+		if info == nil {
+			return nil, fmt.Errorf("info of tool %d is nil", i)
+		}
+		if _, ok := r.tools[info.Name]; ok {
+			return nil, fmt.Errorf("duplicate tool name: %s", info.Name)
+		}
+		r.tools[info.Name] = t
+	}
+
+	return r, nil
+}
+
+// Get returns the tool of name, and whether it is registered.
+func (r *Registry) Get(name string) (BaseTool, bool) {
+	if r == nil {
+		return nil, false
+	}
+	t, ok := r.tools[name]
+	return t, ok
+}
+
+// ToolRequest is a tool call resolved to the tool to execute.
+type ToolRequest struct {
+	// Tool is the registered tool named by the call.
+	Tool BaseTool
+	// Name is the name of the tool.
+	Name string
+	// ID is the id of the tool call, to be set as the ToolCallID of the tool message.
+	ID string
+	// Arguments is the raw arguments of the tool call in JSON.
+	Arguments string
+}
+
+// ToToolRequests resolves the tool calls of the assistant message m against reg, in the order of the calls,
+// which is the seam between the model output and the execution of tools.
+// It returns an error naming all the tools that are not registered.
+// e.g.
+//
+//	reqs, err := tool.ToToolRequests(msg, reg)
+//	if err != nil {...}
+//	for _, req := range reqs {
+//		out, err := req.Tool.(tool.InvokableTool).InvokableRun(ctx, req.Arguments)
+//		...
+//	}
+func ToToolRequests(m *schema.Message, reg *Registry) ([]ToolRequest, error) {
+	if m == nil || len(m.ToolCalls) == 0 {
+		return nil, nil
+	}
+
+	reqs := make([]ToolRequest, 0, len(m.ToolCalls))
+	var missing []string
+	for _, tc := range m.ToolCalls {
+		t, ok := reg.Get(tc.Function.Name)
+		if !ok {
+			missing = append(missing, tc.Function.Name)
+			continue
+		}
+		reqs = append(reqs, ToolRequest{
+			Tool:      t,
+			Name:      tc.Function.Name,
+			ID:        tc.ID,
+			Arguments: tc.Function.Arguments,
+		})
+	}
+
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("tools not registered: %s", strings.Join(missing, ", "))
+	}
+
+	return reqs, nil
+}
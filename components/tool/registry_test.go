@@ -0,0 +1,65 @@
+/*
+ * Copyright 2026 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tool
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+type namedTool struct {
+	name string
+}
+
+func (n *namedTool) Info(ctx context.Context) (*schema.ToolInfo, error) {
+	return &schema.ToolInfo{Name: n.name}, nil
+}
+
+func TestToToolRequests(t *testing.T) {
+	ctx := context.Background()
+	weather, search := &namedTool{name: "get_weather"}, &namedTool{name: "search"}
+
+	reg, err := NewRegistry(ctx, weather, search)
+	assert.NoError(t, err)
+
+	msg := schema.AssistantMessage("", []schema.ToolCall{
+		{ID: "call_1", Function: schema.FunctionCall{Name: "get_weather", Arguments: `{"city":"Paris"}`}},
+		{ID: "call_2", Function: schema.FunctionCall{Name: "search", Arguments: `{"q":"eino"}`}},
+	})
+	reqs, err := ToToolRequests(msg, reg)
+	assert.NoError(t, err)
+	assert.Equal(t, []ToolRequest{
+		{Tool: weather, Name: "get_weather", ID: "call_1", Arguments: `{"city":"Paris"}`},
+		{Tool: search, Name: "search", ID: "call_2", Arguments: `{"q":"eino"}`},
+	}, reqs)
+
+	reg, err = NewRegistry(ctx, weather)
+	assert.NoError(t, err)
+	_, err = ToToolRequests(msg, reg)
+	assert.EqualError(t, err, "tools not registered: search")
+
+	reqs, err = ToToolRequests(schema.AssistantMessage("done", nil), reg)
+	assert.NoError(t, err)
+	assert.Empty(t, reqs)
+
+	_, err = NewRegistry(ctx, weather, &namedTool{name: "get_weather"})
+	assert.ErrorContains(t, err, "duplicate tool name")
+}
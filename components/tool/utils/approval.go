@@ -0,0 +1,51 @@
+/*
+ * Copyright 2026 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package utils
+
+import (
+	"context"
+	"fmt"
+)
+
+// ApprovalFunc decides whether a tool call can be executed, e.g. by asking a human for confirmation.
+type ApprovalFunc func(ctx context.Context, toolName, arguments string) (approved bool, err error)
+
+// ApprovalDeniedError is returned by a tool created with WithRequireApproval when the approver denies the call.
+type ApprovalDeniedError struct {
+	ToolName  string
+	Arguments string
+}
+
+func (e *ApprovalDeniedError) Error() string {
+	return fmt.Sprintf("tool call is denied by approver, toolName=%s", e.ToolName)
+}
+
+func checkApproval(ctx context.Context, approver ApprovalFunc, toolName, arguments string) error {
+	if approver == nil {
+		return nil
+	}
+
+	approved, err := approver(ctx, toolName, arguments)
+	if err != nil {
+		return fmt.Errorf("failed to get approval, toolName=%s, err=%w", toolName, err)
+	}
+	if !approved {
+		return &ApprovalDeniedError{ToolName: toolName, Arguments: arguments}
+	}
+
+	return nil
+}
@@ -0,0 +1,173 @@
+/*
+ * Copyright 2026 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package utils
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/bytedance/sonic"
+
+	"github.com/cloudwego/eino/internal/generic"
+)
+
+// getUnmarshalArguments returns the UnmarshalArguments of the tool with argument type T,
+// which is the one set by WithUnmarshalArguments, or the default one customized by WithArgumentCoercion and WithJSONUseNumber.
+// It returns nil if none of them is set, for which the tool unmarshals by sonic directly.
+func getUnmarshalArguments[T any](to *toolOptions) UnmarshalArguments {
+	if to.um != nil || (!to.argumentCoercion && !to.jsonUseNumber) {
+		return to.um
+	}
+
+	api := sonic.ConfigDefault
+	if to.jsonUseNumber {
+		api = sonic.Config{UseNumber: true}.Froze()
+	}
+
+	typ := generic.TypeOf[T]()
+	coerce := to.argumentCoercion
+	return func(ctx context.Context, arguments string) (any, error) {
+		if coerce {
+			var err error
+			arguments, err = coerceArguments(arguments, typ)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		inst := generic.NewInstance[T]()
+		if err := api.UnmarshalFromString(arguments, &inst); err != nil {
+			return nil, err
+		}
+		return inst, nil
+	}
+}
+
+// coerceArguments converts the obviously mismatched values in the JSON arguments to the types of typ,
+// i.e. strings of numbers to numbers and "true" or "false" to booleans.
+func coerceArguments(arguments string, typ reflect.Type) (string, error) {
+	var v any
+	dec := json.NewDecoder(strings.NewReader(arguments))
+	dec.UseNumber()
+	if err := dec.Decode(&v); err != nil {
+		return "", fmt.Errorf("arguments are not valid json: %w", err)
+	}
+
+	buf := &bytes.Buffer{}
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(coerceValue(v, typ)); err != nil {
+		return "", err
+	}
+
+	return strings.TrimSuffix(buf.String(), "\n"), nil
+}
+
+func coerceValue(v any, typ reflect.Type) any {
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+
+	switch typ.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if s, ok := v.(string); ok {
+			if _, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64); err == nil {
+				return json.Number(strings.TrimSpace(s))
+			}
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if s, ok := v.(string); ok {
+			if _, err := strconv.ParseUint(strings.TrimSpace(s), 10, 64); err == nil {
+				return json.Number(strings.TrimSpace(s))
+			}
+		}
+	case reflect.Float32, reflect.Float64:
+		if s, ok := v.(string); ok {
+			// json.Number must be a valid json number, which rejects e.g. "NaN" or "1e"
+			if n := json.Number(strings.TrimSpace(s)); json.Valid([]byte(n)) {
+				if _, err := n.Float64(); err == nil {
+					return n
+				}
+			}
+		}
+	case reflect.Bool:
+		if s, ok := v.(string); ok {
+			switch strings.ToLower(strings.TrimSpace(s)) {
+			case "true":
+				return true
+			case "false":
+				return false
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		if arr, ok := v.([]any); ok {
+			for i := range arr {
+				arr[i] = coerceValue(arr[i], typ.Elem())
+			}
+		}
+	case reflect.Map:
+		if obj, ok := v.(map[string]any); ok {
+			for k := range obj {
+				obj[k] = coerceValue(obj[k], typ.Elem())
+			}
+		}
+	case reflect.Struct:
+		if obj, ok := v.(map[string]any); ok {
+			coerceStructFields(obj, typ)
+		}
+	}
+
+	return v
+}
+
+func coerceStructFields(obj map[string]any, typ reflect.Type) {
+	fields := make(map[string]reflect.Type)
+	for _, f := range reflect.VisibleFields(typ) {
+		if !f.IsExported() {
+			continue
+		}
+		name := strings.Split(f.Tag.Get("json"), ",")[0]
+		if name == "-" || (name == "" && f.Anonymous) {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		fields[name] = f.Type
+	}
+
+	for k, v := range obj {
+		ft, ok := fields[k]
+		if !ok {
+			// json keys are matched to fields case-insensitively
+			for name, t := range fields {
+				if strings.EqualFold(name, k) {
+					ft, ok = t, true
+					break
+				}
+			}
+		}
+		if ok {
+			obj[k] = coerceValue(v, ft)
+		}
+	}
+}
@@ -17,6 +17,8 @@
 package utils
 
 import (
+	"context"
+
 	"github.com/bytedance/sonic"
 )
 
@@ -26,3 +28,28 @@ func marshalString(resp any) (string, error) {
 	}
 	return sonic.MarshalString(resp)
 }
+
+// baseMarshal returns m, or the default marshalling if m is nil, for the wrappers post-processing the marshalled output.
+func baseMarshal(m MarshalOutput) MarshalOutput {
+	if m != nil {
+		return m
+	}
+	return func(_ context.Context, output any) (string, error) {
+		return marshalString(output)
+	}
+}
+
+func newMarshalOutput(mo MarshalOptions) MarshalOutput {
+	api := sonic.Config{
+		EscapeHTML:       mo.EscapeHTML,
+		SortMapKeys:      mo.SortMapKeys,
+		CompactMarshaler: mo.CompactMarshaler,
+	}.Froze()
+
+	return func(_ context.Context, output any) (string, error) {
+		if rs, ok := output.(string); ok {
+			return rs, nil
+		}
+		return api.MarshalToString(output)
+	}
+}
@@ -18,9 +18,14 @@ package utils
 
 import (
 	"context"
+	"fmt"
 	"reflect"
+	"time"
 
 	"github.com/eino-contrib/jsonschema"
+
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/cloudwego/eino/schema"
 )
 
 // UnmarshalArguments is the function type for unmarshalling the arguments.
@@ -33,6 +38,53 @@ type toolOptions struct {
 	um         UnmarshalArguments
 	m          MarshalOutput
 	scModifier SchemaModifierFn
+	examples   []any
+	checksum   bool
+
+	jsonObjectFraming bool
+
+	fieldDescriptions map[string]string
+
+	pointerFieldsOptional bool
+
+	strictSchema bool
+
+	// whether to coerce the arguments before unmarshalling, only used if um is nil
+	argumentCoercion bool
+	// whether to decode numbers in the arguments as json.Number, only used if um is nil
+	jsonUseNumber bool
+
+	maxOutputTokens int
+	tokenCounter    schema.TokenCounter
+
+	approver ApprovalFunc
+
+	outputPath *jsonPath
+
+	// optionErr is the error of an invalid option, which makes InferTool and its variants return it,
+	// and the tools created by NewTool and NewStreamTool fail on run.
+	optionErr error
+
+	outputKeyRemap map[string]string
+
+	outputSchema *jsonschema.Schema
+
+	errorFormatter    func(error) *schema.ToolResult
+	argErrorFormatter func(err error, rawArgs string) *schema.ToolResult
+
+	resultMeta bool
+
+	contentType      OutputContentType
+	markdownRenderer MarshalOutput
+
+	marshalOptions *MarshalOptions
+
+	cache    Cache
+	cacheTTL time.Duration
+
+	tracer Tracer
+
+	limiter *ConcurrencyLimiter
 }
 
 // Option is the option func for the tool.
@@ -54,6 +106,26 @@ func WithMarshalOutput(m MarshalOutput) Option {
 	}
 }
 
+// WithArgumentCoercion sets whether to coerce the obviously mismatched values in the arguments generated by the model
+// to the types of the fields of the argument struct before unmarshalling, i.e. numbers in strings like "42" to numbers
+// and "true" or "false" to booleans, which reduces the failed tool calls of smaller models.
+// It has no effect if WithUnmarshalArguments is set.
+func WithArgumentCoercion(coerce bool) Option {
+	return func(o *toolOptions) {
+		o.argumentCoercion = coerce
+	}
+}
+
+// WithJSONUseNumber sets whether to decode the numbers in the arguments as json.Number instead of float64,
+// when the target is an interface, e.g. a field of type any or map[string]any,
+// so that large integers like 19-digit ids are kept without precision loss.
+// It has no effect if WithUnmarshalArguments is set.
+func WithJSONUseNumber(useNumber bool) Option {
+	return func(o *toolOptions) {
+		o.jsonUseNumber = useNumber
+	}
+}
+
 // SchemaModifierFn is the schema modifier function for inferring tool parameter from tagged go struct.
 // Within this function, end-user can parse custom go struct tags into corresponding json schema field.
 // Parameters:
@@ -70,6 +142,231 @@ func WithSchemaModifier(modifier SchemaModifierFn) Option {
 	}
 }
 
+// WithSchemaExamples attaches few-shot examples of the tool arguments to the root of the inferred json schema,
+// as the 'examples' keyword, which helps the model to call the tool correctly.
+// Each example is marshalled into JSON, so it can be a go struct of the argument type, or a map.
+func WithSchemaExamples(examples ...any) Option {
+	return func(o *toolOptions) {
+		o.examples = append(o.examples, examples...)
+	}
+}
+
+// WithFieldDescriptionsFromComments sets the descriptions of the properties of the inferred json schema from an external map,
+// e.g. generated from the go doc comments of the argument struct by go:generate, instead of duplicating them in struct tags.
+// The keys are the dotted paths of the json property names, e.g. "address.city", where the items of an array property are
+// addressed by the path of the array itself, e.g. "items.title" for the field "title" of the elements of "items".
+// The descriptions override the ones from the struct tags, and a path matching no property makes the inference fail.
+func WithFieldDescriptionsFromComments(descriptions map[string]string) Option {
+	return func(o *toolOptions) {
+		o.fieldDescriptions = descriptions
+	}
+}
+
+// WithPointerFieldsOptional sets whether the struct fields of pointer type are always excluded from the 'required' list
+// of the inferred json schema, matching the nil semantics of go pointers.
+// By default, pointer fields are treated like the other fields: required unless the json tag has 'omitempty',
+// or as set by the 'required' keyword of the jsonschema tag.
+func WithPointerFieldsOptional(optional bool) Option {
+	return func(o *toolOptions) {
+		o.pointerFieldsOptional = optional
+	}
+}
+
+// WithStrictSchema sets whether the inferred json schema follows the strict mode of function calling, e.g. of OpenAI,
+// where every object has 'additionalProperties: false' and lists all of its properties in 'required'.
+// The properties which would otherwise be optional are made nullable instead, i.e. 'null' is added to their types,
+// so that the model can still leave them out by passing null.
+// It's applied after the other schema options, e.g. WithPointerFieldsOptional and WithSchemaModifier.
+func WithStrictSchema(strict bool) Option {
+	return func(o *toolOptions) {
+		o.strictSchema = strict
+	}
+}
+
+// WithOutputChecksum makes a streamable tool created by NewStreamTool or InferStreamTool append a terminal frame
+// carrying the SHA-256 checksum of all preceding output frames, see OutputChecksumFramePrefix.
+// After draining the stream, the consumer can use SplitOutputChecksum on the concatenated output to verify that nothing was dropped.
+func WithOutputChecksum() Option {
+	return func(o *toolOptions) {
+		o.checksum = true
+	}
+}
+
+// WithJSONObjectFraming makes a streamable tool created by NewStreamTool or InferStreamTool buffer its marshalled output
+// and emit one frame per complete top-level JSON object or array, instead of one frame per chunk of the tool function,
+// e.g. when the tool function streams raw bytes in which the objects span chunk boundaries, or several objects share a chunk.
+// The whitespace between the values is dropped, while any other character outside of an object or array, or a value left
+// incomplete at the end of the stream, fails the stream with an error.
+// The framing is applied before WithMaxOutputTokens and WithOutputChecksum.
+func WithJSONObjectFraming() Option {
+	return func(o *toolOptions) {
+		o.jsonObjectFraming = true
+	}
+}
+
+// WithOutputJSONPath projects the marshalled output of a tool created by NewTool, NewStreamTool or their Infer variants
+// through a JSONPath expression, so that only the needed subset is passed back to the model.
+// For streamable tools, the expression is applied to each output frame.
+// The supported syntax is root '$', child '.name' or ['name'], array index '[0]' and wildcard '.*' or '[*]',
+// where a path with wildcard returns the array of all matches, e.g. "$.items[*].title".
+// An invalid expression makes InferTool and its variants return an error, while the tools created by NewTool and
+// NewStreamTool fail on run, so prefer the Infer variants to catch it on construction.
+func WithOutputJSONPath(expr string) Option {
+	return func(o *toolOptions) {
+		o.outputPath, o.optionErr = compileJSONPath(expr)
+	}
+}
+
+// WithMaxOutputTokens caps the output of a streamable tool created by NewStreamTool or InferStreamTool by token count.
+// Each output frame is counted by counter as the content of a tool message, and once the next frame would make the total
+// exceed n, the stream stops with a terminal frame of OutputTruncatedMarker, and the rest of the output is discarded.
+// If used together with WithOutputChecksum, the checksum covers the capped output including the marker.
+func WithMaxOutputTokens(counter schema.TokenCounter, n int) Option {
+	return func(o *toolOptions) {
+		o.tokenCounter = counter
+		o.maxOutputTokens = n
+	}
+}
+
+// WithRequireApproval makes the tool invoke approver with the tool name and the arguments before executing the tool function,
+// which is useful for tools with side effects, e.g. sending emails or executing code, that need human confirmation.
+// If the approver denies the call, the tool function is not called and an *ApprovalDeniedError is returned.
+func WithRequireApproval(approver ApprovalFunc) Option {
+	return func(o *toolOptions) {
+		o.approver = approver
+	}
+}
+
+// WithOutputKeyRemap renames the top-level keys of the JSON object output of a tool created by NewTool, NewStreamTool
+// or their Infer variants, e.g. {"usr_nm": "name"}, so that the keys seen by the model are decoupled from the go struct.
+// The key order is kept, and an output which is not a JSON object is left as is.
+// For streamable tools, the remap is applied to each output frame.
+// If used together with WithOutputJSONPath, the keys of the projected output are renamed.
+func WithOutputKeyRemap(remap map[string]string) Option {
+	return func(o *toolOptions) {
+		o.outputKeyRemap = remap
+	}
+}
+
+// WithOutputSchema validates the marshalled output of a tool created by NewTool, NewStreamTool or their Infer variants
+// against sc before it is passed back to the model, which catches tool bugs producing malformed output.
+// A mismatch makes the tool return an *OutputSchemaError, and for streamable tools, each output frame is validated.
+// The output is validated after WithOutputJSONPath and WithOutputKeyRemap are applied.
+// Only a subset of JSON Schema is checked: type, enum, const, the numeric, string, array and object constraints,
+// allOf, anyOf, oneOf, not and $ref to the local $defs, while other keywords such as format are ignored.
+// As the output must be JSON, it can't be used with the text or markdown content type set by WithOutputContentType,
+// which makes InferTool and its variants return an error, while the tools created by NewTool and NewStreamTool fail on run.
+func WithOutputSchema(sc *jsonschema.Schema) Option {
+	return func(o *toolOptions) {
+		o.outputSchema = sc
+	}
+}
+
+// WithCache makes a tool created by NewTool, InferTool or their optionable variants return the cached output
+// when called again with the same arguments, which cuts the redundant calls of idempotent tools, e.g. lookups, in agent loops.
+// The cache key is built from the tool name and the hash of the arguments JSON, ignoring the whitespace and the key order,
+// while the call options are not part of the key. Only the successful outputs are cached, and they expire after ttl, or never if ttl is 0.
+// The approval set by WithRequireApproval is still checked before the cache is looked up.
+func WithCache(cache Cache, ttl time.Duration) Option {
+	return func(o *toolOptions) {
+		o.cache = cache
+		o.cacheTTL = ttl
+	}
+}
+
+// WithTracing makes a tool created by any of the New* or Infer* functions run each invocation in a span
+// started by tracer and named after the tool, with the attributes of the tool name, the size of the arguments and
+// the size of the output, see SpanAttrToolName. The failed invocations record the error on the span.
+// The output size of an enhanced tool is the size of the text in its parts, and a streamable tool
+// keeps the span open until its output stream ends or is closed.
+// The context passed to the tool function carries the span, so that the spans started inside are nested under it.
+// The module components/tool/utils/oteltool provides the Tracer backed by OpenTelemetry, see oteltool.WithTracing.
+func WithTracing(tracer Tracer) Option {
+	return func(o *toolOptions) {
+		o.tracer = tracer
+	}
+}
+
+// WithConcurrencyLimit makes a tool created by NewTool, NewEnhancedTool, InferTool, InferEnhancedTool or their optionable variants
+// acquire a slot of limiter before running the tool function and release it once the tool returns,
+// so that concurrent tool calls, e.g. fanned out by an agent, don't overwhelm the downstream services.
+// Sharing a limiter among tools bounds their total concurrency. If ctx is done while waiting for a slot, the tool returns the error of ctx.
+func WithConcurrencyLimit(limiter *ConcurrencyLimiter) Option {
+	return func(o *toolOptions) {
+		o.limiter = limiter
+	}
+}
+
+// WithErrorAsResult makes an enhanced tool created by NewEnhancedTool, NewEnhancedStreamTool or their variants
+// return the ToolResult built by formatter with nil error when the tool function fails, e.g. a text part describing the error,
+// so that the model can see the failure and recover instead of aborting the agent.
+// For enhanced streamable tools, the ToolResult is returned as a single-item stream.
+// Errors from unmarshalling the arguments or from WithRequireApproval, as well as interrupt errors, are still returned as is.
+func WithErrorAsResult(formatter func(error) *schema.ToolResult) Option {
+	return func(o *toolOptions) {
+		o.errorFormatter = formatter
+	}
+}
+
+// WithArgErrorAsResult makes an enhanced tool created by NewEnhancedTool, NewEnhancedStreamTool or their variants
+// return the ToolResult built by formatter with nil error when unmarshalling the arguments fails, e.g. on malformed JSON,
+// so that the model is told its arguments were invalid and can retry, instead of aborting the agent.
+// formatter receives the unmarshal error and the raw arguments. For enhanced streamable tools, the ToolResult is returned as a single-item stream.
+// By default, the unmarshal error is returned as is.
+func WithArgErrorAsResult(formatter func(err error, rawArgs string) *schema.ToolResult) Option {
+	return func(o *toolOptions) {
+		o.argErrorFormatter = formatter
+	}
+}
+
+// WithResultMeta makes an enhanced streamable tool created by NewEnhancedStreamTool or its variants append a chunk
+// carrying only the execution status in ToolResult.Meta when its output stream ends successfully,
+// where the duration covers the call and the whole stream. If the stream fails, the error is forwarded as is.
+// By default, the output stream is returned untouched, while the enhanced invokable tools always set the status.
+func WithResultMeta() Option {
+	return func(o *toolOptions) {
+		o.resultMeta = true
+	}
+}
+
+// WithOutputContentType sets how the output of a tool created by NewTool, NewStreamTool or their Infer variants is rendered,
+// so that the same tool can serve models preferring different styles.
+// The default is OutputContentTypeJSON, see OutputContentType for the others.
+// It has no effect if WithMarshalOutput is set.
+// WithOutputJSONPath and WithOutputKeyRemap expect JSON output, so they should not be used with other content types.
+func WithOutputContentType(ct OutputContentType) Option {
+	return func(o *toolOptions) {
+		o.contentType = ct
+	}
+}
+
+// MarshalOptions controls the JSON encoding of the tool output, mapping to the flags of sonic.Config.
+// The zero value matches the default encoding.
+type MarshalOptions struct {
+	// EscapeHTML escapes '<', '>' and '&' in strings as \u003c, \u003e and \u0026.
+	EscapeHTML bool
+	// SortMapKeys sorts the keys of maps, so that the output is deterministic.
+	SortMapKeys bool
+	// CompactMarshaler compacts the output of the types implementing json.Marshaler.
+	CompactMarshaler bool
+}
+
+// WithMarshalOptions sets the JSON encoding flags for the output of a tool created by NewTool, NewStreamTool or their Infer variants,
+// which controls the exact JSON sent to the model without writing a full MarshalOutput.
+// It has no effect if WithMarshalOutput is set, or if the content type set by WithOutputContentType is not JSON.
+func WithMarshalOptions(mo MarshalOptions) Option {
+	return func(o *toolOptions) {
+		o.marshalOptions = &mo
+	}
+}
+
+// WithMarkdownRenderer sets the renderer used for OutputContentTypeMarkdown, see WithOutputContentType.
+func WithMarkdownRenderer(renderer MarshalOutput) Option {
+	return func(o *toolOptions) {
+		o.markdownRenderer = renderer
+	}
+}
+
 func getToolOptions(opt ...Option) *toolOptions {
 	opts := &toolOptions{
 		um: nil,
@@ -78,5 +375,88 @@ func getToolOptions(opt ...Option) *toolOptions {
 	for _, o := range opt {
 		o(opts)
 	}
+
+	if opts.optionErr == nil && opts.outputSchema != nil && opts.m == nil &&
+		opts.contentType != "" && opts.contentType != OutputContentTypeJSON {
+		opts.optionErr = fmt.Errorf("output schema requires json output, but the output content type is %s", opts.contentType)
+	}
+
+	if opts.optionErr != nil {
+		err := opts.optionErr
+		opts.m = func(ctx context.Context, output any) (string, error) {
+			return "", err
+		}
+		return opts
+	}
+
+	if opts.m == nil && opts.contentType != "" {
+		opts.m = getContentTypeMarshaller(opts.contentType, opts.markdownRenderer)
+	}
+
+	if opts.m == nil && opts.marshalOptions != nil {
+		opts.m = newMarshalOutput(*opts.marshalOptions)
+	}
+
+	if opts.outputPath != nil {
+		opts.m = withOutputJSONPath(opts.m, opts.outputPath)
+	}
+
+	if len(opts.outputKeyRemap) > 0 {
+		opts.m = withOutputKeyRemap(opts.m, opts.outputKeyRemap)
+	}
+
+	if opts.outputSchema != nil {
+		opts.m = withOutputSchema(opts.m, opts.outputSchema)
+	}
+
 	return opts
 }
+
+type marshalCallOptions struct {
+	ctxValues map[any]any
+}
+
+// WithMarshalContextValue returns a tool call option that attaches a key-value pair to the context
+// passed to UnmarshalArguments and MarshalOutput, e.g. the target locale for number formatting.
+// The value can be read back inside these functions by GetMarshalContextValue.
+func WithMarshalContextValue(key, value any) tool.Option {
+	return tool.WrapImplSpecificOptFn(func(o *marshalCallOptions) {
+		if o.ctxValues == nil {
+			o.ctxValues = make(map[any]any)
+		}
+		o.ctxValues[key] = value
+	})
+}
+
+type marshalCtxValuesKey struct{}
+
+// GetMarshalContextValue gets the value set by WithMarshalContextValue from the context
+// passed to UnmarshalArguments and MarshalOutput.
+func GetMarshalContextValue(ctx context.Context, key any) (any, bool) {
+	values, ok := ctx.Value(marshalCtxValuesKey{}).(map[any]any)
+	if !ok {
+		return nil, false
+	}
+
+	v, ok := values[key]
+	return v, ok
+}
+
+func withMarshalContext(ctx context.Context, opts ...tool.Option) context.Context {
+	o := tool.GetImplSpecificOptions(&marshalCallOptions{}, opts...)
+	if len(o.ctxValues) == 0 {
+		return ctx
+	}
+
+	values := make(map[any]any, len(o.ctxValues))
+	if parent, ok := ctx.Value(marshalCtxValuesKey{}).(map[any]any); ok {
+		for k, v := range parent {
+			values[k] = v
+		}
+	}
+	for k, v := range o.ctxValues {
+		values[k] = v
+	}
+
+	return context.WithValue(ctx, marshalCtxValuesKey{}, values)
+}
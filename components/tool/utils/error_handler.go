@@ -164,3 +164,20 @@ func (s *streamErrorHelper) StreamableRun(ctx context.Context, argumentsInJSON s
 	}
 	return result, nil
 }
+
+func formatArgErrorAsResult(formatter func(error, string) *schema.ToolResult, err error, rawArgs string) (*schema.ToolResult, bool) {
+	if formatter == nil {
+		return nil, false
+	}
+	return withToolResultMeta(formatter(err, rawArgs), false, 0), true
+}
+
+func formatErrorAsResult(formatter func(error) *schema.ToolResult, err error) (*schema.ToolResult, bool) {
+	if formatter == nil {
+		return nil, false
+	}
+	if _, ok := compose.IsInterruptRerunError(err); ok {
+		return nil, false
+	}
+	return formatter(err), true
+}
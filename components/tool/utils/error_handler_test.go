@@ -25,6 +25,7 @@ import (
 	"github.com/stretchr/testify/assert"
 
 	"github.com/cloudwego/eino/components/tool"
+	"github.com/cloudwego/eino/compose"
 	"github.com/cloudwego/eino/schema"
 )
 
@@ -79,3 +80,101 @@ func TestErrorWrapper(t *testing.T) {
 	_, err = streamResult.Recv()
 	assert.True(t, errors.Is(err, io.EOF))
 }
+
+func TestWithErrorAsResult(t *testing.T) {
+	type input struct {
+		Query string `json:"query"`
+	}
+	info := &schema.ToolInfo{Name: "search"}
+	formatter := func(err error) *schema.ToolResult {
+		return &schema.ToolResult{Parts: []schema.ToolOutputPart{{Type: schema.ToolPartTypeText, Text: "error: " + err.Error()}}}
+	}
+	arg := &schema.ToolArgument{Text: `{"query":"eino"}`}
+
+	t.Run("invokable", func(t *testing.T) {
+		fn := func(ctx context.Context, in input) (*schema.ToolResult, error) {
+			return nil, errors.New("quota exceeded")
+		}
+
+		_, err := NewEnhancedTool(info, fn).InvokableRun(context.Background(), arg)
+		assert.ErrorContains(t, err, "quota exceeded")
+
+		result, err := NewEnhancedTool(info, fn, WithErrorAsResult(formatter)).InvokableRun(context.Background(), arg)
+		assert.NoError(t, err)
+		assert.Equal(t, "error: quota exceeded", result.Parts[0].Text)
+
+		_, err = NewEnhancedTool(info, fn, WithErrorAsResult(formatter)).InvokableRun(context.Background(), &schema.ToolArgument{Text: "{"})
+		assert.Error(t, err)
+
+		interruptFn := func(ctx context.Context, in input) (*schema.ToolResult, error) {
+			return nil, compose.InterruptAndRerun
+		}
+		_, err = NewEnhancedTool(info, interruptFn, WithErrorAsResult(formatter)).InvokableRun(context.Background(), arg)
+		_, ok := compose.IsInterruptRerunError(err)
+		assert.True(t, ok)
+	})
+
+	t.Run("streamable", func(t *testing.T) {
+		fn := func(ctx context.Context, in input) (*schema.StreamReader[*schema.ToolResult], error) {
+			return nil, errors.New("quota exceeded")
+		}
+
+		_, err := NewEnhancedStreamTool(info, fn).StreamableRun(context.Background(), arg)
+		assert.ErrorContains(t, err, "quota exceeded")
+
+		sr, err := NewEnhancedStreamTool(info, fn, WithErrorAsResult(formatter)).StreamableRun(context.Background(), arg)
+		assert.NoError(t, err)
+		defer sr.Close()
+		result, err := sr.Recv()
+		assert.NoError(t, err)
+		assert.Equal(t, "error: quota exceeded", result.Parts[0].Text)
+		_, err = sr.Recv()
+		assert.Equal(t, io.EOF, err)
+	})
+}
+
+func TestWithArgErrorAsResult(t *testing.T) {
+	type input struct {
+		Query string `json:"query"`
+	}
+	info := &schema.ToolInfo{Name: "search"}
+	formatter := func(err error, rawArgs string) *schema.ToolResult {
+		return &schema.ToolResult{Parts: []schema.ToolOutputPart{{Type: schema.ToolPartTypeText, Text: "invalid arguments " + rawArgs + ", please retry"}}}
+	}
+	badArg := &schema.ToolArgument{Text: `{"query":`}
+
+	t.Run("invokable", func(t *testing.T) {
+		called := false
+		fn := func(ctx context.Context, in input) (*schema.ToolResult, error) {
+			called = true
+			return &schema.ToolResult{}, nil
+		}
+
+		_, err := NewEnhancedTool(info, fn).InvokableRun(context.Background(), badArg)
+		assert.Error(t, err)
+
+		result, err := NewEnhancedTool(info, fn, WithArgErrorAsResult(formatter)).InvokableRun(context.Background(), badArg)
+		assert.NoError(t, err)
+		assert.Equal(t, `invalid arguments {"query":, please retry`, result.Parts[0].Text)
+		assert.False(t, result.Meta.Success)
+		assert.False(t, called)
+	})
+
+	t.Run("streamable", func(t *testing.T) {
+		fn := func(ctx context.Context, in input) (*schema.StreamReader[*schema.ToolResult], error) {
+			return schema.StreamReaderFromArray([]*schema.ToolResult{{}}), nil
+		}
+
+		_, err := NewEnhancedStreamTool(info, fn).StreamableRun(context.Background(), badArg)
+		assert.Error(t, err)
+
+		sr, err := NewEnhancedStreamTool(info, fn, WithArgErrorAsResult(formatter)).StreamableRun(context.Background(), badArg)
+		assert.NoError(t, err)
+		defer sr.Close()
+		result, err := sr.Recv()
+		assert.NoError(t, err)
+		assert.Equal(t, `invalid arguments {"query":, please retry`, result.Parts[0].Text)
+		_, err = sr.Recv()
+		assert.Equal(t, io.EOF, err)
+	})
+}
@@ -19,7 +19,9 @@ package utils
 import (
 	"context"
 	"fmt"
+	"reflect"
 	"strings"
+	"time"
 
 	"github.com/bytedance/sonic"
 	"github.com/eino-contrib/jsonschema"
@@ -95,6 +97,53 @@ func GoStruct2ToolInfo[T any](toolName, toolDesc string, opts ...Option) (*schem
 	return goStruct2ToolInfo[T](toolName, toolDesc, opts...)
 }
 
+// InferToolInfoFromFunc converts the input type of a function to a ToolInfo by reflection,
+// which suits dynamic tool registration where the input type is not available as a generic type parameter.
+// The input type is the first parameter of fn which is not context.Context, and must be a struct or a pointer to struct,
+// e.g. func(ctx context.Context, input *Input) (string, error) or func(input Input, opts ...tool.Option) (*Output, error).
+func InferToolInfoFromFunc(toolName, toolDesc string, fn any, opts ...Option) (*schema.ToolInfo, error) {
+	ft := reflect.TypeOf(fn)
+	if ft == nil || ft.Kind() != reflect.Func {
+		return nil, fmt.Errorf("expected a function, given=%T", fn)
+	}
+
+	var inputType reflect.Type
+	for i := 0; i < ft.NumIn(); i++ {
+		if ft.IsVariadic() && i == ft.NumIn()-1 {
+			break
+		}
+		if in := ft.In(i); in != ctxType {
+			inputType = in
+			break
+		}
+	}
+	if inputType == nil {
+		return nil, fmt.Errorf("function %s has no input parameter besides context", ft)
+	}
+
+	var input any
+	switch {
+	case inputType.Kind() == reflect.Struct:
+		input = reflect.New(inputType).Elem().Interface()
+	case inputType.Kind() == reflect.Ptr && inputType.Elem().Kind() == reflect.Struct:
+		input = reflect.New(inputType.Elem()).Interface()
+	default:
+		return nil, fmt.Errorf("input parameter of function %s must be a struct or a pointer to struct, given=%s", ft, inputType)
+	}
+
+	paramsOneOf, err := goValue2ParamsOneOf(input, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &schema.ToolInfo{
+		Name:        toolName,
+		Desc:        toolDesc,
+		ParamsOneOf: paramsOneOf,
+	}, nil
+}
+
+var ctxType = reflect.TypeOf((*context.Context)(nil)).Elem()
+
 func goStruct2ToolInfo[T any](toolName, toolDesc string, opts ...Option) (*schema.ToolInfo, error) {
 	paramsOneOf, err := goStruct2ParamsOneOf[T](opts...)
 	if err != nil {
@@ -108,23 +157,219 @@ func goStruct2ToolInfo[T any](toolName, toolDesc string, opts ...Option) (*schem
 }
 
 func goStruct2ParamsOneOf[T any](opts ...Option) (*schema.ParamsOneOf, error) {
+	return goValue2ParamsOneOf(generic.NewInstance[T](), opts...)
+}
+
+func goValue2ParamsOneOf(v any, opts ...Option) (*schema.ParamsOneOf, error) {
 	options := getToolOptions(opts...)
+	if options.optionErr != nil {
+		return nil, options.optionErr
+	}
+
+	modifier := options.scModifier
+	if options.pointerFieldsOptional {
+		userModifier := modifier
+		modifier = func(jsonTagName string, t reflect.Type, tag reflect.StructTag, sc *jsonschema.Schema) {
+			removePointerFieldsFromRequired(t, sc)
+			if userModifier != nil {
+				userModifier(jsonTagName, t, tag, sc)
+			}
+		}
+	}
+	if len(options.examples) > 0 {
+		examples, err := marshalSchemaExamples(options.examples)
+		if err != nil {
+			return nil, err
+		}
 
+		userModifier := modifier
+		modifier = func(jsonTagName string, t reflect.Type, tag reflect.StructTag, sc *jsonschema.Schema) {
+			if userModifier != nil {
+				userModifier(jsonTagName, t, tag, sc)
+			}
+			if jsonTagName == rootSchemaName {
+				sc.Examples = append(sc.Examples, examples...)
+			}
+		}
+	}
+
+	// the Reflector holds no state shared across calls, so inferring concurrently, even for the same type, is safe
 	r := &jsonschema.Reflector{
 		Anonymous:      true,
 		DoNotReference: true,
-		SchemaModifier: jsonschema.SchemaModifierFn(options.scModifier),
+		SchemaModifier: jsonschema.SchemaModifierFn(modifier),
 	}
 
-	js := r.Reflect(generic.NewInstance[T]())
+	js := r.Reflect(v)
 	js.Version = ""
 
+	if err := applyFieldDescriptions(js, options.fieldDescriptions); err != nil {
+		return nil, err
+	}
+
+	if options.strictSchema {
+		makeSchemaStrict(js)
+	}
+
 	paramsOneOf := schema.NewParamsOneOfByJSONSchema(js)
 
 	return paramsOneOf, nil
 }
 
+// removePointerFieldsFromRequired removes the fields of pointer type from the required list of the schema of struct type t.
+func removePointerFieldsFromRequired(t reflect.Type, sc *jsonschema.Schema) {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct || len(sc.Required) == 0 {
+		return
+	}
+
+	optional := make(map[string]bool)
+	for _, f := range reflect.VisibleFields(t) {
+		if f.Type.Kind() != reflect.Ptr || !f.IsExported() {
+			continue
+		}
+		name := strings.Split(f.Tag.Get("json"), ",")[0]
+		if name == "-" || (name == "" && f.Anonymous) { // embedded structs are inlined
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		optional[name] = true
+	}
+
+	required := make([]string, 0, len(sc.Required))
+	for _, name := range sc.Required {
+		if !optional[name] {
+			required = append(required, name)
+		}
+	}
+	sc.Required = required
+}
+
+// applyFieldDescriptions sets the descriptions of the properties addressed by the dotted paths, see WithFieldDescriptionsFromComments.
+func applyFieldDescriptions(js *jsonschema.Schema, descriptions map[string]string) error {
+	for path, desc := range descriptions {
+		sc := js
+		for _, name := range strings.Split(path, ".") {
+			for sc.Items != nil && sc.Properties == nil {
+				sc = sc.Items
+			}
+
+			var prop *jsonschema.Schema
+			if sc.Properties != nil {
+				prop, _ = sc.Properties.Get(name)
+			}
+			if prop == nil {
+				return fmt.Errorf("field description path %q matches no property", path)
+			}
+			sc = prop
+		}
+
+		sc.Description = desc
+	}
+
+	return nil
+}
+
+// makeSchemaStrict disallows the additional properties of the objects in sc and makes all of their properties required,
+// where the optional ones become nullable.
+func makeSchemaStrict(sc *jsonschema.Schema) {
+	if sc == nil {
+		return
+	}
+
+	if sc.Type == string(schema.Object) || sc.Properties != nil {
+		if sc.AdditionalProperties == nil {
+			sc.AdditionalProperties = jsonschema.FalseSchema
+		}
+
+		if sc.Properties != nil {
+			required := make(map[string]bool, len(sc.Required))
+			for _, name := range sc.Required {
+				required[name] = true
+			}
+
+			sc.Required = make([]string, 0, sc.Properties.Len())
+			for pair := sc.Properties.Oldest(); pair != nil; pair = pair.Next() {
+				if !required[pair.Key] {
+					makeSchemaNullable(pair.Value)
+				}
+				sc.Required = append(sc.Required, pair.Key)
+				makeSchemaStrict(pair.Value)
+			}
+		}
+	}
+
+	makeSchemaStrict(sc.Items)
+	makeSchemaStrict(sc.AdditionalProperties)
+	for _, sub := range sc.PrefixItems {
+		makeSchemaStrict(sub)
+	}
+	for _, sub := range sc.AllOf {
+		makeSchemaStrict(sub)
+	}
+	for _, sub := range sc.AnyOf {
+		makeSchemaStrict(sub)
+	}
+	for _, sub := range sc.OneOf {
+		makeSchemaStrict(sub)
+	}
+}
+
+// makeSchemaNullable makes sc accept null in addition to the values it accepts.
+func makeSchemaNullable(sc *jsonschema.Schema) {
+	nullType := string(schema.Null)
+
+	switch {
+	case sc.Type != "":
+		sc.TypeEnhanced = []string{sc.Type, nullType}
+		sc.Type = ""
+	case len(sc.TypeEnhanced) > 0:
+		for _, typ := range sc.TypeEnhanced {
+			if typ == nullType {
+				return
+			}
+		}
+		sc.TypeEnhanced = append(sc.TypeEnhanced, nullType)
+	case len(sc.AnyOf) > 0:
+		sc.AnyOf = append(sc.AnyOf, &jsonschema.Schema{Type: nullType})
+	default:
+		// no type constraint, null is accepted already
+		return
+	}
+
+	if len(sc.Enum) > 0 {
+		sc.Enum = append(sc.Enum, nil)
+	}
+}
+
+// rootSchemaName is the jsonTagName passed to SchemaModifierFn for the root schema.
+const rootSchemaName = "_root"
+
+func marshalSchemaExamples(examples []any) ([]any, error) {
+	ret := make([]any, 0, len(examples))
+	for i, example := range examples {
+		data, err := sonic.Marshal(example)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal schema example[%d]: %w", i, err)
+		}
+
+		var v any
+		if err = sonic.Unmarshal(data, &v); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal schema example[%d]: %w", i, err)
+		}
+		ret = append(ret, v)
+	}
+
+	return ret, nil
+}
+
 // NewTool Create a tool, where the input and output are both in JSON format.
+// An invalid option, e.g. a malformed WithOutputJSONPath expression, makes the tool fail on run,
+// while InferTool reports it on construction.
 func NewTool[T, D any](desc *schema.ToolInfo, i InvokeFunc[T, D], opts ...Option) tool.InvokableTool {
 	return newOptionableTool(desc, func(ctx context.Context, input T, _ ...tool.Option) (D, error) {
 		return i(ctx, input)
@@ -135,10 +380,15 @@ func newOptionableTool[T, D any](desc *schema.ToolInfo, i OptionableInvokeFunc[T
 	to := getToolOptions(opts...)
 
 	return &invokableTool[T, D]{
-		info: desc,
-		um:   to.um,
-		m:    to.m,
-		Fn:   i,
+		info:     desc,
+		um:       getUnmarshalArguments[T](to),
+		m:        to.m,
+		approver: to.approver,
+		cache:    to.cache,
+		cacheTTL: to.cacheTTL,
+		tracer:   to.tracer,
+		limiter:  to.limiter,
+		Fn:       i,
 	}
 }
 
@@ -148,6 +398,15 @@ type invokableTool[T, D any] struct {
 	um UnmarshalArguments
 	m  MarshalOutput
 
+	approver ApprovalFunc
+
+	cache    Cache
+	cacheTTL time.Duration
+
+	tracer Tracer
+
+	limiter *ConcurrencyLimiter
+
 	Fn OptionableInvokeFunc[T, D]
 }
 
@@ -157,11 +416,20 @@ func (i *invokableTool[T, D]) Info(ctx context.Context) (*schema.ToolInfo, error
 
 // InvokableRun invokes the tool with the given arguments.
 func (i *invokableTool[T, D]) InvokableRun(ctx context.Context, arguments string, opts ...tool.Option) (output string, err error) {
+	if i.tracer != nil {
+		var span Span
+		ctx, span = startToolSpan(ctx, i.tracer, i.getToolName(), arguments)
+		defer func() {
+			endToolSpan(span, len(output), err)
+		}()
+	}
+
+	mctx := withMarshalContext(ctx, opts...)
 
 	var inst T
 	if i.um != nil {
 		var val any
-		val, err = i.um(ctx, arguments)
+		val, err = i.um(mctx, arguments)
 		if err != nil {
 			return "", fmt.Errorf("[LocalFunc] failed to unmarshal arguments, toolName=%s, err=%w", i.getToolName(), err)
 		}
@@ -179,13 +447,30 @@ func (i *invokableTool[T, D]) InvokableRun(ctx context.Context, arguments string
 		}
 	}
 
+	if err = checkApproval(ctx, i.approver, i.getToolName(), arguments); err != nil {
+		return "", err
+	}
+
+	var cacheKey string
+	if i.cache != nil {
+		cacheKey = toolCacheKey(i.getToolName(), arguments)
+		if cached, ok := i.cache.Get(ctx, cacheKey); ok {
+			return cached, nil
+		}
+	}
+
+	if err = i.limiter.acquire(ctx, i.getToolName()); err != nil {
+		return "", err
+	}
+	defer i.limiter.release()
+
 	resp, err := i.Fn(ctx, inst, opts...)
 	if err != nil {
 		return "", fmt.Errorf("[LocalFunc] failed to invoke tool, toolName=%s, err=%w", i.getToolName(), err)
 	}
 
 	if i.m != nil {
-		output, err = i.m(ctx, resp)
+		output, err = i.m(mctx, resp)
 		if err != nil {
 			return "", fmt.Errorf("[LocalFunc] failed to marshal output, toolName=%s, err=%w", i.getToolName(), err)
 		}
@@ -196,6 +481,10 @@ func (i *invokableTool[T, D]) InvokableRun(ctx context.Context, arguments string
 		}
 	}
 
+	if i.cache != nil {
+		i.cache.Set(ctx, cacheKey, output, i.cacheTTL)
+	}
+
 	return output, nil
 }
 
@@ -239,9 +528,14 @@ func newOptionableEnhancedTool[T any](desc *schema.ToolInfo, i OptionableEnhance
 	to := getToolOptions(opts...)
 
 	return &enhancedInvokableTool[T]{
-		info: desc,
-		um:   to.um,
-		Fn:   i,
+		info:              desc,
+		um:                getUnmarshalArguments[T](to),
+		approver:          to.approver,
+		errorFormatter:    to.errorFormatter,
+		argErrorFormatter: to.argErrorFormatter,
+		tracer:            to.tracer,
+		limiter:           to.limiter,
+		Fn:                i,
 	}
 }
 
@@ -250,6 +544,15 @@ type enhancedInvokableTool[T any] struct {
 
 	um UnmarshalArguments
 
+	approver ApprovalFunc
+
+	errorFormatter    func(error) *schema.ToolResult
+	argErrorFormatter func(err error, rawArgs string) *schema.ToolResult
+
+	tracer Tracer
+
+	limiter *ConcurrencyLimiter
+
 	Fn OptionableEnhancedInvokeFunc[T]
 }
 
@@ -257,14 +560,26 @@ func (e *enhancedInvokableTool[T]) Info(ctx context.Context) (*schema.ToolInfo,
 	return e.info, nil
 }
 
-func (e *enhancedInvokableTool[T]) InvokableRun(ctx context.Context, toolArgument *schema.ToolArgument, opts ...tool.Option) (*schema.ToolResult, error) {
+func (e *enhancedInvokableTool[T]) InvokableRun(ctx context.Context, toolArgument *schema.ToolArgument, opts ...tool.Option) (
+	result *schema.ToolResult, err error) {
+
+	if e.tracer != nil {
+		var span Span
+		ctx, span = startToolSpan(ctx, e.tracer, e.getToolName(), toolArgument.Text)
+		defer func() {
+			endToolSpan(span, toolResultSize(result), err)
+		}()
+	}
+
 	var inst T
-	var err error
 
 	if e.um != nil {
 		var val any
-		val, err = e.um(ctx, toolArgument.Text)
+		val, err = e.um(withMarshalContext(ctx, opts...), toolArgument.Text)
 		if err != nil {
+			if result, ok := formatArgErrorAsResult(e.argErrorFormatter, err, toolArgument.Text); ok {
+				return result, nil
+			}
 			return nil, fmt.Errorf("[EnhancedLocalFunc] failed to unmarshal arguments, toolName=%s, err=%w", e.getToolName(), err)
 		}
 		gt, ok := val.(T)
@@ -277,16 +592,32 @@ func (e *enhancedInvokableTool[T]) InvokableRun(ctx context.Context, toolArgumen
 
 		err = sonic.UnmarshalString(toolArgument.Text, &inst)
 		if err != nil {
+			if result, ok := formatArgErrorAsResult(e.argErrorFormatter, err, toolArgument.Text); ok {
+				return result, nil
+			}
 			return nil, fmt.Errorf("[EnhancedLocalFunc] failed to unmarshal arguments in json, toolName=%s, err=%w", e.getToolName(), err)
 		}
 	}
 
+	if err = checkApproval(ctx, e.approver, e.getToolName(), toolArgument.Text); err != nil {
+		return nil, err
+	}
+
+	if err = e.limiter.acquire(ctx, e.getToolName()); err != nil {
+		return nil, err
+	}
+	defer e.limiter.release()
+
+	start := time.Now()
 	resp, err := e.Fn(ctx, inst, opts...)
 	if err != nil {
+		if result, ok := formatErrorAsResult(e.errorFormatter, err); ok {
+			return withToolResultMeta(result, false, time.Since(start)), nil
+		}
 		return nil, fmt.Errorf("[EnhancedLocalFunc] failed to invoke tool, toolName=%s, err=%w", e.getToolName(), err)
 	}
 
-	return resp, nil
+	return withToolResultMeta(resp, true, time.Since(start)), nil
 }
 
 func (e *enhancedInvokableTool[T]) GetType() string {
@@ -19,8 +19,13 @@ package utils
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"reflect"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/eino-contrib/jsonschema"
 	"github.com/stretchr/testify/assert"
@@ -246,6 +251,37 @@ func TestInferTool(t *testing.T) {
 		assert.NoError(t, err)
 		assert.JSONEq(t, `{"code":200,"msg":"update bruce lee success"}`, content)
 	})
+
+	t.Run("concurrent infer", func(t *testing.T) {
+		expect, err := toolInfo.ToJSONSchema()
+		assert.NoError(t, err)
+		expectStr, err := json.Marshal(expect)
+		assert.NoError(t, err)
+
+		wg := sync.WaitGroup{}
+		size := 100
+		wg.Add(size)
+		for i := 0; i < size; i++ {
+			go func() {
+				defer wg.Done()
+				tl, err := InferTool("update_user_info", "full update user info", updateUserInfo,
+					WithSchemaExamples(&User{Name: "bruce lee"}))
+				assert.NoError(t, err)
+
+				info, err := tl.Info(context.Background())
+				assert.NoError(t, err)
+				actual, err := info.ToJSONSchema()
+				assert.NoError(t, err)
+				assert.Len(t, actual.Examples, 1)
+				actual.Examples = nil
+				actualStr, err := json.Marshal(actual)
+				assert.NoError(t, err)
+				assert.Equal(t, string(expectStr), string(actualStr))
+			}()
+		}
+
+		wg.Wait()
+	})
 }
 
 func TestInferOptionableTool(t *testing.T) {
@@ -403,3 +439,1092 @@ func TestEnumTag(t *testing.T) {
 	_, err = goStruct2ParamsOneOf[testEnumStruct3]()
 	assert.NoError(t, err)
 }
+
+func TestMarshalContextValue(t *testing.T) {
+	ctx := context.Background()
+	type localeKey struct{}
+
+	tl := NewTool(nil, func(ctx context.Context, input string) (float64, error) {
+		return 1234.5, nil
+	}, WithUnmarshalArguments(func(ctx context.Context, arguments string) (any, error) {
+		locale, ok := GetMarshalContextValue(ctx, localeKey{})
+		assert.True(t, ok)
+		assert.Equal(t, "de-DE", locale)
+		return arguments, nil
+	}), WithMarshalOutput(func(ctx context.Context, output any) (string, error) {
+		locale, ok := GetMarshalContextValue(ctx, localeKey{})
+		if ok && locale == "de-DE" {
+			return "1.234,5", nil
+		}
+		return fmt.Sprintf("%v", output), nil
+	}))
+
+	content, err := tl.InvokableRun(ctx, "input", WithMarshalContextValue(localeKey{}, "de-DE"))
+	assert.NoError(t, err)
+	assert.Equal(t, "1.234,5", content)
+
+	_, ok := GetMarshalContextValue(ctx, localeKey{})
+	assert.False(t, ok)
+}
+
+func TestSchemaExamples(t *testing.T) {
+	type Input struct {
+		City string `json:"city" jsonschema:"description=the city"`
+		Days int    `json:"days,omitempty"`
+	}
+
+	var visited []string
+	info, err := GoStruct2ToolInfo[Input]("forecast", "get forecast",
+		WithSchemaExamples(Input{City: "Paris", Days: 3}, map[string]any{"city": "Tokyo"}),
+		WithSchemaModifier(func(jsonTagName string, _ reflect.Type, _ reflect.StructTag, _ *jsonschema.Schema) {
+			visited = append(visited, jsonTagName)
+		}))
+	assert.NoError(t, err)
+	assert.Contains(t, visited, "_root")
+
+	js, err := info.ToJSONSchema()
+	assert.NoError(t, err)
+	assert.Equal(t, []any{
+		map[string]any{"city": "Paris", "days": float64(3)},
+		map[string]any{"city": "Tokyo"},
+	}, js.Examples)
+
+	city, ok := js.Properties.Get("city")
+	assert.True(t, ok)
+	assert.Empty(t, city.Examples)
+
+	data, err := json.Marshal(js)
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), `"examples":[{"city":"Paris","days":3},{"city":"Tokyo"}]`)
+
+	_, err = GoStruct2ToolInfo[Input]("forecast", "get forecast", WithSchemaExamples(make(chan int)))
+	assert.Error(t, err)
+}
+
+func TestOutputJSONPath(t *testing.T) {
+	type Input struct {
+		ID string `json:"id"`
+	}
+	type Address struct {
+		City string `json:"city"`
+	}
+	type Order struct {
+		Title string `json:"title"`
+	}
+	type User struct {
+		ID      string   `json:"id"`
+		Address *Address `json:"address"`
+		Orders  []Order  `json:"orders"`
+	}
+
+	getUser := func(ctx context.Context, input Input) (*User, error) {
+		return &User{
+			ID:      input.ID,
+			Address: &Address{City: "Paris"},
+			Orders:  []Order{{Title: "book"}, {Title: "pen"}},
+		}, nil
+	}
+
+	ctx := context.Background()
+	for _, tc := range []struct {
+		expr     string
+		expected string
+	}{
+		{expr: "$.address.city", expected: `"Paris"`},
+		{expr: "$['address']", expected: `{"city":"Paris"}`},
+		{expr: "$.orders[-1].title", expected: `"pen"`},
+		{expr: "$.orders[*].title", expected: `["book","pen"]`},
+		{expr: "$", expected: `{"id":"1","address":{"city":"Paris"},"orders":[{"title":"book"},{"title":"pen"}]}`},
+	} {
+		tl, err := InferTool("get_user", "get user", getUser, WithOutputJSONPath(tc.expr))
+		assert.NoError(t, err)
+
+		out, err := tl.InvokableRun(ctx, `{"id":"1"}`)
+		assert.NoError(t, err, tc.expr)
+		assert.JSONEq(t, tc.expected, out, tc.expr)
+	}
+
+	t.Run("missing field", func(t *testing.T) {
+		tl, err := InferTool("get_user", "get user", getUser, WithOutputJSONPath("$.phone"))
+		assert.NoError(t, err)
+		_, err = tl.InvokableRun(ctx, `{"id":"1"}`)
+		assert.ErrorContains(t, err, "not found")
+	})
+
+	t.Run("invalid path", func(t *testing.T) {
+		for _, expr := range []string{"address.city", "$.orders[x]", "$.orders[0", "$..city"} {
+			_, err := InferTool("get_user", "get user", getUser, WithOutputJSONPath(expr))
+			assert.Error(t, err, expr)
+		}
+
+		tl := NewTool(&schema.ToolInfo{Name: "get_user"}, getUser, WithOutputJSONPath("address"))
+		_, err := tl.InvokableRun(ctx, `{"id":"1"}`)
+		assert.ErrorContains(t, err, "address")
+	})
+}
+
+func TestRequireApproval(t *testing.T) {
+	type Input struct {
+		To string `json:"to"`
+	}
+
+	ctx := context.Background()
+	var approverArgs []string
+	approver := func(approved bool) ApprovalFunc {
+		return func(ctx context.Context, toolName, arguments string) (bool, error) {
+			approverArgs = append(approverArgs, toolName, arguments)
+			return approved, nil
+		}
+	}
+
+	called := 0
+	sendEmail := func(ctx context.Context, input Input) (string, error) {
+		called++
+		return "sent to " + input.To, nil
+	}
+
+	t.Run("approved", func(t *testing.T) {
+		approverArgs, called = nil, 0
+		tl, err := InferTool("send_email", "send an email", sendEmail, WithRequireApproval(approver(true)))
+		assert.NoError(t, err)
+
+		out, err := tl.InvokableRun(ctx, `{"to":"bob"}`)
+		assert.NoError(t, err)
+		assert.Equal(t, `sent to bob`, out)
+		assert.Equal(t, 1, called)
+		assert.Equal(t, []string{"send_email", `{"to":"bob"}`}, approverArgs)
+	})
+
+	t.Run("denied", func(t *testing.T) {
+		approverArgs, called = nil, 0
+		tl, err := InferTool("send_email", "send an email", sendEmail, WithRequireApproval(approver(false)))
+		assert.NoError(t, err)
+
+		_, err = tl.InvokableRun(ctx, `{"to":"bob"}`)
+		var denied *ApprovalDeniedError
+		assert.True(t, errors.As(err, &denied))
+		assert.Equal(t, "send_email", denied.ToolName)
+		assert.Equal(t, `{"to":"bob"}`, denied.Arguments)
+		assert.Equal(t, 0, called)
+	})
+
+	t.Run("approver error", func(t *testing.T) {
+		called = 0
+		tl, err := InferTool("send_email", "send an email", sendEmail,
+			WithRequireApproval(func(ctx context.Context, toolName, arguments string) (bool, error) {
+				return false, errors.New("approval timeout")
+			}))
+		assert.NoError(t, err)
+
+		_, err = tl.InvokableRun(ctx, `{"to":"bob"}`)
+		assert.ErrorContains(t, err, "approval timeout")
+		assert.Equal(t, 0, called)
+	})
+
+	t.Run("streamable denied", func(t *testing.T) {
+		streamCalled := false
+		tl, err := InferStreamTool("send_email", "send an email",
+			func(ctx context.Context, input Input) (*schema.StreamReader[string], error) {
+				streamCalled = true
+				return schema.StreamReaderFromArray([]string{"sent"}), nil
+			}, WithRequireApproval(approver(false)))
+		assert.NoError(t, err)
+
+		_, err = tl.StreamableRun(ctx, `{"to":"bob"}`)
+		var denied *ApprovalDeniedError
+		assert.True(t, errors.As(err, &denied))
+		assert.False(t, streamCalled)
+	})
+}
+
+func TestInferToolInfoFromFunc(t *testing.T) {
+	type Input struct {
+		City string `json:"city" jsonschema:"description=the city"`
+		Days int    `json:"days,omitempty"`
+	}
+
+	expected, err := GoStruct2ToolInfo[Input]("forecast", "get forecast")
+	assert.NoError(t, err)
+
+	for name, fn := range map[string]any{
+		"ctx and struct":         func(ctx context.Context, input Input) (string, error) { return "", nil },
+		"ctx and pointer":        func(ctx context.Context, input *Input) (string, error) { return "", nil },
+		"struct only":            func(input Input) string { return "" },
+		"with variadic options":  func(ctx context.Context, input *Input, opts ...tool.Option) (string, error) { return "", nil },
+		"stream function":        func(ctx context.Context, input Input) (*schema.StreamReader[string], error) { return nil, nil },
+		"input before a context": func(input Input, ctx context.Context) {},
+	} {
+		info, err := InferToolInfoFromFunc("forecast", "get forecast", fn)
+		assert.NoError(t, err, name)
+		assert.Equal(t, expected, info, name)
+	}
+
+	for name, fn := range map[string]any{
+		"not a function": Input{},
+		"nil":            nil,
+		"context only":   func(ctx context.Context) {},
+		"only variadic":  func(opts ...tool.Option) {},
+		"string input":   func(ctx context.Context, s string) {},
+	} {
+		_, err := InferToolInfoFromFunc("forecast", "get forecast", fn)
+		assert.Error(t, err, name)
+	}
+}
+
+func TestOutputKeyRemap(t *testing.T) {
+	type Input struct {
+		ID string `json:"id"`
+	}
+	type Address struct {
+		CityNm string `json:"city_nm"`
+	}
+	type User struct {
+		UsrNm string   `json:"usr_nm"`
+		Age   int      `json:"age"`
+		Addr  *Address `json:"addr"`
+	}
+
+	getUser := func(ctx context.Context, input Input) (*User, error) {
+		return &User{UsrNm: "bob", Age: 18, Addr: &Address{CityNm: "Paris"}}, nil
+	}
+
+	ctx := context.Background()
+	tl, err := InferTool("get_user", "get user", getUser,
+		WithOutputKeyRemap(map[string]string{"usr_nm": "name", "addr": "address"}))
+	assert.NoError(t, err)
+
+	out, err := tl.InvokableRun(ctx, `{"id":"1"}`)
+	assert.NoError(t, err)
+	// only top-level keys are renamed, and the key order is kept
+	assert.Equal(t, `{"name":"bob","age":18,"address":{"city_nm":"Paris"}}`, out)
+
+	t.Run("with json path", func(t *testing.T) {
+		tl, err := InferTool("get_user", "get user", getUser,
+			WithOutputJSONPath("$.addr"), WithOutputKeyRemap(map[string]string{"city_nm": "city"}))
+		assert.NoError(t, err)
+
+		out, err := tl.InvokableRun(ctx, `{"id":"1"}`)
+		assert.NoError(t, err)
+		assert.Equal(t, `{"city":"Paris"}`, out)
+	})
+
+	t.Run("duplicated key", func(t *testing.T) {
+		tl, err := InferTool("get_user", "get user", getUser, WithOutputKeyRemap(map[string]string{"usr_nm": "age"}))
+		assert.NoError(t, err)
+
+		_, err = tl.InvokableRun(ctx, `{"id":"1"}`)
+		assert.ErrorContains(t, err, "duplicated")
+	})
+
+	t.Run("non object output", func(t *testing.T) {
+		tl, err := InferTool("echo", "echo", func(ctx context.Context, input Input) ([]string, error) {
+			return []string{input.ID}, nil
+		}, WithOutputKeyRemap(map[string]string{"id": "ID"}))
+		assert.NoError(t, err)
+
+		out, err := tl.InvokableRun(ctx, `{"id":"1"}`)
+		assert.NoError(t, err)
+		assert.Equal(t, `["1"]`, out)
+	})
+}
+
+func TestOutputSchema(t *testing.T) {
+	type Input struct {
+		ID string `json:"id"`
+	}
+	type Item struct {
+		Title string `json:"title"`
+		Score any    `json:"score"`
+	}
+	type Output struct {
+		Status string  `json:"status"`
+		Items  []*Item `json:"items"`
+	}
+
+	sc := &jsonschema.Schema{}
+	err := json.Unmarshal([]byte(`{
+		"type": "object",
+		"required": ["status", "items"],
+		"additionalProperties": false,
+		"properties": {
+			"status": {"type": "string", "enum": ["ok", "partial"]},
+			"items": {
+				"type": "array",
+				"maxItems": 2,
+				"items": {
+					"type": "object",
+					"required": ["title"],
+					"properties": {
+						"title": {"type": "string", "minLength": 1},
+						"score": {"type": "integer", "minimum": 0, "maximum": 100}
+					}
+				}
+			}
+		}
+	}`), sc)
+	assert.NoError(t, err)
+
+	ctx := context.Background()
+	run := func(out *Output) (string, error) {
+		tl, err := InferTool("search", "search", func(ctx context.Context, input Input) (*Output, error) {
+			return out, nil
+		}, WithOutputSchema(sc))
+		assert.NoError(t, err)
+		return tl.InvokableRun(ctx, `{"id":"1"}`)
+	}
+
+	out, err := run(&Output{Status: "ok", Items: []*Item{{Title: "eino", Score: 90}}})
+	assert.NoError(t, err)
+	assert.Equal(t, `{"status":"ok","items":[{"title":"eino","score":90}]}`, out)
+
+	for _, tc := range []struct {
+		name string
+		out  *Output
+		path string
+	}{
+		{name: "enum", out: &Output{Status: "failed", Items: []*Item{}}, path: "$.status"},
+		{name: "null array", out: &Output{Status: "ok"}, path: "$.items"},
+		{name: "max items", out: &Output{Status: "ok", Items: []*Item{{Title: "a"}, {Title: "b"}, {Title: "c"}}}, path: "$.items"},
+		{name: "min length", out: &Output{Status: "ok", Items: []*Item{{Title: ""}}}, path: "$.items[0].title"},
+		{name: "integer", out: &Output{Status: "ok", Items: []*Item{{Title: "a", Score: 1.5}}}, path: "$.items[0].score"},
+		{name: "maximum", out: &Output{Status: "ok", Items: []*Item{{Title: "a", Score: 101}}}, path: "$.items[0].score"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := run(tc.out)
+			var schemaErr *OutputSchemaError
+			if assert.True(t, errors.As(err, &schemaErr)) {
+				assert.Equal(t, tc.path, schemaErr.Path)
+			}
+		})
+	}
+
+	t.Run("additional properties", func(t *testing.T) {
+		tl, err := InferTool("search", "search", func(ctx context.Context, input Input) (map[string]any, error) {
+			return map[string]any{"status": "ok", "items": []any{}, "debug": true}, nil
+		}, WithOutputSchema(sc))
+		assert.NoError(t, err)
+
+		_, err = tl.InvokableRun(ctx, `{"id":"1"}`)
+		assert.ErrorContains(t, err, `additional property "debug" is not allowed`)
+	})
+
+	t.Run("content type", func(t *testing.T) {
+		fn := func(ctx context.Context, input Input) (*Output, error) {
+			return &Output{Status: "ok", Items: []*Item{}}, nil
+		}
+		_, err := InferTool("search", "search", fn, WithOutputSchema(sc), WithOutputContentType(OutputContentTypeText))
+		assert.ErrorContains(t, err, "requires json output")
+		tl := NewTool(&schema.ToolInfo{Name: "search"}, fn, WithOutputSchema(sc), WithOutputContentType(OutputContentTypeMarkdown))
+		_, err = tl.InvokableRun(ctx, `{"id":"1"}`)
+		assert.ErrorContains(t, err, "requires json output")
+
+		_, err = InferTool("search", "search", fn, WithOutputSchema(sc), WithOutputContentType(OutputContentTypeJSON))
+		assert.NoError(t, err)
+	})
+}
+
+func TestMarshalOptions(t *testing.T) {
+	type Input struct {
+		ID string `json:"id"`
+	}
+	type Output struct {
+		HTML string         `json:"html"`
+		Tags map[string]int `json:"tags"`
+	}
+
+	ctx := context.Background()
+	fn := func(ctx context.Context, input Input) (*Output, error) {
+		return &Output{HTML: "<b>a & b</b>", Tags: map[string]int{"c": 3, "a": 1, "b": 2}}, nil
+	}
+
+	tl, err := InferTool("render", "render", fn, WithMarshalOptions(MarshalOptions{EscapeHTML: true, SortMapKeys: true}))
+	assert.NoError(t, err)
+	out, err := tl.InvokableRun(ctx, `{"id":"1"}`)
+	assert.NoError(t, err)
+	assert.Equal(t, `{"html":"\u003cb\u003ea \u0026 b\u003c/b\u003e","tags":{"a":1,"b":2,"c":3}}`, out)
+
+	tl, err = InferTool("render", "render", fn, WithMarshalOptions(MarshalOptions{EscapeHTML: false, SortMapKeys: true}))
+	assert.NoError(t, err)
+	out, err = tl.InvokableRun(ctx, `{"id":"1"}`)
+	assert.NoError(t, err)
+	assert.Equal(t, `{"html":"<b>a & b</b>","tags":{"a":1,"b":2,"c":3}}`, out)
+
+	t.Run("with json path", func(t *testing.T) {
+		tl, err := InferTool("render", "render", fn,
+			WithMarshalOptions(MarshalOptions{SortMapKeys: true}), WithOutputJSONPath("$.tags"))
+		assert.NoError(t, err)
+		out, err := tl.InvokableRun(ctx, `{"id":"1"}`)
+		assert.NoError(t, err)
+		assert.JSONEq(t, `{"a":1,"b":2,"c":3}`, out)
+	})
+}
+
+type mapCache struct {
+	m    map[string]string
+	ttls map[string]time.Duration
+}
+
+func (c *mapCache) Get(_ context.Context, key string) (string, bool) {
+	v, ok := c.m[key]
+	return v, ok
+}
+
+func (c *mapCache) Set(_ context.Context, key string, output string, ttl time.Duration) {
+	c.m[key] = output
+	c.ttls[key] = ttl
+}
+
+func TestWithCache(t *testing.T) {
+	type Input struct {
+		City string `json:"city"`
+		Unit string `json:"unit"`
+	}
+
+	ctx := context.Background()
+	calls := 0
+	cache := &mapCache{m: map[string]string{}, ttls: map[string]time.Duration{}}
+	tl, err := InferTool("get_weather", "get weather", func(ctx context.Context, input Input) (string, error) {
+		calls++
+		if input.City == "" {
+			return "", errors.New("city is required")
+		}
+		return fmt.Sprintf("sunny in %s, call %d", input.City, calls), nil
+	}, WithCache(cache, time.Minute))
+	assert.NoError(t, err)
+
+	out, err := tl.InvokableRun(ctx, `{"city":"Paris","unit":"C"}`)
+	assert.NoError(t, err)
+	assert.Equal(t, "sunny in Paris, call 1", out)
+
+	// the same arguments in a different key order and whitespace hit the cache
+	out, err = tl.InvokableRun(ctx, `{ "unit": "C", "city": "Paris" }`)
+	assert.NoError(t, err)
+	assert.Equal(t, "sunny in Paris, call 1", out)
+	assert.Equal(t, 1, calls)
+
+	out, err = tl.InvokableRun(ctx, `{"city":"London","unit":"C"}`)
+	assert.NoError(t, err)
+	assert.Equal(t, "sunny in London, call 2", out)
+
+	// failures are not cached
+	_, err = tl.InvokableRun(ctx, `{"unit":"C"}`)
+	assert.Error(t, err)
+	_, err = tl.InvokableRun(ctx, `{"unit":"C"}`)
+	assert.Error(t, err)
+	assert.Equal(t, 4, calls)
+
+	assert.Len(t, cache.m, 2)
+	for _, ttl := range cache.ttls {
+		assert.Equal(t, time.Minute, ttl)
+	}
+}
+
+type memorySpan struct {
+	name  string
+	attrs map[string]any
+	err   error
+	ended bool
+}
+
+func (s *memorySpan) SetAttribute(key string, value any) { s.attrs[key] = value }
+func (s *memorySpan) RecordError(err error)              { s.err = err }
+func (s *memorySpan) End()                               { s.ended = true }
+
+type memorySpanKey struct{}
+
+type memoryTracer struct {
+	spans []*memorySpan
+}
+
+func (m *memoryTracer) Start(ctx context.Context, spanName string) (context.Context, Span) {
+	span := &memorySpan{name: spanName, attrs: map[string]any{}}
+	m.spans = append(m.spans, span)
+	return context.WithValue(ctx, memorySpanKey{}, span), span
+}
+
+func TestWithTracing(t *testing.T) {
+	type Input struct {
+		City string `json:"city"`
+	}
+
+	ctx := context.Background()
+	tracer := &memoryTracer{}
+	tl, err := InferTool("get_weather", "get weather", func(ctx context.Context, input Input) (string, error) {
+		assert.NotNil(t, ctx.Value(memorySpanKey{}))
+		if input.City == "" {
+			return "", errors.New("city is required")
+		}
+		return "sunny in " + input.City, nil
+	}, WithTracing(tracer))
+	assert.NoError(t, err)
+
+	out, err := tl.InvokableRun(ctx, `{"city":"Paris"}`)
+	assert.NoError(t, err)
+	assert.Equal(t, "sunny in Paris", out)
+
+	_, err = tl.InvokableRun(ctx, `{}`)
+	assert.Error(t, err)
+
+	assert.Len(t, tracer.spans, 2)
+	assert.Equal(t, &memorySpan{
+		name: "get_weather",
+		attrs: map[string]any{
+			SpanAttrToolName:      "get_weather",
+			SpanAttrArgumentsSize: len(`{"city":"Paris"}`),
+			SpanAttrOutputSize:    len("sunny in Paris"),
+		},
+		ended: true,
+	}, tracer.spans[0])
+
+	failed := tracer.spans[1]
+	assert.True(t, failed.ended)
+	assert.ErrorContains(t, failed.err, "city is required")
+	assert.Equal(t, 2, failed.attrs[SpanAttrArgumentsSize])
+	assert.NotContains(t, failed.attrs, SpanAttrOutputSize)
+
+	// the enhanced and the streamable tools are traced the same way
+	tracer = &memoryTracer{}
+	etl, err := InferEnhancedTool("get_weather", "get weather", func(ctx context.Context, input Input) (*schema.ToolResult, error) {
+		assert.NotNil(t, ctx.Value(memorySpanKey{}))
+		return &schema.ToolResult{Parts: []schema.ToolOutputPart{{Type: schema.ToolPartTypeText, Text: "sunny"}}}, nil
+	}, WithTracing(tracer))
+	assert.NoError(t, err)
+	_, err = etl.InvokableRun(ctx, &schema.ToolArgument{Text: `{"city":"Paris"}`})
+	assert.NoError(t, err)
+
+	stl, err := InferStreamTool("get_weather", "get weather", func(ctx context.Context, input Input) (*schema.StreamReader[string], error) {
+		assert.NotNil(t, ctx.Value(memorySpanKey{}))
+		return schema.StreamReaderFromArray([]string{"sunny", " in ", input.City}), nil
+	}, WithTracing(tracer))
+	assert.NoError(t, err)
+	sr, err := stl.StreamableRun(ctx, `{"city":"Paris"}`)
+	assert.NoError(t, err)
+	// the span stays open until the stream ends
+	assert.False(t, tracer.spans[1].ended)
+	var chunks string
+	for {
+		chunk, err := sr.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		assert.NoError(t, err)
+		chunks += chunk
+	}
+	assert.Equal(t, "sunny in Paris", chunks)
+
+	estl, err := InferEnhancedStreamTool("get_weather", "get weather", func(ctx context.Context, input Input) (*schema.StreamReader[*schema.ToolResult], error) {
+		assert.NotNil(t, ctx.Value(memorySpanKey{}))
+		sr, sw := schema.Pipe[*schema.ToolResult](1)
+		go func() {
+			defer sw.Close()
+			sw.Send(&schema.ToolResult{Parts: []schema.ToolOutputPart{{Type: schema.ToolPartTypeText, Text: "sunny"}}}, nil)
+			sw.Send(nil, errors.New("connection lost"))
+		}()
+		return sr, nil
+	}, WithTracing(tracer))
+	assert.NoError(t, err)
+	esr, err := estl.StreamableRun(ctx, &schema.ToolArgument{Text: `{"city":"Paris"}`})
+	assert.NoError(t, err)
+	for err == nil {
+		_, err = esr.Recv()
+	}
+	assert.ErrorContains(t, err, "connection lost")
+	esr.Close()
+
+	assert.Len(t, tracer.spans, 3)
+	for _, span := range tracer.spans {
+		assert.True(t, span.ended)
+		assert.Equal(t, "get_weather", span.attrs[SpanAttrToolName])
+	}
+	assert.Equal(t, len("sunny"), tracer.spans[0].attrs[SpanAttrOutputSize])
+	assert.Equal(t, len("sunny in Paris"), tracer.spans[1].attrs[SpanAttrOutputSize])
+	assert.ErrorContains(t, tracer.spans[2].err, "connection lost")
+}
+
+func TestWithConcurrencyLimit(t *testing.T) {
+	type Input struct {
+		N int `json:"n"`
+	}
+
+	const limit = 2
+	limiter := NewConcurrencyLimiter(limit)
+
+	var (
+		mu            sync.Mutex
+		running, peak int
+	)
+	enter := func() {
+		mu.Lock()
+		running++
+		if running > peak {
+			peak = running
+		}
+		mu.Unlock()
+		time.Sleep(10 * time.Millisecond)
+		mu.Lock()
+		running--
+		mu.Unlock()
+	}
+
+	// the limiter is shared by a plain tool and an enhanced tool
+	tl, err := InferTool("a", "a", func(ctx context.Context, input Input) (int, error) {
+		enter()
+		return input.N, nil
+	}, WithConcurrencyLimit(limiter))
+	assert.NoError(t, err)
+	etl, err := InferEnhancedTool("b", "b", func(ctx context.Context, input Input) (*schema.ToolResult, error) {
+		enter()
+		return &schema.ToolResult{}, nil
+	}, WithConcurrencyLimit(limiter))
+	assert.NoError(t, err)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			_, err := tl.InvokableRun(context.Background(), `{"n":1}`)
+			assert.NoError(t, err)
+		}()
+		go func() {
+			defer wg.Done()
+			_, err := etl.InvokableRun(context.Background(), &schema.ToolArgument{Text: `{"n":1}`})
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, limit, peak)
+
+	// waiting for a slot is canceled with ctx
+	full := NewConcurrencyLimiter(1)
+	assert.NoError(t, full.acquire(context.Background(), "a"))
+	tl, err = InferTool("a", "a", func(ctx context.Context, input Input) (int, error) {
+		return input.N, nil
+	}, WithConcurrencyLimit(full))
+	assert.NoError(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = tl.InvokableRun(ctx, `{"n":1}`)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestWithFieldDescriptionsFromComments(t *testing.T) {
+	type Address struct {
+		City    string `json:"city"`
+		Country string `json:"country"`
+	}
+	type Item struct {
+		Title string `json:"title"`
+	}
+	type Input struct {
+		Name    string   `json:"name"`
+		Age     int      `json:"age" jsonschema:"description=from the tag"`
+		Address *Address `json:"address"`
+		Items   []*Item  `json:"items"`
+	}
+
+	descriptions := map[string]string{
+		"name":         "the name of the user",
+		"age":          "the age of the user in years",
+		"address.city": "the city the user lives in",
+		"items.title":  "the title of the item",
+	}
+
+	tl, err := InferTool("create_user", "create user", func(ctx context.Context, input Input) (string, error) {
+		return "", nil
+	}, WithFieldDescriptionsFromComments(descriptions))
+	assert.NoError(t, err)
+
+	info, err := tl.Info(context.Background())
+	assert.NoError(t, err)
+	js, err := info.ToJSONSchema()
+	assert.NoError(t, err)
+
+	get := func(sc *jsonschema.Schema, name string) *jsonschema.Schema {
+		prop, ok := sc.Properties.Get(name)
+		assert.True(t, ok)
+		return prop
+	}
+	assert.Equal(t, "the name of the user", get(js, "name").Description)
+	assert.Equal(t, "the age of the user in years", get(js, "age").Description)
+	assert.Equal(t, "the city the user lives in", get(get(js, "address"), "city").Description)
+	assert.Equal(t, "", get(get(js, "address"), "country").Description)
+	assert.Equal(t, "the title of the item", get(get(js, "items").Items, "title").Description)
+
+	_, err = InferTool("create_user", "create user", func(ctx context.Context, input Input) (string, error) {
+		return "", nil
+	}, WithFieldDescriptionsFromComments(map[string]string{"address.zip": "zip code"}))
+	assert.ErrorContains(t, err, `"address.zip"`)
+}
+
+type weatherReport struct {
+	City string `json:"city"`
+	Temp int    `json:"temp"`
+}
+
+func (w *weatherReport) String() string {
+	return fmt.Sprintf("%s: %d°C", w.City, w.Temp)
+}
+
+func TestOutputContentType(t *testing.T) {
+	type Input struct {
+		City string `json:"city"`
+	}
+	getWeather := func(ctx context.Context, input Input) (*weatherReport, error) {
+		return &weatherReport{City: input.City, Temp: 21}, nil
+	}
+	ctx := context.Background()
+
+	t.Run("json", func(t *testing.T) {
+		for _, opts := range [][]Option{nil, {WithOutputContentType(OutputContentTypeJSON)}} {
+			tl, err := InferTool("weather", "get weather", getWeather, opts...)
+			assert.NoError(t, err)
+
+			out, err := tl.InvokableRun(ctx, `{"city":"Paris"}`)
+			assert.NoError(t, err)
+			assert.Equal(t, `{"city":"Paris","temp":21}`, out)
+		}
+	})
+
+	t.Run("text by stringer", func(t *testing.T) {
+		tl, err := InferTool("weather", "get weather", getWeather, WithOutputContentType(OutputContentTypeText))
+		assert.NoError(t, err)
+
+		out, err := tl.InvokableRun(ctx, `{"city":"Paris"}`)
+		assert.NoError(t, err)
+		assert.Equal(t, "Paris: 21°C", out)
+	})
+
+	t.Run("text by string field", func(t *testing.T) {
+		type Output struct {
+			String string
+			Extra  int
+		}
+		tl, err := InferTool("weather", "get weather", func(ctx context.Context, input Input) (Output, error) {
+			return Output{String: "sunny in " + input.City}, nil
+		}, WithOutputContentType(OutputContentTypeText))
+		assert.NoError(t, err)
+
+		out, err := tl.InvokableRun(ctx, `{"city":"Paris"}`)
+		assert.NoError(t, err)
+		assert.Equal(t, "sunny in Paris", out)
+
+		tl, err = InferTool("weather", "get weather", func(ctx context.Context, input Input) (map[string]int, error) {
+			return map[string]int{"temp": 21}, nil
+		}, WithOutputContentType(OutputContentTypeText))
+		assert.NoError(t, err)
+		_, err = tl.InvokableRun(ctx, `{"city":"Paris"}`)
+		assert.ErrorContains(t, err, "cannot be rendered as text")
+	})
+
+	t.Run("markdown", func(t *testing.T) {
+		tl, err := InferTool("weather", "get weather", getWeather, WithOutputContentType(OutputContentTypeMarkdown),
+			WithMarkdownRenderer(func(ctx context.Context, output any) (string, error) {
+				w := output.(*weatherReport)
+				return fmt.Sprintf("| city | temp |\n| --- | --- |\n| %s | %d |", w.City, w.Temp), nil
+			}))
+		assert.NoError(t, err)
+
+		out, err := tl.InvokableRun(ctx, `{"city":"Paris"}`)
+		assert.NoError(t, err)
+		assert.Equal(t, "| city | temp |\n| --- | --- |\n| Paris | 21 |", out)
+
+		tl, err = InferTool("weather", "get weather", getWeather, WithOutputContentType(OutputContentTypeMarkdown))
+		assert.NoError(t, err)
+		_, err = tl.InvokableRun(ctx, `{"city":"Paris"}`)
+		assert.ErrorContains(t, err, "markdown renderer is required")
+	})
+}
+
+func TestPointerFieldsOptional(t *testing.T) {
+	type Filter struct {
+		Tag   string  `json:"tag"`
+		Score *string `json:"score"`
+	}
+	type Input struct {
+		Query  string  `json:"query"`
+		Limit  *int    `json:"limit"`
+		Filter *Filter `json:"filter"`
+		Sort   *string `json:"sort" jsonschema:"required"`
+		Page   int     `json:"page,omitempty"`
+		Cursor *string
+	}
+	fn := func(ctx context.Context, input *Input) (string, error) {
+		return "", nil
+	}
+
+	getSchema := func(opts ...Option) *jsonschema.Schema {
+		tl, err := InferTool("search", "search", fn, opts...)
+		assert.NoError(t, err)
+		info, err := tl.Info(context.Background())
+		assert.NoError(t, err)
+		js, err := info.ToJSONSchema()
+		assert.NoError(t, err)
+		return js
+	}
+
+	// by default, pointer fields are required like the value fields
+	js := getSchema()
+	assert.Equal(t, []string{"query", "limit", "filter", "sort", "Cursor"}, js.Required)
+
+	js = getSchema(WithPointerFieldsOptional(true))
+	assert.Equal(t, []string{"query"}, js.Required)
+	filter, ok := js.Properties.Get("filter")
+	assert.True(t, ok)
+	assert.Equal(t, []string{"tag"}, filter.Required)
+}
+
+func TestStrictSchema(t *testing.T) {
+	type Item struct {
+		Name string `json:"name"`
+	}
+	type Input struct {
+		Query string  `json:"query" jsonschema:"description=the query"`
+		Limit int     `json:"limit,omitempty" jsonschema:"enum=10,enum=20"`
+		Items []*Item `json:"items,omitempty"`
+	}
+
+	tl, err := InferTool("search", "search", func(ctx context.Context, input *Input) (string, error) {
+		return "", nil
+	}, WithStrictSchema(true))
+	assert.NoError(t, err)
+	info, err := tl.Info(context.Background())
+	assert.NoError(t, err)
+	js, err := info.ToJSONSchema()
+	assert.NoError(t, err)
+
+	data, err := json.Marshal(js)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{
+		"type": "object",
+		"properties": {
+			"query": {"type": "string", "description": "the query"},
+			"limit": {"type": ["integer", "null"], "enum": [10, 20, null]},
+			"items": {
+				"type": ["array", "null"],
+				"items": {
+					"type": "object",
+					"properties": {"name": {"type": "string"}},
+					"required": ["name"],
+					"additionalProperties": false
+				}
+			}
+		},
+		"required": ["query", "limit", "items"],
+		"additionalProperties": false
+	}`, string(data))
+}
+
+func TestArgumentCoercion(t *testing.T) {
+	type Filter struct {
+		MinScore float64 `json:"min_score"`
+	}
+	type Input struct {
+		Limit   int               `json:"limit"`
+		Page    *uint             `json:"page"`
+		Strict  bool              `json:"strict"`
+		Tags    []string          `json:"tags"`
+		IDs     []int64           `json:"ids"`
+		Filter  *Filter           `json:"filter"`
+		Weights map[string]int    `json:"weights"`
+		Query   string            `json:"query"`
+		Extra   map[string]string `json:"extra"`
+	}
+	var got Input
+	fn := func(ctx context.Context, input Input) (string, error) {
+		got = input
+		return "ok", nil
+	}
+	ctx := context.Background()
+	args := `{"limit":"42","page":" 2 ","strict":"True","tags":["1"],"ids":["7",8],"filter":{"min_score":"0.5"},` +
+		`"weights":{"a":"3"},"query":"10","extra":{"k":"true"}}`
+
+	tl, err := InferTool("search", "search", fn)
+	assert.NoError(t, err)
+	_, err = tl.InvokableRun(ctx, args)
+	assert.Error(t, err)
+
+	tl, err = InferTool("search", "search", fn, WithArgumentCoercion(true))
+	assert.NoError(t, err)
+	_, err = tl.InvokableRun(ctx, args)
+	assert.NoError(t, err)
+	page := uint(2)
+	assert.Equal(t, Input{
+		Limit:   42,
+		Page:    &page,
+		Strict:  true,
+		Tags:    []string{"1"},
+		IDs:     []int64{7, 8},
+		Filter:  &Filter{MinScore: 0.5},
+		Weights: map[string]int{"a": 3},
+		Query:   "10",
+		Extra:   map[string]string{"k": "true"},
+	}, got)
+
+	t.Run("not coercible", func(t *testing.T) {
+		_, err := tl.InvokableRun(ctx, `{"limit":"many"}`)
+		assert.Error(t, err)
+		_, err = tl.InvokableRun(ctx, `{"strict":"yes"}`)
+		assert.Error(t, err)
+	})
+
+	t.Run("streamable", func(t *testing.T) {
+		st, err := InferStreamTool("search", "search", func(ctx context.Context, input Input) (*schema.StreamReader[string], error) {
+			return schema.StreamReaderFromArray([]string{fmt.Sprint(input.Limit, input.Strict)}), nil
+		}, WithArgumentCoercion(true))
+		assert.NoError(t, err)
+		sr, err := st.StreamableRun(ctx, `{"limit":"3","strict":"false"}`)
+		assert.NoError(t, err)
+		defer sr.Close()
+		chunk, err := sr.Recv()
+		assert.NoError(t, err)
+		assert.Equal(t, "3 false", chunk)
+	})
+}
+
+func TestJSONUseNumber(t *testing.T) {
+	type Input struct {
+		ID     any            `json:"id"`
+		Filter map[string]any `json:"filter"`
+	}
+	fn := func(ctx context.Context, input Input) (*Input, error) {
+		return &input, nil
+	}
+	ctx := context.Background()
+	args := `{"id":1234567890123456789,"filter":{"owner_id":9223372036854775807}}`
+
+	tl, err := InferTool("get", "get", fn)
+	assert.NoError(t, err)
+	out, err := tl.InvokableRun(ctx, args)
+	assert.NoError(t, err)
+	assert.NotEqual(t, args, out)
+
+	tl, err = InferTool("get", "get", fn, WithJSONUseNumber(true))
+	assert.NoError(t, err)
+	out, err = tl.InvokableRun(ctx, args)
+	assert.NoError(t, err)
+	assert.Equal(t, args, out)
+
+	tl, err = InferTool("get", "get", fn, WithJSONUseNumber(true), WithArgumentCoercion(true))
+	assert.NoError(t, err)
+	out, err = tl.InvokableRun(ctx, args)
+	assert.NoError(t, err)
+	assert.Equal(t, args, out)
+}
+
+func TestToolResultMeta(t *testing.T) {
+	type input struct {
+		Query string `json:"query"`
+	}
+	ctx := context.Background()
+	info := &schema.ToolInfo{Name: "search"}
+	arg := &schema.ToolArgument{Text: `{"query":"eino"}`}
+	text := func(s string) *schema.ToolResult {
+		return &schema.ToolResult{Parts: []schema.ToolOutputPart{{Type: schema.ToolPartTypeText, Text: s}}}
+	}
+
+	t.Run("invokable", func(t *testing.T) {
+		result, err := NewEnhancedTool(info, func(ctx context.Context, in input) (*schema.ToolResult, error) {
+			time.Sleep(time.Millisecond)
+			return text("ok"), nil
+		}).InvokableRun(ctx, arg)
+		assert.NoError(t, err)
+		assert.NotNil(t, result.Meta)
+		assert.True(t, result.Meta.Success)
+		assert.Greater(t, result.Meta.Duration, time.Duration(0))
+
+		result, err = NewEnhancedTool(info, func(ctx context.Context, in input) (*schema.ToolResult, error) {
+			return nil, errors.New("quota exceeded")
+		}, WithErrorAsResult(func(err error) *schema.ToolResult {
+			r := text(err.Error())
+			r.Meta = &schema.ToolResultMeta{ErrorCode: "quota"}
+			return r
+		})).InvokableRun(ctx, arg)
+		assert.NoError(t, err)
+		assert.False(t, result.Meta.Success)
+		assert.Equal(t, "quota", result.Meta.ErrorCode)
+	})
+
+	t.Run("streamable", func(t *testing.T) {
+		sr, err := NewEnhancedStreamTool(info, func(ctx context.Context, in input) (*schema.StreamReader[*schema.ToolResult], error) {
+			time.Sleep(time.Millisecond)
+			return schema.StreamReaderFromArray([]*schema.ToolResult{text("a"), text("b")}), nil
+		}, WithResultMeta()).StreamableRun(ctx, arg)
+		assert.NoError(t, err)
+
+		var chunks []*schema.ToolResult
+		for {
+			chunk, err := sr.Recv()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			assert.NoError(t, err)
+			chunks = append(chunks, chunk)
+		}
+		assert.Len(t, chunks, 3)
+		assert.Nil(t, chunks[0].Meta)
+		assert.Nil(t, chunks[1].Meta)
+		assert.Empty(t, chunks[2].Parts)
+
+		result, err := schema.ConcatToolResults(chunks)
+		assert.NoError(t, err)
+		assert.Len(t, result.Parts, 2)
+		assert.True(t, result.Meta.Success)
+		assert.Greater(t, result.Meta.Duration, time.Duration(0))
+	})
+
+	t.Run("streamable forwards chunks immediately", func(t *testing.T) {
+		release := make(chan struct{})
+		sr, err := NewEnhancedStreamTool(info, func(ctx context.Context, in input) (*schema.StreamReader[*schema.ToolResult], error) {
+			out, sw := schema.Pipe[*schema.ToolResult](0)
+			go func() {
+				defer sw.Close()
+				sw.Send(text("first"), nil)
+				<-release
+				sw.Send(text("second"), nil)
+			}()
+			return out, nil
+		}, WithResultMeta()).StreamableRun(ctx, arg)
+		assert.NoError(t, err)
+
+		// "first" arrives while the tool is still blocked before sending the next chunk
+		chunk, err := sr.Recv()
+		assert.NoError(t, err)
+		assert.Equal(t, "first", chunk.Parts[0].Text)
+		close(release)
+
+		chunks, err := schema.CollectBestEffort(sr)
+		assert.NoError(t, err)
+		assert.Len(t, chunks, 2)
+		assert.True(t, chunks[1].Meta.Success)
+	})
+
+	t.Run("streamable fails mid-way", func(t *testing.T) {
+		errBroken := errors.New("broken stream")
+		sr, err := NewEnhancedStreamTool(info, func(ctx context.Context, in input) (*schema.StreamReader[*schema.ToolResult], error) {
+			out, sw := schema.Pipe[*schema.ToolResult](2)
+			sw.Send(text("a"), nil)
+			sw.Send(nil, errBroken)
+			sw.Close()
+			return out, nil
+		}, WithResultMeta()).StreamableRun(ctx, arg)
+		assert.NoError(t, err)
+
+		// the error is forwarded without a status chunk ahead of it
+		chunks, err := schema.CollectBestEffort(sr)
+		assert.ErrorIs(t, err, errBroken)
+		assert.Len(t, chunks, 1)
+		assert.Equal(t, "a", chunks[0].Parts[0].Text)
+		assert.Nil(t, chunks[0].Meta)
+	})
+
+	t.Run("streamable without WithResultMeta", func(t *testing.T) {
+		in := schema.StreamReaderFromArray([]*schema.ToolResult{text("a")})
+		sr, err := NewEnhancedStreamTool(info, func(ctx context.Context, _ input) (*schema.StreamReader[*schema.ToolResult], error) {
+			return in, nil
+		}).StreamableRun(ctx, arg)
+		assert.NoError(t, err)
+		// the stream of the tool function is returned as is
+		assert.Same(t, in, sr)
+	})
+}
@@ -0,0 +1,93 @@
+/*
+ * Copyright 2026 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+// ApplyJSONPatchStream drains the stream of a tool streaming JSON merge patches (RFC 7386) and closes it,
+// applying each patch in order to the document starting from {}, and returns the final document.
+// A member set to null in a patch deletes it, a nested object is merged recursively, and any other value replaces the target.
+// The chunks containing only whitespace are skipped. Numbers are kept without precision loss, and the keys are sorted in the output.
+// e.g. applying {"a":1,"b":{"c":2}} and then {"b":{"c":null,"d":3}} results in {"a":1,"b":{"d":3}}.
+func ApplyJSONPatchStream(sr *schema.StreamReader[string]) (json.RawMessage, error) {
+	defer sr.Close()
+
+	var doc any = map[string]any{}
+	for idx := 0; ; idx++ {
+		chunk, err := sr.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if strings.TrimSpace(chunk) == "" {
+			continue
+		}
+
+		var patch any
+		dec := json.NewDecoder(strings.NewReader(chunk))
+		dec.UseNumber()
+		if err = dec.Decode(&patch); err != nil {
+			return nil, fmt.Errorf("patch %d is not valid json: %w", idx, err)
+		}
+		if dec.More() {
+			return nil, fmt.Errorf("patch %d is not valid json: unexpected data after the value", idx)
+		}
+
+		doc = applyJSONMergePatch(doc, patch)
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(doc); err != nil {
+		return nil, err
+	}
+
+	return bytes.TrimRight(buf.Bytes(), "\n"), nil
+}
+
+// applyJSONMergePatch applies patch to target as defined by RFC 7386, modifying target in place if it is an object.
+func applyJSONMergePatch(target, patch any) any {
+	patchObj, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+
+	targetObj, ok := target.(map[string]any)
+	if !ok {
+		targetObj = map[string]any{}
+	}
+	for k, v := range patchObj {
+		if v == nil {
+			delete(targetObj, k)
+			continue
+		}
+		targetObj[k] = applyJSONMergePatch(targetObj[k], v)
+	}
+
+	return targetObj
+}
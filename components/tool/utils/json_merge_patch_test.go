@@ -0,0 +1,57 @@
+/*
+ * Copyright 2026 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package utils
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+func TestApplyJSONPatchStream(t *testing.T) {
+	doc, err := ApplyJSONPatchStream(schema.StreamReaderFromArray([]string{
+		`{"title":"report","author":{"name":"bob","email":"bob@example.com"},"tags":["a"]}`,
+		` `,
+		`{"author":{"email":null,"id":12345678901234567890},"tags":["a","b"],"status":"done"}`,
+	}))
+	assert.NoError(t, err)
+	assert.Equal(t, json.RawMessage(`{"author":{"id":12345678901234567890,"name":"bob"},"status":"done","tags":["a","b"],"title":"report"}`), doc)
+
+	doc, err = ApplyJSONPatchStream(schema.StreamReaderFromArray([]string{}))
+	assert.NoError(t, err)
+	assert.Equal(t, json.RawMessage(`{}`), doc)
+
+	// a non-object patch replaces the whole document
+	doc, err = ApplyJSONPatchStream(schema.StreamReaderFromArray([]string{`{"a":1}`, `[1,2]`, `{"b":2}`}))
+	assert.NoError(t, err)
+	assert.Equal(t, json.RawMessage(`{"b":2}`), doc)
+
+	_, err = ApplyJSONPatchStream(schema.StreamReaderFromArray([]string{`{"a":1}`, `{"a":`}))
+	assert.ErrorContains(t, err, "patch 1 is not valid json")
+
+	errRecv := errors.New("recv failed")
+	sr, sw := schema.Pipe[string](2)
+	sw.Send(`{"a":1}`, nil)
+	sw.Send("", errRecv)
+	sw.Close()
+	_, err = ApplyJSONPatchStream(sr)
+	assert.ErrorIs(t, err, errRecv)
+}
@@ -0,0 +1,111 @@
+/*
+ * Copyright 2026 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package oteltool traces the tools created by components/tool/utils with OpenTelemetry.
+// It is a separate module, so that eino itself does not depend on OpenTelemetry.
+package oteltool
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/cloudwego/eino/components/tool/utils"
+)
+
+const instrumentationName = "github.com/cloudwego/eino/components/tool/utils"
+
+type options struct {
+	provider trace.TracerProvider
+}
+
+// Option configures the tracer created by NewTracer or WithTracing.
+type Option func(o *options)
+
+// WithTracerProvider sets the provider of the otel tracer, by default the global one returned by otel.GetTracerProvider.
+func WithTracerProvider(provider trace.TracerProvider) Option {
+	return func(o *options) {
+		o.provider = provider
+	}
+}
+
+// WithTracing makes a tool created by components/tool/utils run each invocation in an otel span,
+// which is a child of the span in the context passed to the tool, see utils.WithTracing.
+// e.g.
+//
+//	t, err := utils.InferTool("get_weather", "get weather", getWeather, oteltool.WithTracing())
+func WithTracing(opts ...Option) utils.Option {
+	return utils.WithTracing(NewTracer(opts...))
+}
+
+// NewTracer returns a utils.Tracer backed by an otel tracer.
+func NewTracer(opts ...Option) utils.Tracer {
+	o := &options{}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	return &tracer{provider: o.provider}
+}
+
+type tracer struct {
+	provider trace.TracerProvider
+}
+
+func (t *tracer) Start(ctx context.Context, spanName string) (context.Context, utils.Span) {
+	provider := t.provider
+	if provider == nil {
+		// resolved on each call, so that a global provider registered after the tool is created takes effect
+		provider = otel.GetTracerProvider()
+	}
+
+	ctx, span := provider.Tracer(instrumentationName).Start(ctx, spanName)
+	return ctx, &otelSpan{span: span}
+}
+
+type otelSpan struct {
+	span trace.Span
+}
+
+func (s *otelSpan) SetAttribute(key string, value any) {
+	switch v := value.(type) {
+	case string:
+		s.span.SetAttributes(attribute.String(key, v))
+	case int:
+		s.span.SetAttributes(attribute.Int(key, v))
+	case int64:
+		s.span.SetAttributes(attribute.Int64(key, v))
+	case bool:
+		s.span.SetAttributes(attribute.Bool(key, v))
+	case float64:
+		s.span.SetAttributes(attribute.Float64(key, v))
+	default:
+		s.span.SetAttributes(attribute.String(key, fmt.Sprint(v)))
+	}
+}
+
+func (s *otelSpan) RecordError(err error) {
+	s.span.RecordError(err)
+	s.span.SetStatus(codes.Error, err.Error())
+}
+
+func (s *otelSpan) End() {
+	s.span.End()
+}
@@ -0,0 +1,115 @@
+/*
+ * Copyright 2026 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package oteltool
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/cloudwego/eino/components/tool/utils"
+	"github.com/cloudwego/eino/schema"
+)
+
+func TestWithTracing(t *testing.T) {
+	type Input struct {
+		City string `json:"city"`
+	}
+
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+
+	var toolSpanCtx trace.SpanContext
+	tl, err := utils.InferTool("get_weather", "get weather", func(ctx context.Context, input Input) (string, error) {
+		toolSpanCtx = trace.SpanContextFromContext(ctx)
+		if input.City == "" {
+			return "", errors.New("city is required")
+		}
+		return "sunny in " + input.City, nil
+	}, WithTracing(WithTracerProvider(provider)))
+	assert.NoError(t, err)
+
+	ctx, parent := provider.Tracer("test").Start(context.Background(), "agent")
+	out, err := tl.InvokableRun(ctx, `{"city":"Paris"}`)
+	assert.NoError(t, err)
+	assert.Equal(t, "sunny in Paris", out)
+	_, err = tl.InvokableRun(ctx, `{}`)
+	assert.Error(t, err)
+	parent.End()
+
+	spans := exporter.GetSpans()
+	assert.Len(t, spans, 3)
+
+	succeeded := spans[0]
+	assert.Equal(t, "get_weather", succeeded.Name)
+	assert.Equal(t, parent.SpanContext().SpanID(), succeeded.Parent.SpanID())
+	assert.Equal(t, parent.SpanContext().TraceID(), succeeded.SpanContext.TraceID())
+	assert.ElementsMatch(t, []attribute.KeyValue{
+		attribute.String(utils.SpanAttrToolName, "get_weather"),
+		attribute.Int(utils.SpanAttrArgumentsSize, len(`{"city":"Paris"}`)),
+		attribute.Int(utils.SpanAttrOutputSize, len("sunny in Paris")),
+	}, succeeded.Attributes)
+	assert.Equal(t, codes.Unset, succeeded.Status.Code)
+
+	failed := spans[1]
+	// the context passed to the tool function carries the tool span
+	assert.Equal(t, failed.SpanContext.SpanID(), toolSpanCtx.SpanID())
+	assert.Equal(t, codes.Error, failed.Status.Code)
+	assert.Contains(t, failed.Status.Description, "city is required")
+	assert.Len(t, failed.Events, 1)
+	assert.Equal(t, "exception", failed.Events[0].Name)
+
+	assert.Equal(t, "agent", spans[2].Name)
+}
+
+func TestWithTracingStream(t *testing.T) {
+	type Input struct {
+		City string `json:"city"`
+	}
+
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+
+	tl, err := utils.InferStreamTool("get_weather", "get weather", func(ctx context.Context, input Input) (*schema.StreamReader[string], error) {
+		return schema.StreamReaderFromArray([]string{"sunny", " in ", input.City}), nil
+	}, WithTracing(WithTracerProvider(provider)))
+	assert.NoError(t, err)
+
+	sr, err := tl.StreamableRun(context.Background(), `{"city":"Paris"}`)
+	assert.NoError(t, err)
+	// the span ends with the stream
+	assert.Empty(t, exporter.GetSpans())
+	for {
+		_, err = sr.Recv()
+		if err != nil {
+			break
+		}
+	}
+	assert.ErrorIs(t, err, io.EOF)
+
+	spans := exporter.GetSpans()
+	assert.Len(t, spans, 1)
+	assert.Contains(t, spans[0].Attributes, attribute.Int(utils.SpanAttrOutputSize, len("sunny in Paris")))
+}
@@ -0,0 +1,89 @@
+/*
+ * Copyright 2026 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package utils
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"runtime/debug"
+	"strings"
+
+	"github.com/cloudwego/eino/internal/safe"
+	"github.com/cloudwego/eino/schema"
+)
+
+// OutputChecksumFramePrefix is the prefix of the terminal frame appended by WithOutputChecksum,
+// which is followed by the hex encoded SHA-256 of all preceding output frames concatenated.
+const OutputChecksumFramePrefix = "\n[sha256:"
+
+const outputChecksumFrameSuffix = "]"
+
+// SplitOutputChecksum splits the concatenated output of a streamable tool created with WithOutputChecksum
+// into the content and the checksum carried by the terminal frame.
+// ok reports whether the terminal frame is present and the checksum matches the content.
+func SplitOutputChecksum(output string) (content string, checksum string, ok bool) {
+	idx := strings.LastIndex(output, OutputChecksumFramePrefix)
+	if idx < 0 || !strings.HasSuffix(output, outputChecksumFrameSuffix) {
+		return output, "", false
+	}
+
+	content = output[:idx]
+	checksum = output[idx+len(OutputChecksumFramePrefix) : len(output)-len(outputChecksumFrameSuffix)]
+	sum := sha256.Sum256([]byte(content))
+
+	return content, checksum, hex.EncodeToString(sum[:]) == checksum
+}
+
+func appendOutputChecksum(sr *schema.StreamReader[string]) *schema.StreamReader[string] {
+	outSR, sw := schema.Pipe[string](0)
+
+	go func() {
+		defer func() {
+			panicErr := recover()
+			if panicErr != nil {
+				_ = sw.Send("", safe.NewPanicErr(panicErr, debug.Stack()))
+			}
+
+			sw.Close()
+			sr.Close()
+		}()
+
+		h := sha256.New()
+		for {
+			chunk, err := sr.Recv()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				// the checksum is meaningless once a frame fails, so it is not appended
+				_ = sw.Send("", err)
+				return
+			}
+
+			_, _ = h.Write([]byte(chunk))
+			if sw.Send(chunk, nil) {
+				return
+			}
+		}
+
+		_ = sw.Send(OutputChecksumFramePrefix+hex.EncodeToString(h.Sum(nil))+outputChecksumFrameSuffix, nil)
+	}()
+
+	return outSR
+}
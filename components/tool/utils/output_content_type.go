@@ -0,0 +1,81 @@
+/*
+ * Copyright 2026 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// OutputContentType is the content type the output of a tool is rendered into, see WithOutputContentType.
+type OutputContentType string
+
+const (
+	// OutputContentTypeJSON renders the output as JSON, which is the default.
+	OutputContentTypeJSON OutputContentType = "json"
+	// OutputContentTypeText renders the output as plain text, by its String method if it implements fmt.Stringer,
+	// or else by its string value or the string field named 'String' of a struct.
+	OutputContentTypeText OutputContentType = "text"
+	// OutputContentTypeMarkdown renders the output as markdown, by the renderer set with WithMarkdownRenderer.
+	OutputContentTypeMarkdown OutputContentType = "markdown"
+)
+
+func getContentTypeMarshaller(ct OutputContentType, markdownRenderer MarshalOutput) MarshalOutput {
+	switch ct {
+	case OutputContentTypeJSON:
+		return nil
+	case OutputContentTypeText:
+		return func(_ context.Context, output any) (string, error) {
+			return renderText(output)
+		}
+	case OutputContentTypeMarkdown:
+		if markdownRenderer == nil {
+			return func(_ context.Context, _ any) (string, error) {
+				return "", errors.New("markdown renderer is required for markdown output, set it by WithMarkdownRenderer")
+			}
+		}
+		return markdownRenderer
+	default:
+		return func(_ context.Context, _ any) (string, error) {
+			return "", fmt.Errorf("unsupported output content type: %s", ct)
+		}
+	}
+}
+
+func renderText(output any) (string, error) {
+	if s, ok := output.(fmt.Stringer); ok {
+		return s.String(), nil
+	}
+
+	rv := reflect.ValueOf(output)
+	for rv.Kind() == reflect.Ptr && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+
+	switch rv.Kind() {
+	case reflect.String:
+		return rv.String(), nil
+	case reflect.Struct:
+		if f := rv.FieldByName("String"); f.IsValid() && f.Kind() == reflect.String {
+			return f.String(), nil
+		}
+	}
+
+	return "", fmt.Errorf("output of type %T cannot be rendered as text, it should implement fmt.Stringer or have a string field named 'String'", output)
+}
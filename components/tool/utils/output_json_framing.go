@@ -0,0 +1,129 @@
+/*
+ * Copyright 2026 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package utils
+
+import (
+	"fmt"
+	"io"
+	"runtime/debug"
+	"strings"
+
+	"github.com/cloudwego/eino/internal/safe"
+	"github.com/cloudwego/eino/schema"
+)
+
+// jsonFramer splits concatenated JSON objects and arrays into complete values, tracking the nesting outside of strings.
+type jsonFramer struct {
+	buf      strings.Builder
+	depth    int
+	inString bool
+	escaped  bool
+}
+
+// feed consumes chunk and returns the values completed by it.
+func (f *jsonFramer) feed(chunk string) ([]string, error) {
+	var values []string
+	for i := 0; i < len(chunk); i++ {
+		c := chunk[i]
+
+		if f.depth == 0 {
+			switch c {
+			case ' ', '\t', '\n', '\r':
+				// whitespace between top-level values
+				continue
+			case '{', '[':
+			default:
+				return values, fmt.Errorf("unexpected character %q outside of JSON object or array", c)
+			}
+		}
+
+		f.buf.WriteByte(c)
+
+		if f.inString {
+			switch {
+			case f.escaped:
+				f.escaped = false
+			case c == '\\':
+				f.escaped = true
+			case c == '"':
+				f.inString = false
+			}
+			continue
+		}
+
+		switch c {
+		case '"':
+			f.inString = true
+		case '{', '[':
+			f.depth++
+		case '}', ']':
+			f.depth--
+			if f.depth == 0 {
+				values = append(values, f.buf.String())
+				f.buf.Reset()
+			}
+		}
+	}
+
+	return values, nil
+}
+
+func frameJSONObjects(sr *schema.StreamReader[string]) *schema.StreamReader[string] {
+	outSR, sw := schema.Pipe[string](0)
+
+	go func() {
+		defer func() {
+			panicErr := recover()
+			if panicErr != nil {
+				_ = sw.Send("", safe.NewPanicErr(panicErr, debug.Stack()))
+			}
+
+			sw.Close()
+			sr.Close()
+		}()
+
+		framer := &jsonFramer{}
+		for {
+			chunk, err := sr.Recv()
+			if err == io.EOF {
+				if framer.buf.Len() > 0 {
+					_ = sw.Send("", fmt.Errorf("incomplete JSON value at the end of tool output: %s", framer.buf.String()))
+				}
+				return
+			}
+			if err != nil {
+				if sw.Send("", err) {
+					return
+				}
+				continue
+			}
+
+			values, err := framer.feed(chunk)
+			for _, v := range values {
+				if sw.Send(v, nil) {
+					return
+				}
+			}
+			if err != nil {
+				_ = sw.Send("", fmt.Errorf("failed to frame tool output as JSON: %w", err))
+				return
+			}
+		}
+	}()
+
+	return outSR
+}
@@ -0,0 +1,193 @@
+/*
+ * Copyright 2026 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package utils
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/bytedance/sonic"
+)
+
+type jsonPathStepKind int
+
+const (
+	jsonPathStepKey jsonPathStepKind = iota
+	jsonPathStepIndex
+	jsonPathStepWildcard
+)
+
+type jsonPathStep struct {
+	kind  jsonPathStepKind
+	key   string
+	index int
+}
+
+// jsonPath is a compiled JSONPath expression, supporting the subset of
+// root '$', child '.name' or ['name'], array index '[0]' (negative counts from the end) and wildcard '.*' or '[*]'.
+type jsonPath struct {
+	expr     string
+	steps    []jsonPathStep
+	wildcard bool
+}
+
+func compileJSONPath(expr string) (*jsonPath, error) {
+	p := &jsonPath{expr: expr}
+
+	s := strings.TrimSpace(expr)
+	if !strings.HasPrefix(s, "$") {
+		return nil, fmt.Errorf("invalid json path %q: must start with '$'", expr)
+	}
+	s = s[1:]
+
+	for len(s) > 0 {
+		switch s[0] {
+		case '.':
+			s = s[1:]
+			end := strings.IndexAny(s, ".[")
+			if end < 0 {
+				end = len(s)
+			}
+			name := s[:end]
+			if name == "" {
+				return nil, fmt.Errorf("invalid json path %q: empty field name", expr)
+			}
+			if name == "*" {
+				p.steps = append(p.steps, jsonPathStep{kind: jsonPathStepWildcard})
+				p.wildcard = true
+			} else {
+				p.steps = append(p.steps, jsonPathStep{kind: jsonPathStepKey, key: name})
+			}
+			s = s[end:]
+		case '[':
+			end := strings.IndexByte(s, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("invalid json path %q: missing ']'", expr)
+			}
+			inner := strings.TrimSpace(s[1:end])
+			s = s[end+1:]
+
+			switch {
+			case inner == "*":
+				p.steps = append(p.steps, jsonPathStep{kind: jsonPathStepWildcard})
+				p.wildcard = true
+			case len(inner) >= 2 && (inner[0] == '\'' || inner[0] == '"') && inner[len(inner)-1] == inner[0]:
+				p.steps = append(p.steps, jsonPathStep{kind: jsonPathStepKey, key: inner[1 : len(inner)-1]})
+			default:
+				idx, err := strconv.Atoi(inner)
+				if err != nil {
+					return nil, fmt.Errorf("invalid json path %q: invalid index %q", expr, inner)
+				}
+				p.steps = append(p.steps, jsonPathStep{kind: jsonPathStepIndex, index: idx})
+			}
+		default:
+			return nil, fmt.Errorf("invalid json path %q: unexpected character %q", expr, s[0])
+		}
+	}
+
+	return p, nil
+}
+
+// apply evaluates the path against a JSON document. A path containing a wildcard returns the array of all matches,
+// otherwise the single matched value is returned and a missing value is an error.
+func (p *jsonPath) apply(data string) (string, error) {
+	var root any
+	if err := sonic.UnmarshalString(data, &root); err != nil {
+		return "", fmt.Errorf("output is not valid json: %w", err)
+	}
+
+	matches := []any{root}
+	for _, step := range p.steps {
+		next := make([]any, 0, len(matches))
+		for _, m := range matches {
+			switch step.kind {
+			case jsonPathStepKey:
+				obj, ok := m.(map[string]any)
+				if !ok {
+					if p.wildcard {
+						continue
+					}
+					return "", fmt.Errorf("json path %q: field %q applied to non-object", p.expr, step.key)
+				}
+				v, ok := obj[step.key]
+				if !ok {
+					if p.wildcard {
+						continue
+					}
+					return "", fmt.Errorf("json path %q: field %q not found", p.expr, step.key)
+				}
+				next = append(next, v)
+			case jsonPathStepIndex:
+				arr, ok := m.([]any)
+				if !ok {
+					if p.wildcard {
+						continue
+					}
+					return "", fmt.Errorf("json path %q: index %d applied to non-array", p.expr, step.index)
+				}
+				idx := step.index
+				if idx < 0 {
+					idx += len(arr)
+				}
+				if idx < 0 || idx >= len(arr) {
+					if p.wildcard {
+						continue
+					}
+					return "", fmt.Errorf("json path %q: index %d out of range", p.expr, step.index)
+				}
+				next = append(next, arr[idx])
+			case jsonPathStepWildcard:
+				switch v := m.(type) {
+				case []any:
+					next = append(next, v...)
+				case map[string]any:
+					keys := make([]string, 0, len(v))
+					for k := range v {
+						keys = append(keys, k)
+					}
+					sort.Strings(keys)
+					for _, k := range keys {
+						next = append(next, v[k])
+					}
+				}
+			}
+		}
+		matches = next
+	}
+
+	var result any = matches
+	if !p.wildcard {
+		result = matches[0]
+	}
+
+	return sonic.MarshalString(result)
+}
+
+func withOutputJSONPath(m MarshalOutput, p *jsonPath) MarshalOutput {
+	m = baseMarshal(m)
+	return func(ctx context.Context, output any) (string, error) {
+		out, err := m(ctx, output)
+		if err != nil {
+			return "", err
+		}
+
+		return p.apply(out)
+	}
+}
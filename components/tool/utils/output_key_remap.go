@@ -0,0 +1,69 @@
+/*
+ * Copyright 2026 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	orderedmap "github.com/wk8/go-ordered-map/v2"
+)
+
+// remapOutputKeys renames the top-level keys of a JSON object output, keeping the order of the keys.
+// An output which is not a JSON object is returned as is.
+func remapOutputKeys(out string, remap map[string]string) (string, error) {
+	if !strings.HasPrefix(strings.TrimSpace(out), "{") {
+		return out, nil
+	}
+
+	obj := orderedmap.New[string, json.RawMessage]()
+	if err := json.Unmarshal([]byte(out), obj); err != nil {
+		return "", fmt.Errorf("output is not valid json: %w", err)
+	}
+
+	remapped := orderedmap.New[string, json.RawMessage](obj.Len())
+	for pair := obj.Oldest(); pair != nil; pair = pair.Next() {
+		key := pair.Key
+		if newKey, ok := remap[key]; ok {
+			key = newKey
+		}
+		if _, present := remapped.Set(key, pair.Value); present {
+			return "", fmt.Errorf("output key %q is duplicated after remapping", key)
+		}
+	}
+
+	data, err := json.Marshal(remapped)
+	if err != nil {
+		return "", err
+	}
+
+	return string(data), nil
+}
+
+func withOutputKeyRemap(m MarshalOutput, remap map[string]string) MarshalOutput {
+	m = baseMarshal(m)
+	return func(ctx context.Context, output any) (string, error) {
+		out, err := m(ctx, output)
+		if err != nil {
+			return "", err
+		}
+
+		return remapOutputKeys(out, remap)
+	}
+}
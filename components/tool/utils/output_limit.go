@@ -0,0 +1,75 @@
+/*
+ * Copyright 2026 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package utils
+
+import (
+	"fmt"
+	"io"
+	"runtime/debug"
+
+	"github.com/cloudwego/eino/internal/safe"
+	"github.com/cloudwego/eino/schema"
+)
+
+// OutputTruncatedMarker is the terminal frame emitted when the output of a streamable tool is capped by WithMaxOutputTokens.
+const OutputTruncatedMarker = "\n[output truncated]"
+
+func capOutputTokens(sr *schema.StreamReader[string], counter schema.TokenCounter, maxTokens int) *schema.StreamReader[string] {
+	outSR, sw := schema.Pipe[string](0)
+
+	go func() {
+		defer func() {
+			panicErr := recover()
+			if panicErr != nil {
+				_ = sw.Send("", safe.NewPanicErr(panicErr, debug.Stack()))
+			}
+
+			sw.Close()
+			sr.Close()
+		}()
+
+		total := 0
+		for {
+			chunk, err := sr.Recv()
+			if err == io.EOF {
+				return
+			}
+			if err != nil {
+				_ = sw.Send("", err)
+				return
+			}
+
+			n, err := counter(&schema.Message{Role: schema.Tool, Content: chunk})
+			if err != nil {
+				_ = sw.Send("", fmt.Errorf("failed to count tokens of tool output: %w", err))
+				return
+			}
+
+			if total+n > maxTokens {
+				_ = sw.Send(OutputTruncatedMarker, nil)
+				return
+			}
+			total += n
+
+			if sw.Send(chunk, nil) {
+				return
+			}
+		}
+	}()
+
+	return outSR
+}
@@ -0,0 +1,410 @@
+/*
+ * Copyright 2026 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/eino-contrib/jsonschema"
+)
+
+// OutputSchemaError is returned by a tool created with WithOutputSchema when its output does not match the schema.
+type OutputSchemaError struct {
+	// Path is the location of the mismatched value in the output, e.g. "$.items[0].title".
+	Path   string
+	Reason string
+}
+
+func (e *OutputSchemaError) Error() string {
+	return fmt.Sprintf("output does not match schema at %s: %s", e.Path, e.Reason)
+}
+
+// validateOutput checks the JSON output against the root schema, supporting the keywords
+// type, enum, const, the numeric, string, array and object constraints, allOf, anyOf, oneOf, not, and $ref to the local $defs.
+// Other keywords such as format are ignored.
+func (sv *schemaValidator) validateOutput(out string) error {
+	var v any
+	if err := json.Unmarshal([]byte(out), &v); err != nil {
+		return fmt.Errorf("output is not valid json: %w", err)
+	}
+
+	return sv.validate(sv.root, v, "$")
+}
+
+func withOutputSchema(m MarshalOutput, sc *jsonschema.Schema) MarshalOutput {
+	sv := &schemaValidator{root: sc}
+	m = baseMarshal(m)
+	return func(ctx context.Context, output any) (string, error) {
+		out, err := m(ctx, output)
+		if err != nil {
+			return "", err
+		}
+
+		if err = sv.validateOutput(out); err != nil {
+			return "", err
+		}
+
+		return out, nil
+	}
+}
+
+// schemaValidator is shared by the runs of a tool, so it's safe for concurrent use.
+type schemaValidator struct {
+	root *jsonschema.Schema
+	// patterns caches the compiled regexp of each pattern in the schema, keyed by the pattern.
+	patterns sync.Map
+}
+
+func (sv *schemaValidator) validate(sc *jsonschema.Schema, v any, path string) error {
+	if sc == nil || sc == jsonschema.TrueSchema {
+		return nil
+	}
+	if isFalseSchema(sc) {
+		return &OutputSchemaError{Path: path, Reason: "no value is allowed"}
+	}
+
+	if sc.Ref != "" {
+		ref, err := sv.resolveRef(sc.Ref)
+		if err != nil {
+			return &OutputSchemaError{Path: path, Reason: err.Error()}
+		}
+		if err = sv.validate(ref, v, path); err != nil {
+			return err
+		}
+	}
+
+	if err := sv.validateType(sc, v, path); err != nil {
+		return err
+	}
+
+	if len(sc.Enum) > 0 {
+		matched := false
+		for _, e := range sc.Enum {
+			if jsonEqual(e, v) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return &OutputSchemaError{Path: path, Reason: fmt.Sprintf("value %s is not one of the enum values", jsonText(v))}
+		}
+	}
+	if sc.Const != nil && !jsonEqual(sc.Const, v) {
+		return &OutputSchemaError{Path: path, Reason: fmt.Sprintf("value %s does not equal the const value %s", jsonText(v), jsonText(sc.Const))}
+	}
+
+	var err error
+	switch tv := v.(type) {
+	case float64:
+		err = sv.validateNumber(sc, tv, path)
+	case string:
+		err = sv.validateString(sc, tv, path)
+	case []any:
+		err = sv.validateArray(sc, tv, path)
+	case map[string]any:
+		err = sv.validateObject(sc, tv, path)
+	}
+	if err != nil {
+		return err
+	}
+
+	return sv.validateCombinators(sc, v, path)
+}
+
+func (sv *schemaValidator) resolveRef(ref string) (*jsonschema.Schema, error) {
+	for _, prefix := range []string{"#/$defs/", "#/definitions/"} {
+		if strings.HasPrefix(ref, prefix) {
+			if def, ok := sv.root.Definitions[strings.TrimPrefix(ref, prefix)]; ok {
+				return def, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("unresolvable $ref %q", ref)
+}
+
+func (sv *schemaValidator) validateType(sc *jsonschema.Schema, v any, path string) error {
+	types := sc.TypeEnhanced
+	if sc.Type != "" {
+		types = []string{sc.Type}
+	}
+	if len(types) == 0 {
+		return nil
+	}
+
+	for _, t := range types {
+		if matchJSONType(t, v) {
+			return nil
+		}
+	}
+	return &OutputSchemaError{Path: path, Reason: fmt.Sprintf("expected type %s, got %s", strings.Join(types, " or "), jsonTypeOf(v))}
+}
+
+func (sv *schemaValidator) validateNumber(sc *jsonschema.Schema, n float64, path string) error {
+	check := func(bound json.Number, fails func(n, b float64) bool, desc string) error {
+		if bound == "" {
+			return nil
+		}
+		b, err := bound.Float64()
+		if err != nil {
+			return &OutputSchemaError{Path: path, Reason: fmt.Sprintf("invalid %s %q in schema", desc, bound)}
+		}
+		if fails(n, b) {
+			return &OutputSchemaError{Path: path, Reason: fmt.Sprintf("value %v violates %s %v", n, desc, b)}
+		}
+		return nil
+	}
+
+	if err := check(sc.Minimum, func(n, b float64) bool { return n < b }, "minimum"); err != nil {
+		return err
+	}
+	if err := check(sc.Maximum, func(n, b float64) bool { return n > b }, "maximum"); err != nil {
+		return err
+	}
+	if err := check(sc.ExclusiveMinimum, func(n, b float64) bool { return n <= b }, "exclusiveMinimum"); err != nil {
+		return err
+	}
+	if err := check(sc.ExclusiveMaximum, func(n, b float64) bool { return n >= b }, "exclusiveMaximum"); err != nil {
+		return err
+	}
+	return check(sc.MultipleOf, func(n, b float64) bool {
+		q := n / b
+		return b > 0 && math.Abs(q-math.Round(q)) > 1e-9
+	}, "multipleOf")
+}
+
+func (sv *schemaValidator) validateString(sc *jsonschema.Schema, s string, path string) error {
+	length := uint64(utf8.RuneCountInString(s))
+	if sc.MinLength != nil && length < *sc.MinLength {
+		return &OutputSchemaError{Path: path, Reason: fmt.Sprintf("length %d is less than minLength %d", length, *sc.MinLength)}
+	}
+	if sc.MaxLength != nil && length > *sc.MaxLength {
+		return &OutputSchemaError{Path: path, Reason: fmt.Sprintf("length %d is greater than maxLength %d", length, *sc.MaxLength)}
+	}
+	if sc.Pattern != "" {
+		re, err := sv.compilePattern(sc.Pattern)
+		if err != nil {
+			return &OutputSchemaError{Path: path, Reason: fmt.Sprintf("invalid pattern %q in schema: %v", sc.Pattern, err)}
+		}
+		if !re.MatchString(s) {
+			return &OutputSchemaError{Path: path, Reason: fmt.Sprintf("value %q does not match pattern %q", s, sc.Pattern)}
+		}
+	}
+	return nil
+}
+
+func (sv *schemaValidator) compilePattern(pattern string) (*regexp.Regexp, error) {
+	if re, ok := sv.patterns.Load(pattern); ok {
+		return re.(*regexp.Regexp), nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	sv.patterns.Store(pattern, re)
+	return re, nil
+}
+
+func (sv *schemaValidator) validateArray(sc *jsonschema.Schema, arr []any, path string) error {
+	n := uint64(len(arr))
+	if sc.MinItems != nil && n < *sc.MinItems {
+		return &OutputSchemaError{Path: path, Reason: fmt.Sprintf("%d items are fewer than minItems %d", n, *sc.MinItems)}
+	}
+	if sc.MaxItems != nil && n > *sc.MaxItems {
+		return &OutputSchemaError{Path: path, Reason: fmt.Sprintf("%d items are more than maxItems %d", n, *sc.MaxItems)}
+	}
+	if sc.UniqueItems {
+		for i := range arr {
+			for j := 0; j < i; j++ {
+				if reflect.DeepEqual(arr[i], arr[j]) {
+					return &OutputSchemaError{Path: path, Reason: fmt.Sprintf("items %d and %d are duplicated", j, i)}
+				}
+			}
+		}
+	}
+
+	for i, item := range arr {
+		itemSchema := sc.Items
+		if i < len(sc.PrefixItems) {
+			itemSchema = sc.PrefixItems[i]
+		}
+		if err := sv.validate(itemSchema, item, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (sv *schemaValidator) validateObject(sc *jsonschema.Schema, obj map[string]any, path string) error {
+	n := uint64(len(obj))
+	if sc.MinProperties != nil && n < *sc.MinProperties {
+		return &OutputSchemaError{Path: path, Reason: fmt.Sprintf("%d properties are fewer than minProperties %d", n, *sc.MinProperties)}
+	}
+	if sc.MaxProperties != nil && n > *sc.MaxProperties {
+		return &OutputSchemaError{Path: path, Reason: fmt.Sprintf("%d properties are more than maxProperties %d", n, *sc.MaxProperties)}
+	}
+	for _, name := range sc.Required {
+		if _, ok := obj[name]; !ok {
+			return &OutputSchemaError{Path: path, Reason: fmt.Sprintf("missing required property %q", name)}
+		}
+	}
+
+	if sc.Properties != nil {
+		for pair := sc.Properties.Oldest(); pair != nil; pair = pair.Next() {
+			if pv, ok := obj[pair.Key]; ok {
+				if err := sv.validate(pair.Value, pv, path+"."+pair.Key); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	if sc.AdditionalProperties != nil {
+		for key, pv := range obj {
+			if sc.Properties != nil {
+				if _, ok := sc.Properties.Get(key); ok {
+					continue
+				}
+			}
+			if isFalseSchema(sc.AdditionalProperties) {
+				return &OutputSchemaError{Path: path, Reason: fmt.Sprintf("additional property %q is not allowed", key)}
+			}
+			if err := sv.validate(sc.AdditionalProperties, pv, path+"."+key); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (sv *schemaValidator) validateCombinators(sc *jsonschema.Schema, v any, path string) error {
+	for _, sub := range sc.AllOf {
+		if err := sv.validate(sub, v, path); err != nil {
+			return err
+		}
+	}
+
+	if len(sc.AnyOf) > 0 {
+		matched := false
+		for _, sub := range sc.AnyOf {
+			if sv.validate(sub, v, path) == nil {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return &OutputSchemaError{Path: path, Reason: "value matches none of anyOf"}
+		}
+	}
+
+	if len(sc.OneOf) > 0 {
+		matched := 0
+		for _, sub := range sc.OneOf {
+			if sv.validate(sub, v, path) == nil {
+				matched++
+			}
+		}
+		if matched != 1 {
+			return &OutputSchemaError{Path: path, Reason: fmt.Sprintf("value matches %d of oneOf, expected exactly 1", matched)}
+		}
+	}
+
+	if sc.Not != nil && sv.validate(sc.Not, v, path) == nil {
+		return &OutputSchemaError{Path: path, Reason: "value matches the not schema"}
+	}
+	return nil
+}
+
+// isFalseSchema reports whether sc is the boolean schema false. A schema unmarshalled from JSON false
+// is a copy of jsonschema.FalseSchema rather than the same pointer, so it's compared by value.
+func isFalseSchema(sc *jsonschema.Schema) bool {
+	return sc == jsonschema.FalseSchema || reflect.DeepEqual(sc, jsonschema.FalseSchema)
+}
+
+func matchJSONType(t string, v any) bool {
+	switch t {
+	case "null":
+		return v == nil
+	case "boolean":
+		_, ok := v.(bool)
+		return ok
+	case "string":
+		_, ok := v.(string)
+		return ok
+	case "number":
+		_, ok := v.(float64)
+		return ok
+	case "integer":
+		n, ok := v.(float64)
+		return ok && n == math.Trunc(n)
+	case "array":
+		_, ok := v.([]any)
+		return ok
+	case "object":
+		_, ok := v.(map[string]any)
+		return ok
+	default:
+		return false
+	}
+}
+
+func jsonTypeOf(v any) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case float64:
+		return "number"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	default:
+		return fmt.Sprintf("%T", v)
+	}
+}
+
+// jsonEqual compares a value from the schema, which can be of any go type, with a decoded JSON value.
+func jsonEqual(schemaValue, v any) bool {
+	data, err := json.Marshal(schemaValue)
+	if err != nil {
+		return false
+	}
+	var normalized any
+	if err = json.Unmarshal(data, &normalized); err != nil {
+		return false
+	}
+	return reflect.DeepEqual(normalized, v)
+}
+
+func jsonText(v any) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprintf("%v", v)
+	}
+	return string(data)
+}
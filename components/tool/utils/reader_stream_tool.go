@@ -0,0 +1,115 @@
+/*
+ * Copyright 2026 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"runtime/debug"
+	"unicode/utf8"
+
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/cloudwego/eino/internal"
+	"github.com/cloudwego/eino/internal/safe"
+	"github.com/cloudwego/eino/schema"
+)
+
+const defaultReaderChunkSize = 4096
+
+// NewReaderStreamTool creates a streamable tool whose output comes from a byte stream, such as the stdout of a subprocess or a file.
+// open is called with the raw arguments on each run, and the returned ReadCloser is read in blocks of up to chunkSize bytes,
+// each emitted as a stream frame. The ReadCloser is closed on EOF, on read error, or when the output stream is closed early.
+// A multi-byte UTF-8 character split across blocks is carried over to the next frame, so every frame is valid text for valid input.
+// A chunkSize <= 0 defaults to 4096.
+func NewReaderStreamTool(info *schema.ToolInfo, open func(ctx context.Context, args string) (io.ReadCloser, error), chunkSize int) tool.StreamableTool {
+	if chunkSize <= 0 {
+		chunkSize = defaultReaderChunkSize
+	}
+
+	return &readerStreamTool{
+		info:      info,
+		open:      open,
+		chunkSize: chunkSize,
+	}
+}
+
+type readerStreamTool struct {
+	info      *schema.ToolInfo
+	open      func(ctx context.Context, args string) (io.ReadCloser, error)
+	chunkSize int
+}
+
+// Info returns the tool info, implement the BaseTool interface.
+func (r *readerStreamTool) Info(ctx context.Context) (*schema.ToolInfo, error) {
+	return r.info, nil
+}
+
+// StreamableRun opens the reader with the given arguments and streams its content, implement the StreamableTool interface.
+func (r *readerStreamTool) StreamableRun(ctx context.Context, argumentsInJSON string, _ ...tool.Option) (*schema.StreamReader[string], error) {
+	rc, err := r.open(ctx, argumentsInJSON)
+	if err != nil {
+		return nil, fmt.Errorf("[ReaderStreamTool] failed to open reader, toolName=%s, err=%w", r.info.Name, err)
+	}
+
+	sr, sw := schema.Pipe[string](0)
+
+	go func() {
+		defer func() {
+			panicErr := recover()
+			if panicErr != nil {
+				_ = sw.Send("", safe.NewPanicErr(panicErr, debug.Stack()))
+			}
+
+			_ = rc.Close()
+			sw.Close()
+		}()
+
+		buf := make([]byte, r.chunkSize+utf8.UTFMax)
+		carry := 0
+		for {
+			if err := ctx.Err(); err != nil {
+				_ = sw.Send("", err)
+				return
+			}
+
+			n, rErr := rc.Read(buf[carry : carry+r.chunkSize])
+			n += carry
+			carry = 0
+
+			// hold back an incomplete trailing rune until the rest of it is read
+			if rErr == nil {
+				carry = internal.IncompleteUTF8SuffixLen(buf[:n])
+			}
+			if n-carry > 0 && sw.Send(string(buf[:n-carry]), nil) {
+				return
+			}
+			copy(buf, buf[n-carry:n])
+
+			if errors.Is(rErr, io.EOF) {
+				return
+			}
+			if rErr != nil {
+				_ = sw.Send("", rErr)
+				return
+			}
+		}
+	}()
+
+	return sr, nil
+}
@@ -0,0 +1,81 @@
+/*
+ * Copyright 2026 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package utils
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+type trackingReadCloser struct {
+	io.Reader
+	closed bool
+}
+
+func (t *trackingReadCloser) Close() error {
+	t.closed = true
+	return nil
+}
+
+func TestNewReaderStreamTool(t *testing.T) {
+	ctx := context.Background()
+	info := &schema.ToolInfo{Name: "cat"}
+
+	t.Run("chunks", func(t *testing.T) {
+		var rc *trackingReadCloser
+		tl := NewReaderStreamTool(info, func(ctx context.Context, args string) (io.ReadCloser, error) {
+			rc = &trackingReadCloser{Reader: strings.NewReader(args)}
+			return rc, nil
+		}, 4)
+
+		input := "hello, 世界!"
+		sr, err := tl.StreamableRun(ctx, input)
+		assert.NoError(t, err)
+
+		var chunks []string
+		for {
+			chunk, err := sr.Recv()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			assert.NoError(t, err)
+			assert.True(t, utf8.ValidString(chunk))
+			chunks = append(chunks, chunk)
+		}
+		assert.Equal(t, input, strings.Join(chunks, ""))
+		assert.Equal(t, []string{"hell", "o, ", "世", "界!"}, chunks)
+		sr.Close()
+		assert.True(t, rc.closed)
+	})
+
+	t.Run("open_error", func(t *testing.T) {
+		tl := NewReaderStreamTool(info, func(ctx context.Context, args string) (io.ReadCloser, error) {
+			return nil, errors.New("no such file")
+		}, 0)
+
+		_, err := tl.StreamableRun(ctx, "")
+		assert.ErrorContains(t, err, "no such file")
+	})
+}
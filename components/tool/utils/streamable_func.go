@@ -19,6 +19,7 @@ package utils
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/bytedance/sonic"
 
@@ -56,6 +57,8 @@ func InferOptionableStreamTool[T, D any](toolName, toolDesc string, s Optionable
 
 // NewStreamTool Create a streaming tool, where the input and output are both in JSON format.
 // convert: convert the stream frame to string that could be concatenated to a string.
+// An invalid option, e.g. a malformed WithOutputJSONPath expression, makes the tool fail on run,
+// while InferStreamTool reports it on construction.
 func NewStreamTool[T, D any](desc *schema.ToolInfo, s StreamFunc[T, D], opts ...Option) tool.StreamableTool {
 	return newOptionableStreamTool(desc,
 		func(ctx context.Context, input T, _ ...tool.Option) (output *schema.StreamReader[D], err error) {
@@ -71,8 +74,19 @@ func newOptionableStreamTool[T, D any](desc *schema.ToolInfo, s OptionableStream
 	return &streamableTool[T, D]{
 		info: desc,
 
-		um: to.um,
-		m:  to.m,
+		um:       getUnmarshalArguments[T](to),
+		m:        to.m,
+		checksum: to.checksum,
+
+		jsonObjectFraming: to.jsonObjectFraming,
+
+		maxOutputTokens: to.maxOutputTokens,
+		tokenCounter:    to.tokenCounter,
+
+		approver: to.approver,
+
+		tracer: to.tracer,
+
 		Fn: s,
 	}
 }
@@ -83,6 +97,17 @@ type streamableTool[T, D any] struct {
 	um UnmarshalArguments
 	m  MarshalOutput
 
+	checksum bool
+
+	jsonObjectFraming bool
+
+	maxOutputTokens int
+	tokenCounter    schema.TokenCounter
+
+	approver ApprovalFunc
+
+	tracer Tracer
+
 	Fn OptionableStreamFunc[T, D]
 }
 
@@ -95,10 +120,24 @@ func (s *streamableTool[T, D]) Info(ctx context.Context) (*schema.ToolInfo, erro
 func (s *streamableTool[T, D]) StreamableRun(ctx context.Context, argumentsInJSON string, opts ...tool.Option) (
 	outStream *schema.StreamReader[string], err error) {
 
+	if s.tracer != nil {
+		var span Span
+		ctx, span = startToolSpan(ctx, s.tracer, s.getToolName(), argumentsInJSON)
+		defer func() {
+			if err != nil {
+				endToolSpan(span, 0, err)
+				return
+			}
+			outStream = traceStream(span, outStream, func(chunk string) int { return len(chunk) })
+		}()
+	}
+
+	mctx := withMarshalContext(ctx, opts...)
+
 	var inst T
 	if s.um != nil {
 		var val any
-		val, err = s.um(ctx, argumentsInJSON)
+		val, err = s.um(mctx, argumentsInJSON)
 		if err != nil {
 			return nil, fmt.Errorf("[LocalStreamFunc] failed to unmarshal arguments, toolName=%s, err=%w", s.getToolName(), err)
 		}
@@ -118,6 +157,10 @@ func (s *streamableTool[T, D]) StreamableRun(ctx context.Context, argumentsInJSO
 		}
 	}
 
+	if err = checkApproval(ctx, s.approver, s.getToolName(), argumentsInJSON); err != nil {
+		return nil, err
+	}
+
 	streamD, err := s.Fn(ctx, inst, opts...)
 	if err != nil {
 		return nil, err
@@ -127,7 +170,7 @@ func (s *streamableTool[T, D]) StreamableRun(ctx context.Context, argumentsInJSO
 		var out string
 		var e error
 		if s.m != nil {
-			out, e = s.m(ctx, d)
+			out, e = s.m(mctx, d)
 			if e != nil {
 				return "", fmt.Errorf("[LocalStreamFunc] failed to marshal output, toolName=%s, err=%w", s.getToolName(), e)
 			}
@@ -141,6 +184,18 @@ func (s *streamableTool[T, D]) StreamableRun(ctx context.Context, argumentsInJSO
 		return out, nil
 	})
 
+	if s.jsonObjectFraming {
+		outStream = frameJSONObjects(outStream)
+	}
+
+	if s.tokenCounter != nil {
+		outStream = capOutputTokens(outStream, s.tokenCounter, s.maxOutputTokens)
+	}
+
+	if s.checksum {
+		outStream = appendOutputChecksum(outStream)
+	}
+
 	return outStream, nil
 }
 
@@ -196,9 +251,14 @@ func newOptionableEnhancedStreamTool[T any](desc *schema.ToolInfo, s OptionableE
 	to := getToolOptions(opts...)
 
 	return &enhancedStreamableTool[T]{
-		info: desc,
-		um:   to.um,
-		Fn:   s,
+		info:              desc,
+		um:                getUnmarshalArguments[T](to),
+		approver:          to.approver,
+		errorFormatter:    to.errorFormatter,
+		argErrorFormatter: to.argErrorFormatter,
+		resultMeta:        to.resultMeta,
+		tracer:            to.tracer,
+		Fn:                s,
 	}
 }
 
@@ -207,6 +267,15 @@ type enhancedStreamableTool[T any] struct {
 
 	um UnmarshalArguments
 
+	approver ApprovalFunc
+
+	errorFormatter    func(error) *schema.ToolResult
+	argErrorFormatter func(err error, rawArgs string) *schema.ToolResult
+
+	resultMeta bool
+
+	tracer Tracer
+
 	Fn OptionableEnhancedStreamFunc[T]
 }
 
@@ -217,11 +286,26 @@ func (s *enhancedStreamableTool[T]) Info(ctx context.Context) (*schema.ToolInfo,
 func (s *enhancedStreamableTool[T]) StreamableRun(ctx context.Context, toolArgument *schema.ToolArgument, opts ...tool.Option) (
 	outStream *schema.StreamReader[*schema.ToolResult], err error) {
 
+	if s.tracer != nil {
+		var span Span
+		ctx, span = startToolSpan(ctx, s.tracer, s.getToolName(), toolArgument.Text)
+		defer func() {
+			if err != nil {
+				endToolSpan(span, 0, err)
+				return
+			}
+			outStream = traceStream(span, outStream, toolResultSize)
+		}()
+	}
+
 	var inst T
 	if s.um != nil {
 		var val any
-		val, err = s.um(ctx, toolArgument.Text)
+		val, err = s.um(withMarshalContext(ctx, opts...), toolArgument.Text)
 		if err != nil {
+			if result, ok := formatArgErrorAsResult(s.argErrorFormatter, err, toolArgument.Text); ok {
+				return schema.StreamReaderFromArray([]*schema.ToolResult{result}), nil
+			}
 			return nil, fmt.Errorf("[EnhancedLocalStreamFunc] failed to unmarshal arguments, toolName=%s, err=%w", s.getToolName(), err)
 		}
 
@@ -235,11 +319,31 @@ func (s *enhancedStreamableTool[T]) StreamableRun(ctx context.Context, toolArgum
 
 		err = sonic.UnmarshalString(toolArgument.Text, &inst)
 		if err != nil {
+			if result, ok := formatArgErrorAsResult(s.argErrorFormatter, err, toolArgument.Text); ok {
+				return schema.StreamReaderFromArray([]*schema.ToolResult{result}), nil
+			}
 			return nil, fmt.Errorf("[EnhancedLocalStreamFunc] failed to unmarshal arguments in json, toolName=%s, err=%w", s.getToolName(), err)
 		}
 	}
 
-	return s.Fn(ctx, inst, opts...)
+	if err = checkApproval(ctx, s.approver, s.getToolName(), toolArgument.Text); err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	outStream, err = s.Fn(ctx, inst, opts...)
+	if err != nil {
+		if result, ok := formatErrorAsResult(s.errorFormatter, err); ok {
+			return schema.StreamReaderFromArray([]*schema.ToolResult{withToolResultMeta(result, false, time.Since(start))}), nil
+		}
+		return nil, err
+	}
+
+	if s.resultMeta {
+		outStream = appendToolResultMeta(outStream, start)
+	}
+
+	return outStream, nil
 }
 
 func (s *enhancedStreamableTool[T]) GetType() string {
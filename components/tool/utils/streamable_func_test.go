@@ -18,8 +18,12 @@ package utils
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"io"
+	"strings"
 	"testing"
 
 	"github.com/eino-contrib/jsonschema"
@@ -220,6 +224,8 @@ func TestNewEnhancedStreamTool(t *testing.T) {
 				break
 			}
 			assert.NoError(t, err)
+			// the output stream is untouched without WithResultMeta
+			assert.Nil(t, m.Meta)
 
 			if idx == 0 {
 				assert.Len(t, m.Parts, 1)
@@ -327,3 +333,133 @@ func TestInferOptionableEnhancedStreamTool(t *testing.T) {
 		assert.Equal(t, "default: test", m.Parts[0].Text)
 	})
 }
+
+func TestOutputChecksum(t *testing.T) {
+	type Input struct {
+		Name string `json:"name"`
+	}
+
+	tl, err := InferStreamTool("echo", "echo the name twice",
+		func(ctx context.Context, input Input) (*schema.StreamReader[string], error) {
+			return schema.StreamReaderFromArray([]string{input.Name, "-", input.Name}), nil
+		}, WithOutputChecksum())
+	assert.NoError(t, err)
+
+	sr, err := tl.StreamableRun(context.Background(), `{"name":"lee"}`)
+	assert.NoError(t, err)
+
+	var sb strings.Builder
+	var frames int
+	for {
+		chunk, err := sr.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		assert.NoError(t, err)
+		sb.WriteString(chunk)
+		frames++
+	}
+	assert.Equal(t, 4, frames)
+
+	content, checksum, ok := SplitOutputChecksum(sb.String())
+	assert.True(t, ok)
+	assert.Equal(t, "lee-lee", content)
+	sum := sha256.Sum256([]byte(content))
+	assert.Equal(t, hex.EncodeToString(sum[:]), checksum)
+
+	_, _, ok = SplitOutputChecksum("leelee" + OutputChecksumFramePrefix + checksum + "]")
+	assert.False(t, ok)
+
+	_, _, ok = SplitOutputChecksum(content)
+	assert.False(t, ok)
+}
+
+func TestMaxOutputTokens(t *testing.T) {
+	type Input struct {
+		Words []string `json:"words"`
+	}
+
+	wordCounter := func(msg *schema.Message) (int, error) {
+		return len(strings.Fields(msg.Content)), nil
+	}
+
+	tl, err := InferStreamTool("echo", "echo the words",
+		func(ctx context.Context, input Input) (*schema.StreamReader[string], error) {
+			return schema.StreamReaderFromArray(input.Words), nil
+		}, WithMaxOutputTokens(wordCounter, 5))
+	assert.NoError(t, err)
+
+	t.Run("truncated", func(t *testing.T) {
+		sr, err := tl.StreamableRun(context.Background(), `{"words":["one two ","three ","four five ","six ","seven"]}`)
+		assert.NoError(t, err)
+
+		frames, err := schema.CollectBestEffort(sr)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"one two ", "three ", "four five ", OutputTruncatedMarker}, frames)
+	})
+
+	t.Run("under cap", func(t *testing.T) {
+		sr, err := tl.StreamableRun(context.Background(), `{"words":["one ","two"]}`)
+		assert.NoError(t, err)
+
+		frames, err := schema.CollectBestEffort(sr)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"one ", "two"}, frames)
+	})
+
+	t.Run("upstream error", func(t *testing.T) {
+		src, sw := schema.Pipe[string](3)
+		sw.Send("one ", nil)
+		sw.Send("", errors.New("upstream error"))
+		sw.Send("two", nil)
+		sw.Close()
+
+		sr := capOutputTokens(src, wordCounter, 5)
+		defer sr.Close()
+		chunk, err := sr.Recv()
+		assert.NoError(t, err)
+		assert.Equal(t, "one ", chunk)
+		_, err = sr.Recv()
+		assert.ErrorContains(t, err, "upstream error")
+		_, err = sr.Recv()
+		assert.ErrorIs(t, err, io.EOF)
+	})
+}
+
+func TestJSONObjectFraming(t *testing.T) {
+	type Input struct {
+		Chunks []string `json:"chunks"`
+	}
+
+	tl, err := InferStreamTool("objects", "stream the raw chunks",
+		func(ctx context.Context, input Input) (*schema.StreamReader[string], error) {
+			return schema.StreamReaderFromArray(input.Chunks), nil
+		}, WithJSONObjectFraming())
+	assert.NoError(t, err)
+
+	run := func(chunks ...string) ([]string, error) {
+		args, err := json.Marshal(Input{Chunks: chunks})
+		assert.NoError(t, err)
+		sr, err := tl.StreamableRun(context.Background(), string(args))
+		assert.NoError(t, err)
+		return schema.CollectBestEffort(sr)
+	}
+
+	t.Run("split across chunks", func(t *testing.T) {
+		frames, err := run(`{"a":1,"b":{"c`, `":[1,2]}}`, "\n", `[{"s":"}{\"]"}`, `]{"x":`, `"y"}  {}`)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{`{"a":1,"b":{"c":[1,2]}}`, `[{"s":"}{\"]"}]`, `{"x":"y"}`, `{}`}, frames)
+	})
+
+	t.Run("incomplete", func(t *testing.T) {
+		frames, err := run(`{"a":1}{"b":`)
+		assert.ErrorContains(t, err, "incomplete JSON value")
+		assert.Equal(t, []string{`{"a":1}`}, frames)
+	})
+
+	t.Run("not an object", func(t *testing.T) {
+		frames, err := run(`{"a":1} 42`)
+		assert.ErrorContains(t, err, "outside of JSON object or array")
+		assert.Equal(t, []string{`{"a":1}`}, frames)
+	})
+}
@@ -0,0 +1,52 @@
+/*
+ * Copyright 2026 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package utils
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+)
+
+// Cache is the storage of tool results used by WithCache, e.g. backed by an in-process LRU or redis.
+type Cache interface {
+	// Get returns the cached output for key, and whether it is found and not expired.
+	Get(ctx context.Context, key string) (string, bool)
+	// Set stores the output for key, which expires after ttl, or never if ttl is 0.
+	Set(ctx context.Context, key string, output string, ttl time.Duration)
+}
+
+// toolCacheKey builds the cache key from the tool name and the SHA-256 of the arguments.
+// The arguments are canonicalized if they are valid JSON, so that the differences in whitespace or key order still hit the cache.
+func toolCacheKey(toolName, arguments string) string {
+	data := []byte(arguments)
+
+	var v any
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&v); err == nil {
+		if canonical, err := json.Marshal(v); err == nil {
+			data = canonical
+		}
+	}
+
+	sum := sha256.Sum256(data)
+	return toolName + ":" + hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,58 @@
+/*
+ * Copyright 2026 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package utils
+
+import (
+	"context"
+	"fmt"
+)
+
+// ConcurrencyLimiter bounds the number of tool functions running at the same time, see WithConcurrencyLimit.
+// A limiter can be shared by multiple tools, e.g. all the tools calling the same downstream service.
+type ConcurrencyLimiter struct {
+	slots chan struct{}
+}
+
+// NewConcurrencyLimiter creates a ConcurrencyLimiter allowing at most n tool functions to run at the same time.
+// A non-positive n means no limit.
+func NewConcurrencyLimiter(n int) *ConcurrencyLimiter {
+	if n <= 0 {
+		return &ConcurrencyLimiter{}
+	}
+	return &ConcurrencyLimiter{slots: make(chan struct{}, n)}
+}
+
+// acquire waits for a free slot, or returns the error of ctx if it's done first.
+func (l *ConcurrencyLimiter) acquire(ctx context.Context, toolName string) error {
+	if l == nil || l.slots == nil {
+		return nil
+	}
+
+	select {
+	case l.slots <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("failed to wait for concurrency limit, toolName=%s, err=%w", toolName, ctx.Err())
+	}
+}
+
+func (l *ConcurrencyLimiter) release() {
+	if l == nil || l.slots == nil {
+		return
+	}
+	<-l.slots
+}
@@ -0,0 +1,67 @@
+/*
+ * Copyright 2026 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package utils
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/cloudwego/eino/internal"
+)
+
+// MaxToolNameLength is the max length of tool names accepted by ValidateToolNames, which is the limit of most providers.
+const MaxToolNameLength = 64
+
+var toolNamePattern = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+
+// ValidateToolNames checks the names of tools returned by their Info against the restrictions of the provider APIs,
+// i.e. matching ^[a-zA-Z0-9_-]+$ and no longer than MaxToolNameLength, and that no two tools share the same name,
+// which catches the registration mistakes before they cause 400 errors from the provider.
+// All violations are joined into the returned error, which errors.Is and errors.As match against each of them.
+func ValidateToolNames(ctx context.Context, tools []tool.BaseTool) error {
+	var errs []error
+	seen := make(map[string]int, len(tools))
+	for i, t := range tools {
+		info, err := t.Info(ctx)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to get info of tool[%d]: %w", i, err))
+			continue
+		}
+		if info == nil {
+			errs = append(errs, fmt.Errorf("info of tool[%d] is nil", i))
+			continue
+		}
+
+		name := info.Name
+		switch {
+		case !toolNamePattern.MatchString(name):
+			errs = append(errs, fmt.Errorf("name of tool[%d] %q contains characters other than letters, digits, '_' and '-', or is empty", i, name))
+		case len(name) > MaxToolNameLength:
+			errs = append(errs, fmt.Errorf("name of tool[%d] %q is longer than %d", i, name, MaxToolNameLength))
+		}
+
+		if j, ok := seen[name]; ok {
+			errs = append(errs, fmt.Errorf("name of tool[%d] %q duplicates tool[%d]", i, name, j))
+			continue
+		}
+		seen[name] = i
+	}
+
+	return internal.JoinErrors(errs...)
+}
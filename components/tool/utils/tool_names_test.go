@@ -0,0 +1,58 @@
+/*
+ * Copyright 2026 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package utils
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/cloudwego/eino/schema"
+)
+
+func TestValidateToolNames(t *testing.T) {
+	ctx := context.Background()
+	newTool := func(name string) tool.BaseTool {
+		return NewTool(&schema.ToolInfo{Name: name}, func(ctx context.Context, input map[string]any) (string, error) {
+			return "", nil
+		})
+	}
+
+	assert.NoError(t, ValidateToolNames(ctx, []tool.BaseTool{newTool("search"), newTool("get-weather_v2")}))
+
+	t.Run("duplicate", func(t *testing.T) {
+		err := ValidateToolNames(ctx, []tool.BaseTool{newTool("search"), newTool("weather"), newTool("search")})
+		assert.EqualError(t, err, `name of tool[2] "search" duplicates tool[0]`)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		err := ValidateToolNames(ctx, []tool.BaseTool{
+			newTool("web search"),
+			newTool(""),
+			newTool(strings.Repeat("a", MaxToolNameLength+1)),
+			newTool("web search"),
+		})
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), `name of tool[0] "web search" contains characters`)
+		assert.Contains(t, err.Error(), `name of tool[1] "" contains characters`)
+		assert.Contains(t, err.Error(), "is longer than 64")
+		assert.Contains(t, err.Error(), `name of tool[3] "web search" duplicates tool[0]`)
+	})
+}
@@ -0,0 +1,141 @@
+/*
+ * Copyright 2026 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package utils
+
+import (
+	"context"
+
+	"github.com/cloudwego/eino/components"
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/cloudwego/eino/schema"
+)
+
+// PrefixToolNames wraps each of tools so that its name in Info is prefix + "_" + the original name, e.g. "github_search",
+// which avoids the name collisions when merging the tool sets of multiple libraries.
+// The wrapped tools implement the same of InvokableTool, StreamableTool, EnhancedInvokableTool and EnhancedStreamableTool
+// as the original ones, and run them as is, since the name only affects Info and GetType.
+// Other optional interfaces, e.g. BatchStreamableTool, are not kept, while the callbacks status of components.Checker is.
+func PrefixToolNames(prefix string, tools ...tool.BaseTool) []tool.BaseTool {
+	ret := make([]tool.BaseTool, 0, len(tools))
+	for _, t := range tools {
+		ret = append(ret, prefixToolName(prefix, t))
+	}
+	return ret
+}
+
+type invokableRunner interface {
+	InvokableRun(ctx context.Context, argumentsInJSON string, opts ...tool.Option) (string, error)
+}
+
+type streamableRunner interface {
+	StreamableRun(ctx context.Context, argumentsInJSON string, opts ...tool.Option) (*schema.StreamReader[string], error)
+}
+
+type enhancedInvokableRunner interface {
+	InvokableRun(ctx context.Context, toolArgument *schema.ToolArgument, opts ...tool.Option) (*schema.ToolResult, error)
+}
+
+type enhancedStreamableRunner interface {
+	StreamableRun(ctx context.Context, toolArgument *schema.ToolArgument, opts ...tool.Option) (*schema.StreamReader[*schema.ToolResult], error)
+}
+
+func prefixToolName(prefix string, t tool.BaseTool) tool.BaseTool {
+	p := &prefixedTool{BaseTool: t, prefix: prefix}
+
+	// a tool can't implement both of the invokable interfaces or both of the streamable ones, as the method names are the same
+	inv, isInv := t.(invokableRunner)
+	einv, isEInv := t.(enhancedInvokableRunner)
+	str, isStr := t.(streamableRunner)
+	estr, isEStr := t.(enhancedStreamableRunner)
+
+	switch {
+	case isInv && isStr:
+		return &struct {
+			*prefixedTool
+			invokableRunner
+			streamableRunner
+		}{p, inv, str}
+	case isInv && isEStr:
+		return &struct {
+			*prefixedTool
+			invokableRunner
+			enhancedStreamableRunner
+		}{p, inv, estr}
+	case isEInv && isStr:
+		return &struct {
+			*prefixedTool
+			enhancedInvokableRunner
+			streamableRunner
+		}{p, einv, str}
+	case isEInv && isEStr:
+		return &struct {
+			*prefixedTool
+			enhancedInvokableRunner
+			enhancedStreamableRunner
+		}{p, einv, estr}
+	case isInv:
+		return &struct {
+			*prefixedTool
+			invokableRunner
+		}{p, inv}
+	case isEInv:
+		return &struct {
+			*prefixedTool
+			enhancedInvokableRunner
+		}{p, einv}
+	case isStr:
+		return &struct {
+			*prefixedTool
+			streamableRunner
+		}{p, str}
+	case isEStr:
+		return &struct {
+			*prefixedTool
+			enhancedStreamableRunner
+		}{p, estr}
+	default:
+		return p
+	}
+}
+
+// prefixedTool overrides the name of the wrapped tool, while the run methods are provided by the embedding structs.
+type prefixedTool struct {
+	tool.BaseTool
+	prefix string
+}
+
+func (p *prefixedTool) Info(ctx context.Context) (*schema.ToolInfo, error) {
+	info, err := p.BaseTool.Info(ctx)
+	if err != nil || info == nil {
+		return info, err
+	}
+
+	copied := *info
+	copied.Name = p.prefix + "_" + info.Name
+
+	return &copied, nil
+}
+
+// GetType prepends the prefix in camel case to the type of the wrapped tool, if any.
+func (p *prefixedTool) GetType() string {
+	typ, _ := components.GetType(p.BaseTool)
+	return snakeToCamel(p.prefix) + typ
+}
+
+func (p *prefixedTool) IsCallbacksEnabled() bool {
+	return components.IsCallbacksEnabled(p.BaseTool)
+}
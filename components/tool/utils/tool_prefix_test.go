@@ -0,0 +1,92 @@
+/*
+ * Copyright 2026 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package utils
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/cloudwego/eino/components"
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/cloudwego/eino/schema"
+)
+
+func TestPrefixToolNames(t *testing.T) {
+	type Input struct {
+		Query string `json:"query"`
+	}
+	ctx := context.Background()
+
+	search, err := InferTool("search", "search", func(ctx context.Context, input Input) (string, error) {
+		return "found " + input.Query, nil
+	})
+	assert.NoError(t, err)
+	stream, err := InferStreamTool("stream_search", "stream search", func(ctx context.Context, input Input) (*schema.StreamReader[string], error) {
+		return schema.StreamReaderFromArray([]string{"found ", input.Query}), nil
+	})
+	assert.NoError(t, err)
+	enhanced, err := InferEnhancedTool("image_search", "image search", func(ctx context.Context, input Input) (*schema.ToolResult, error) {
+		return &schema.ToolResult{Parts: []schema.ToolOutputPart{{Type: schema.ToolPartTypeText, Text: input.Query}}}, nil
+	})
+	assert.NoError(t, err)
+
+	tools := PrefixToolNames("github", search, stream, enhanced)
+	assert.Len(t, tools, 3)
+
+	var names []string
+	for _, tl := range tools {
+		info, err := tl.Info(ctx)
+		assert.NoError(t, err)
+		names = append(names, info.Name)
+	}
+	assert.Equal(t, []string{"github_search", "github_stream_search", "github_image_search"}, names)
+
+	// the original tools are not modified
+	info, err := search.Info(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, "search", info.Name)
+
+	typ, ok := components.GetType(tools[0])
+	assert.True(t, ok)
+	assert.Equal(t, "GithubSearch", typ)
+
+	inv, ok := tools[0].(tool.InvokableTool)
+	assert.True(t, ok)
+	_, ok = tools[0].(tool.StreamableTool)
+	assert.False(t, ok)
+	out, err := inv.InvokableRun(ctx, `{"query":"eino"}`)
+	assert.NoError(t, err)
+	assert.Equal(t, "found eino", out)
+
+	str, ok := tools[1].(tool.StreamableTool)
+	assert.True(t, ok)
+	_, ok = tools[1].(tool.InvokableTool)
+	assert.False(t, ok)
+	sr, err := str.StreamableRun(ctx, `{"query":"eino"}`)
+	assert.NoError(t, err)
+	chunks, err := schema.CollectBestEffort(sr)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"found ", "eino"}, chunks)
+
+	einv, ok := tools[2].(tool.EnhancedInvokableTool)
+	assert.True(t, ok)
+	result, err := einv.InvokableRun(ctx, &schema.ToolArgument{Text: `{"query":"cat"}`})
+	assert.NoError(t, err)
+	assert.Equal(t, "cat", result.Parts[0].Text)
+}
@@ -0,0 +1,94 @@
+/*
+ * Copyright 2026 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package utils
+
+import (
+	"errors"
+	"io"
+	"runtime/debug"
+	"time"
+
+	"github.com/cloudwego/eino/internal/safe"
+	"github.com/cloudwego/eino/schema"
+)
+
+// withToolResultMeta returns a copy of tr with the execution status set.
+// The status set by the tool itself is kept, except that the duration is filled in if zero.
+func withToolResultMeta(tr *schema.ToolResult, success bool, duration time.Duration) *schema.ToolResult {
+	var ret schema.ToolResult
+	if tr != nil {
+		ret = *tr
+	}
+
+	ret.Meta = fillToolResultMeta(ret.Meta, success, duration)
+	return &ret
+}
+
+func fillToolResultMeta(meta *schema.ToolResultMeta, success bool, duration time.Duration) *schema.ToolResultMeta {
+	if meta == nil {
+		return &schema.ToolResultMeta{Success: success, Duration: duration}
+	}
+
+	cp := *meta
+	if cp.Duration == 0 {
+		cp.Duration = duration
+	}
+	return &cp
+}
+
+// appendToolResultMeta forwards the output stream of an enhanced streamable tool as is, and appends a chunk carrying only
+// the execution status when the stream ends, where the duration covers the call and the whole stream since start.
+// If the stream fails, the error is forwarded without the status chunk.
+func appendToolResultMeta(sr *schema.StreamReader[*schema.ToolResult], start time.Time) *schema.StreamReader[*schema.ToolResult] {
+	outSR, sw := schema.Pipe[*schema.ToolResult](0)
+
+	go func() {
+		defer func() {
+			panicErr := recover()
+			if panicErr != nil {
+				_ = sw.Send(nil, safe.NewPanicErr(panicErr, debug.Stack()))
+			}
+
+			sw.Close()
+			sr.Close()
+		}()
+
+		// the status set by the tool itself in any chunk
+		var meta *schema.ToolResultMeta
+		for {
+			chunk, err := sr.Recv()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				_ = sw.Send(nil, err)
+				return
+			}
+
+			if chunk != nil && chunk.Meta != nil {
+				meta = chunk.Meta
+			}
+			if sw.Send(chunk, nil) {
+				return
+			}
+		}
+
+		_ = sw.Send(&schema.ToolResult{Meta: fillToolResultMeta(meta, true, time.Since(start))}, nil)
+	}()
+
+	return outSR
+}
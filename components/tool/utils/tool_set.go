@@ -0,0 +1,83 @@
+/*
+ * Copyright 2026 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package utils
+
+import (
+	"fmt"
+	"runtime/debug"
+	"sync"
+
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/cloudwego/eino/internal"
+	"github.com/cloudwego/eino/internal/safe"
+)
+
+// ToolSet collects the constructors of tools, e.g. InferTool, and builds them at once,
+// so that the startup code doesn't need to check the error of each constructor.
+// e.g.
+//
+//	tools, err := new(utils.ToolSet).
+//		Add(func() (tool.BaseTool, error) { return utils.InferTool("search", "search the web", search) }).
+//		Add(func() (tool.BaseTool, error) { return utils.InferTool("weather", "get the weather", getWeather) }).
+//		Build()
+type ToolSet struct {
+	constructors []func() (tool.BaseTool, error)
+}
+
+// Add adds a tool constructor to the set, and returns the set for chaining.
+func (ts *ToolSet) Add(constructor func() (tool.BaseTool, error)) *ToolSet {
+	ts.constructors = append(ts.constructors, constructor)
+	return ts
+}
+
+// Build runs all the constructors in parallel, and returns the tools built successfully in the order they were added.
+// The errors of the failed constructors, including panics, are joined into the returned error,
+// which errors.Is and errors.As match against each of them.
+func (ts *ToolSet) Build() ([]tool.BaseTool, error) {
+	tools := make([]tool.BaseTool, len(ts.constructors))
+	errs := make([]error, len(ts.constructors))
+
+	wg := sync.WaitGroup{}
+	wg.Add(len(ts.constructors))
+	for i, constructor := range ts.constructors {
+		go func(i int, constructor func() (tool.BaseTool, error)) {
+			defer wg.Done()
+			defer func() {
+				if panicErr := recover(); panicErr != nil {
+					errs[i] = fmt.Errorf("failed to build tool[%d]: %w", i, safe.NewPanicErr(panicErr, debug.Stack()))
+				}
+			}()
+
+			t, err := constructor()
+			if err != nil {
+				errs[i] = fmt.Errorf("failed to build tool[%d]: %w", i, err)
+				return
+			}
+			tools[i] = t
+		}(i, constructor)
+	}
+	wg.Wait()
+
+	ret := make([]tool.BaseTool, 0, len(tools))
+	for i, t := range tools {
+		if errs[i] == nil && t != nil {
+			ret = append(ret, t)
+		}
+	}
+
+	return ret, internal.JoinErrors(errs...)
+}
@@ -0,0 +1,60 @@
+/*
+ * Copyright 2026 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package utils
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/cloudwego/eino/components/tool"
+)
+
+func TestToolSet(t *testing.T) {
+	type Input struct {
+		Query string `json:"query"`
+	}
+	fn := func(ctx context.Context, input Input) (string, error) {
+		return input.Query, nil
+	}
+	errBroken := errors.New("broken tool")
+
+	tools, err := new(ToolSet).
+		Add(func() (tool.BaseTool, error) { return InferTool("search", "search", fn) }).
+		Add(func() (tool.BaseTool, error) { return nil, errBroken }).
+		Add(func() (tool.BaseTool, error) { panic("boom") }).
+		Add(func() (tool.BaseTool, error) { return InferTool("echo", "echo", fn) }).
+		Build()
+	assert.ErrorIs(t, err, errBroken)
+	assert.ErrorContains(t, err, "failed to build tool[1]: broken tool")
+	assert.ErrorContains(t, err, "failed to build tool[2]")
+	assert.ErrorContains(t, err, "boom")
+
+	assert.Len(t, tools, 2)
+	info, err := tools[0].Info(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, "search", info.Name)
+	info, err = tools[1].Info(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, "echo", info.Name)
+
+	tools, err = new(ToolSet).Build()
+	assert.NoError(t, err)
+	assert.Empty(t, tools)
+}
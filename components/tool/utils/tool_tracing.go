@@ -0,0 +1,134 @@
+/*
+ * Copyright 2026 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package utils
+
+import (
+	"context"
+	"errors"
+	"io"
+	"runtime/debug"
+
+	"github.com/cloudwego/eino/internal/safe"
+	"github.com/cloudwego/eino/schema"
+)
+
+// Span attribute keys recorded by WithTracing.
+const (
+	SpanAttrToolName      = "tool.name"
+	SpanAttrArgumentsSize = "tool.arguments.size"
+	SpanAttrOutputSize    = "tool.output.size"
+)
+
+// Tracer starts the spans for the tool invocations traced by WithTracing.
+// It mirrors the shape of the OpenTelemetry trace API, so that eino does not depend on otel,
+// while the module components/tool/utils/oteltool implements it with an otel tracer.
+type Tracer interface {
+	// Start starts a span named spanName as a child of the span in ctx, if any,
+	// and returns the context carrying the new span.
+	Start(ctx context.Context, spanName string) (context.Context, Span)
+}
+
+// Span is a span started by Tracer.
+type Span interface {
+	// SetAttribute records an attribute of the span, where value is a string or an int.
+	SetAttribute(key string, value any)
+	// RecordError records err on the span and marks the span as failed.
+	RecordError(err error)
+	// End ends the span.
+	End()
+}
+
+func startToolSpan(ctx context.Context, tracer Tracer, toolName, arguments string) (context.Context, Span) {
+	ctx, span := tracer.Start(ctx, toolName)
+	span.SetAttribute(SpanAttrToolName, toolName)
+	span.SetAttribute(SpanAttrArgumentsSize, len(arguments))
+	return ctx, span
+}
+
+func endToolSpan(span Span, outputSize int, err error) {
+	if err != nil {
+		span.RecordError(err)
+	} else {
+		span.SetAttribute(SpanAttrOutputSize, outputSize)
+	}
+	span.End()
+}
+
+// traceStream forwards the output stream of a streamable tool as is, and ends span when the stream ends,
+// with the total size of the chunks measured by size, or with the error the stream fails with.
+// If the stream is closed by the receiver before it ends, span ends with the size received so far.
+func traceStream[T any](span Span, sr *schema.StreamReader[T], size func(T) int) *schema.StreamReader[T] {
+	outSR, sw := schema.Pipe[T](0)
+
+	go func() {
+		var total int
+		ended := false
+		end := func(err error) {
+			if !ended {
+				ended = true
+				endToolSpan(span, total, err)
+			}
+		}
+
+		defer func() {
+			panicErr := recover()
+			if panicErr != nil {
+				var zero T
+				err := safe.NewPanicErr(panicErr, debug.Stack())
+				end(err)
+				_ = sw.Send(zero, err)
+			}
+
+			end(nil)
+			sw.Close()
+			sr.Close()
+		}()
+
+		for {
+			chunk, err := sr.Recv()
+			if errors.Is(err, io.EOF) {
+				return
+			}
+			if err != nil {
+				// the span ends before the error reaches the receiver
+				end(err)
+				_ = sw.Send(chunk, err)
+				return
+			}
+
+			total += size(chunk)
+			if sw.Send(chunk, nil) {
+				return
+			}
+		}
+	}()
+
+	return outSR
+}
+
+// toolResultSize is the output size of an enhanced tool, i.e. the size of the text in its parts.
+func toolResultSize(result *schema.ToolResult) int {
+	if result == nil {
+		return 0
+	}
+
+	var size int
+	for _, part := range result.Parts {
+		size += len(part.Text)
+	}
+	return size
+}
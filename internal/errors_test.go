@@ -0,0 +1,55 @@
+/*
+ * Copyright 2026 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testPathError struct {
+	path string
+}
+
+func (e *testPathError) Error() string {
+	return "bad path: " + e.path
+}
+
+func TestJoinErrors(t *testing.T) {
+	assert.NoError(t, JoinErrors())
+	assert.NoError(t, JoinErrors(nil, nil))
+
+	errA := errors.New("a")
+	errB := fmt.Errorf("wrapped: %w", &testPathError{path: "/tmp"})
+	err := JoinErrors(errA, nil, errB)
+	assert.Equal(t, "a\nwrapped: bad path: /tmp", err.Error())
+
+	// the methods are called directly, as errors.Is and errors.As only support Unwrap() []error since go1.20
+	je := err.(*joinError)
+	assert.True(t, je.Is(errA))
+	assert.False(t, je.Is(errors.New("a")))
+	var pathErr *testPathError
+	if assert.True(t, je.As(&pathErr)) {
+		assert.Equal(t, "/tmp", pathErr.path)
+	}
+
+	assert.ErrorIs(t, err, errA)
+	assert.ErrorAs(t, err, &pathErr)
+}
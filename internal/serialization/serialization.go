@@ -17,9 +17,12 @@
 package serialization
 
 import (
+	"encoding/binary"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"reflect"
+	"sort"
 
 	"github.com/bytedance/sonic"
 )
@@ -27,6 +30,10 @@ import (
 var m = map[string]reflect.Type{}
 var rm = map[reflect.Type]string{}
 
+// conflicts records every rejected registration so that callers ignoring the
+// error of GenericRegister can still find out about it via ValidateRegistry.
+var conflicts []error
+
 func init() {
 	_ = GenericRegister[int]("_eino_int")
 	_ = GenericRegister[int8]("_eino_int8")
@@ -54,20 +61,110 @@ func GenericRegister[T any](key string) error {
 		t = t.Elem()
 	}
 	if nt, ok := m[key]; ok {
-		return fmt.Errorf("key[%s] already registered to %s", key, nt.String())
+		err := fmt.Errorf("key[%s] already registered to %s", key, nt.String())
+		if nt != t {
+			conflicts = append(conflicts, fmt.Errorf("%w, cannot register %s", err, t.String()))
+		}
+		return err
 	}
 	if nk, ok := rm[t]; ok {
-		return fmt.Errorf("type[%s] already registered to %s", t.String(), nk)
+		err := fmt.Errorf("type[%s] already registered to %s", t.String(), nk)
+		if nk != key {
+			conflicts = append(conflicts, fmt.Errorf("%w, cannot register as %s", err, key))
+		}
+		return err
 	}
 	m[key] = t
 	rm[t] = key
 	return nil
 }
 
-type InternalSerializer struct{}
+// ValidateRegistry inspects the registered types and reports:
+//   - registrations rejected because the name or the type was already taken by another entry.
+//   - non-empty interface types used in fields of registered types that have no registered implementer,
+//     which means values assigned to such fields cannot be serialized.
+func ValidateRegistry() []error {
+	var errs []error
+	errs = append(errs, conflicts...)
+
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	visited := map[reflect.Type]bool{}
+	reported := map[reflect.Type]bool{}
+	for _, k := range keys {
+		var walk func(t reflect.Type, path string)
+		walk = func(t reflect.Type, path string) {
+			switch t.Kind() {
+			case reflect.Ptr, reflect.Slice, reflect.Array:
+				walk(t.Elem(), path)
+			case reflect.Map:
+				walk(t.Key(), path)
+				walk(t.Elem(), path)
+			case reflect.Interface:
+				if t.NumMethod() == 0 || reported[t] || hasRegisteredImplementer(t) {
+					return
+				}
+				reported[t] = true
+				errs = append(errs, fmt.Errorf("interface type[%s] used in %s has no registered implementer", t.String(), path))
+			case reflect.Struct:
+				if visited[t] {
+					return
+				}
+				visited[t] = true
+				for i := 0; i < t.NumField(); i++ {
+					field := t.Field(i)
+					if field.PkgPath != "" {
+						continue
+					}
+					walk(field.Type, t.String()+"."+field.Name)
+				}
+			}
+		}
+		walk(m[k], k)
+	}
+
+	return errs
+}
+
+func hasRegisteredImplementer(it reflect.Type) bool {
+	for t := range rm {
+		if t.Kind() == reflect.Interface {
+			continue
+		}
+		if t.Implements(it) || reflect.PointerTo(t).Implements(it) {
+			return true
+		}
+	}
+	return false
+}
+
+// DefaultMaxDepth is the max nesting depth of values used by InternalSerializer if MaxDepth is not set,
+// which is far beyond the depth of legitimate values.
+const DefaultMaxDepth = 10000
+
+// ErrMaxDepthExceeded is returned by InternalSerializer when a value is nested deeper than the max depth,
+// e.g. from malicious or buggy input, instead of exhausting the stack.
+var ErrMaxDepthExceeded = errors.New("max nesting depth exceeded")
+
+type InternalSerializer struct {
+	// MaxDepth is the max nesting depth of the values to marshal or unmarshal, where each level of struct, map, slice
+	// or array counts as one. DefaultMaxDepth is used if it is not positive.
+	MaxDepth int
+}
+
+func (i *InternalSerializer) maxDepth() int {
+	if i.MaxDepth > 0 {
+		return i.MaxDepth
+	}
+	return DefaultMaxDepth
+}
 
 func (i *InternalSerializer) Marshal(v any) ([]byte, error) {
-	is, err := internalMarshal(v, nil)
+	is, err := internalMarshal(v, nil, i.maxDepth())
 	if err != nil {
 		return nil, err
 	}
@@ -76,7 +173,7 @@ func (i *InternalSerializer) Marshal(v any) ([]byte, error) {
 }
 
 func (i *InternalSerializer) Unmarshal(data []byte, v any) error {
-	val, err := unmarshal(data, reflect.TypeOf(v))
+	val, err := unmarshal(data, reflect.TypeOf(v), i.maxDepth())
 	if err != nil {
 		return fmt.Errorf("failed to unmarshal: %w", err)
 	}
@@ -142,13 +239,60 @@ func (i *InternalSerializer) Unmarshal(data []byte, v any) error {
 	return fmt.Errorf("failed to unmarshal: cannot assign %s to %s", reflect.TypeOf(val), target.Type())
 }
 
-func unmarshal(data []byte, t reflect.Type) (any, error) {
+// MarshalBatch marshals values into one blob, which is a uvarint count of the values followed by
+// each marshalled value prefixed with its uvarint length, so that several values can be persisted together.
+func (i *InternalSerializer) MarshalBatch(values ...any) ([]byte, error) {
+	lenBuf := make([]byte, binary.MaxVarintLen64)
+	buf := append([]byte(nil), lenBuf[:binary.PutUvarint(lenBuf, uint64(len(values)))]...)
+	for idx, v := range values {
+		data, err := i.Marshal(v)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal batch value[%d]: %w", idx, err)
+		}
+		buf = append(buf, lenBuf[:binary.PutUvarint(lenBuf, uint64(len(data)))]...)
+		buf = append(buf, data...)
+	}
+
+	return buf, nil
+}
+
+// UnmarshalBatch unmarshals the blob produced by MarshalBatch into targets in order, each of which must be a non-nil pointer.
+// It returns an error if the count of the values in the blob doesn't match the count of targets.
+func (i *InternalSerializer) UnmarshalBatch(data []byte, targets ...any) error {
+	count, n := binary.Uvarint(data)
+	if n <= 0 {
+		return fmt.Errorf("failed to unmarshal batch: invalid value count")
+	}
+	data = data[n:]
+	if count != uint64(len(targets)) {
+		return fmt.Errorf("failed to unmarshal batch: value count mismatch, data has %d, targets has %d", count, len(targets))
+	}
+
+	for idx, target := range targets {
+		length, n := binary.Uvarint(data)
+		if n <= 0 || length > uint64(len(data)-n) {
+			return fmt.Errorf("failed to unmarshal batch value[%d]: data truncated", idx)
+		}
+		data = data[n:]
+		if err := i.Unmarshal(data[:length], target); err != nil {
+			return fmt.Errorf("failed to unmarshal batch value[%d]: %w", idx, err)
+		}
+		data = data[length:]
+	}
+	if len(data) > 0 {
+		return fmt.Errorf("failed to unmarshal batch: %d trailing bytes", len(data))
+	}
+
+	return nil
+}
+
+func unmarshal(data []byte, t reflect.Type, depth int) (any, error) {
 	is := &internalStruct{}
 	err := sonic.Unmarshal(data, is)
 	if err != nil {
 		return nil, err
 	}
-	return internalUnmarshal(is, t)
+	return internalUnmarshal(is, t, depth)
 }
 
 type internalStruct struct {
@@ -245,11 +389,15 @@ func restoreType(vt *valueType) (reflect.Type, error) {
 	return nil, fmt.Errorf("empty value")
 }
 
-func internalMarshal(v any, fieldType reflect.Type) (*internalStruct, error) {
+// internalMarshal marshals v, where depth is the remaining levels of nesting allowed.
+func internalMarshal(v any, fieldType reflect.Type, depth int) (*internalStruct, error) {
 	if v == nil ||
 		(reflect.ValueOf(v).IsZero() && fieldType != nil && fieldType.Kind() != reflect.Interface) {
 		return nil, nil
 	}
+	if depth <= 0 {
+		return nil, ErrMaxDepthExceeded
+	}
 
 	ret := &internalStruct{}
 	rv := reflect.ValueOf(v)
@@ -282,6 +430,26 @@ func internalMarshal(v any, fieldType reflect.Type) (*internalStruct, error) {
 		return ret, nil
 	}
 
+	// non-struct types with their own json encoding, e.g. json.RawMessage, are kept as json like the structs below,
+	// as unmarshalling restores them by json if the type is specific
+	if rt.Kind() != reflect.Struct && checkMarshaler(rt) {
+		key, registered := rm[rt]
+		if !typeUnspecific || registered {
+			if typeUnspecific {
+				ret.Type = &valueType{
+					PointerNum: pointerNum,
+					SimpleType: key,
+				}
+			}
+			jsonBytes, err := json.Marshal(rv.Interface())
+			if err != nil {
+				return nil, err
+			}
+			ret.JSONValue = jsonBytes
+			return ret, nil
+		}
+	}
+
 	switch rt.Kind() {
 	case reflect.Struct:
 		if typeUnspecific {
@@ -322,7 +490,7 @@ func internalMarshal(v any, fieldType reflect.Type) (*internalStruct, error) {
 				k := field.Name
 				v := rv.Field(i)
 
-				internalValue, err := internalMarshal(v.Interface(), field.Type)
+				internalValue, err := internalMarshal(v.Interface(), field.Type, depth-1)
 				if err != nil {
 					return nil, err
 				}
@@ -358,7 +526,7 @@ func internalMarshal(v any, fieldType reflect.Type) (*internalStruct, error) {
 			k := iter.Key()
 			v := iter.Value()
 
-			internalValue, err := internalMarshal(v.Interface(), rt.Elem())
+			internalValue, err := internalMarshal(v.Interface(), rt.Elem(), depth-1)
 			if err != nil {
 				return nil, err
 			}
@@ -385,9 +553,11 @@ func internalMarshal(v any, fieldType reflect.Type) (*internalStruct, error) {
 		ret.SliceValues = make([]*internalStruct, length)
 
 		for i := 0; i < length; i++ {
-			internalValue, err := internalMarshal(rv.Index(i).Interface(), rt.Elem())
+			elem := rv.Index(i).Interface()
+			internalValue, err := internalMarshal(elem, rt.Elem(), depth-1)
 			if err != nil {
-				return nil, err
+				// name the index and the concrete type, as the element type can be an interface
+				return nil, fmt.Errorf("marshal slice[%s] element %d of type %T fail: %w", rt.Elem(), i, elem, err)
 			}
 			ret.SliceValues[i] = internalValue
 		}
@@ -415,10 +585,14 @@ func internalMarshal(v any, fieldType reflect.Type) (*internalStruct, error) {
 	}
 }
 
-func internalUnmarshal(v *internalStruct, typ reflect.Type) (any, error) {
+// internalUnmarshal unmarshals v, where depth is the remaining levels of nesting allowed.
+func internalUnmarshal(v *internalStruct, typ reflect.Type, depth int) (any, error) {
 	if v == nil {
 		return nil, nil
 	}
+	if depth <= 0 {
+		return nil, ErrMaxDepthExceeded
+	}
 
 	if v.Type == nil {
 		// specific type
@@ -430,7 +604,7 @@ func internalUnmarshal(v *internalStruct, typ reflect.Type) (any, error) {
 			}
 			return pv.Elem().Interface(), nil
 		}
-		return internalSpecificTypeUnmarshal(v, typ)
+		return internalSpecificTypeUnmarshal(v, typ, depth)
 	}
 
 	if len(v.Type.SimpleType) != 0 {
@@ -455,7 +629,7 @@ func internalUnmarshal(v *internalStruct, typ reflect.Type) (any, error) {
 		}
 		result, dResult := createValueFromType(resolvePointerNum(v.Type.PointerNum, rt))
 
-		err := setStructFields(dResult, v.MapValues)
+		err := setStructFields(dResult, v.MapValues, depth)
 		if err != nil {
 			return nil, err
 		}
@@ -475,7 +649,7 @@ func internalUnmarshal(v *internalStruct, typ reflect.Type) (any, error) {
 		}
 
 		result, dResult := createValueFromType(reflect.MapOf(rkt, rvt))
-		err = setMapKVs(dResult, v.MapValues)
+		err = setMapKVs(dResult, v.MapValues, depth)
 		if err != nil {
 			return nil, err
 		}
@@ -489,31 +663,31 @@ func internalUnmarshal(v *internalStruct, typ reflect.Type) (any, error) {
 	}
 
 	result, dResult := createValueFromType(reflect.SliceOf(rvt))
-	err = setSliceElems(dResult, v.SliceValues)
+	err = setSliceElems(dResult, v.SliceValues, depth)
 	if err != nil {
 		return nil, err
 	}
 	return result.Interface(), nil
 }
 
-func internalSpecificTypeUnmarshal(is *internalStruct, typ reflect.Type) (any, error) {
+func internalSpecificTypeUnmarshal(is *internalStruct, typ reflect.Type, depth int) (any, error) {
 	_, dtyp := derefPointerNum(typ)
 	result, dResult := createValueFromType(typ)
 
 	if dtyp.Kind() == reflect.Struct {
-		err := setStructFields(dResult, is.MapValues)
+		err := setStructFields(dResult, is.MapValues, depth)
 		if err != nil {
 			return nil, err
 		}
 		return result.Interface(), nil
 	} else if dtyp.Kind() == reflect.Map {
-		err := setMapKVs(dResult, is.MapValues)
+		err := setMapKVs(dResult, is.MapValues, depth)
 		if err != nil {
 			return nil, err
 		}
 		return result.Interface(), nil
 	} else if dtyp.Kind() == reflect.Array || dtyp.Kind() == reflect.Slice {
-		err := setSliceElems(dResult, is.SliceValues)
+		err := setSliceElems(dResult, is.SliceValues, depth)
 		if err != nil {
 			return nil, err
 		}
@@ -523,12 +697,12 @@ func internalSpecificTypeUnmarshal(is *internalStruct, typ reflect.Type) (any, e
 	v := reflect.New(typ)
 	err := sonic.Unmarshal(is.JSONValue, v.Interface())
 	if err != nil {
-		return nil, fmt.Errorf("unmarshal type[%s] fail: %v", typ.String(), err)
+		return nil, fmt.Errorf("unmarshal type[%s] fail: %w", typ.String(), err)
 	}
 	return v.Elem().Interface(), nil
 }
 
-func setSliceElems(dResult reflect.Value, values []*internalStruct) error {
+func setSliceElems(dResult reflect.Value, values []*internalStruct, depth int) error {
 	t := dResult.Type()
 
 	// Handle arrays differently from slices
@@ -538,9 +712,9 @@ func setSliceElems(dResult reflect.Value, values []*internalStruct) error {
 			if i >= dResult.Len() {
 				return fmt.Errorf("array index out of bounds: trying to set index %d in array of length %d", i, dResult.Len())
 			}
-			value, err := internalUnmarshal(internalValue, t.Elem())
+			value, err := internalUnmarshal(internalValue, t.Elem(), depth-1)
 			if err != nil {
-				return fmt.Errorf("unmarshal array[%s] element %d fail: %v", t.Elem(), i, err)
+				return fmt.Errorf("unmarshal array[%s] element %d fail: %w", t.Elem(), i, err)
 			}
 			if value == nil {
 				dResult.Index(i).Set(reflect.Zero(t.Elem()))
@@ -552,10 +726,10 @@ func setSliceElems(dResult reflect.Value, values []*internalStruct) error {
 	}
 
 	// For slices, use Append as before
-	for _, internalValue := range values {
-		value, err := internalUnmarshal(internalValue, t.Elem())
+	for i, internalValue := range values {
+		value, err := internalUnmarshal(internalValue, t.Elem(), depth-1)
 		if err != nil {
-			return fmt.Errorf("unmarshal slice[%s] fail: %v", t.Elem(), err)
+			return fmt.Errorf("unmarshal slice[%s] element %d fail: %w", t.Elem(), i, err)
 		}
 		if value == nil {
 			// empty value
@@ -567,7 +741,7 @@ func setSliceElems(dResult reflect.Value, values []*internalStruct) error {
 	return nil
 }
 
-func setMapKVs(dResult reflect.Value, values map[string]*internalStruct) error {
+func setMapKVs(dResult reflect.Value, values map[string]*internalStruct, depth int) error {
 	t := dResult.Type()
 	for marshaledMapKey, internalValue := range values {
 		prkv := reflect.New(t.Key())
@@ -576,9 +750,9 @@ func setMapKVs(dResult reflect.Value, values map[string]*internalStruct) error {
 			return fmt.Errorf("unmarshal map key[%v] to type[%s] fail: %v", marshaledMapKey, t.Key(), err)
 		}
 
-		value, err := internalUnmarshal(internalValue, t.Elem())
+		value, err := internalUnmarshal(internalValue, t.Elem(), depth-1)
 		if err != nil {
-			return fmt.Errorf("unmarshal map value fail: %v", err)
+			return fmt.Errorf("unmarshal map value fail: %w", err)
 		}
 		if value == nil {
 			dResult.SetMapIndex(prkv.Elem(), reflect.New(t.Elem()).Elem())
@@ -589,16 +763,16 @@ func setMapKVs(dResult reflect.Value, values map[string]*internalStruct) error {
 	return nil
 }
 
-func setStructFields(dResult reflect.Value, values map[string]*internalStruct) error {
+func setStructFields(dResult reflect.Value, values map[string]*internalStruct, depth int) error {
 	t := dResult.Type()
 	for k, internalValue := range values {
 		sf, ok := t.FieldByName(k)
 		if !ok {
 			continue
 		}
-		value, err := internalUnmarshal(internalValue, sf.Type)
+		value, err := internalUnmarshal(internalValue, sf.Type, depth-1)
 		if err != nil {
-			return fmt.Errorf("unmarshal map field[%v] fail: %v", k, err)
+			return fmt.Errorf("unmarshal map field[%v] fail: %w", k, err)
 		}
 		err = setStructField(t, dResult, k, value)
 		if err != nil {
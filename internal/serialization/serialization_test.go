@@ -17,6 +17,7 @@
 package serialization
 
 import (
+	"bytes"
 	"reflect"
 	"testing"
 
@@ -203,6 +204,46 @@ func TestMarshalStruct(t *testing.T) {
 	}, result2)
 }
 
+func TestInternalSerializerMaxDepth(t *testing.T) {
+	nested := map[string]any{"a": map[string]any{"b": []any{map[string]any{"c": "d"}}}}
+
+	data, err := (&InternalSerializer{}).Marshal(nested)
+	assert.NoError(t, err)
+	result := map[string]any{}
+	assert.NoError(t, (&InternalSerializer{}).Unmarshal(data, &result))
+	assert.Equal(t, nested, result)
+
+	limited := &InternalSerializer{MaxDepth: 3}
+	_, err = limited.Marshal(nested)
+	assert.ErrorIs(t, err, ErrMaxDepthExceeded)
+	err = limited.Unmarshal(data, &map[string]any{})
+	assert.ErrorIs(t, err, ErrMaxDepthExceeded)
+
+	_, err = (&InternalSerializer{MaxDepth: 5}).Marshal(nested)
+	assert.NoError(t, err)
+}
+
+type unregisteredImpl struct{}
+
+func (u *unregisteredImpl) Method() {}
+
+func TestSliceElementErrors(t *testing.T) {
+	_ = GenericRegister[myStruct]("myStruct")
+	_ = GenericRegister[myInterface]("myInterface")
+	s := &InternalSerializer{}
+
+	_, err := s.Marshal([]myInterface{&myStruct{A: "1"}, nil, &unregisteredImpl{}})
+	assert.ErrorContains(t, err, "element 2 of type *serialization.unregisteredImpl")
+
+	data, err := s.Marshal([]myInterface{nil, &myStruct{A: "1"}})
+	assert.NoError(t, err)
+	// the concrete type is registered under a different name when unmarshalling
+	data = bytes.ReplaceAll(data, []byte(`"myStruct"`), []byte(`"renamedStruct"`))
+	var result []myInterface
+	err = s.Unmarshal(data, &result)
+	assert.ErrorContains(t, err, "element 1 fail: unknown type key: renamedStruct")
+}
+
 type unmarshalTestStruct struct {
 	Foo string
 	Bar int
@@ -0,0 +1,33 @@
+/*
+ * Copyright 2026 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package internal
+
+import "unicode/utf8"
+
+// IncompleteUTF8SuffixLen returns the length of the trailing bytes of s that start, but do not complete, a UTF-8 character,
+// e.g. for holding back a multi-byte character split across the chunks of a stream.
+func IncompleteUTF8SuffixLen[T string | []byte](s T) int {
+	for i := 1; i < utf8.UTFMax && i <= len(s); i++ {
+		if utf8.RuneStart(s[len(s)-i]) {
+			if utf8.FullRuneInString(string(s[len(s)-i:])) {
+				return 0
+			}
+			return i
+		}
+	}
+	return 0
+}
@@ -0,0 +1,32 @@
+/*
+ * Copyright 2026 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package internal
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIncompleteUTF8SuffixLen(t *testing.T) {
+	s := "a你" // "你" takes 3 bytes
+	for n, want := range []int{0, 0, 1, 2, 0} {
+		assert.Equal(t, want, IncompleteUTF8SuffixLen(s[:n]), s[:n])
+		assert.Equal(t, want, IncompleteUTF8SuffixLen([]byte(s[:n])), s[:n])
+	}
+	assert.Equal(t, 0, IncompleteUTF8SuffixLen("a\xff"))
+}
@@ -0,0 +1,321 @@
+/*
+ * Copyright 2026 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package schema
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"text/template"
+)
+
+// MissingKeyMode controls how a template renders a missing variable, see FormatOptions.
+type MissingKeyMode uint8
+
+const (
+	// MissingKeyDefault keeps the behavior of each format type, i.e. failing for GoTemplate,
+	// and rendering an empty string for Jinja2 and Mustache.
+	MissingKeyDefault MissingKeyMode = iota
+	// MissingKeyError makes the rendering fail on a missing variable.
+	MissingKeyError
+	// MissingKeyZero renders a missing variable as the zero value, e.g. "<no value>" for a map[string]any in GoTemplate,
+	// and an empty string in Jinja2 and Mustache.
+	MissingKeyZero
+)
+
+// FormatOptions are the package-level options of rendering templates by FormatDefault, set by SetDefaultFormatOptions.
+// FString has no notion of either option, as it always fails on a missing variable and has no functions.
+type FormatOptions struct {
+	// MissingKey controls how a missing variable is rendered by GoTemplate, Jinja2 and Mustache.
+	MissingKey MissingKeyMode
+	// Funcs are the functions callable in GoTemplate, see text/template.FuncMap, and in Jinja2 as globals,
+	// where a variable of the same name takes precedence. Mustache has no functions and ignores them.
+	Funcs map[string]any
+}
+
+var (
+	defaultFormatMu      sync.RWMutex
+	defaultFormatType    = FString
+	defaultFormatOptions FormatOptions
+)
+
+// SetDefaultFormatType sets the format type used by FormatDefault, which is FString if not set.
+// It is safe for concurrent use, though it is meant to be called once at init.
+func SetDefaultFormatType(ft FormatType) {
+	defaultFormatMu.Lock()
+	defer defaultFormatMu.Unlock()
+	defaultFormatType = ft
+}
+
+// GetDefaultFormatType returns the format type set by SetDefaultFormatType.
+func GetDefaultFormatType() FormatType {
+	defaultFormatMu.RLock()
+	defer defaultFormatMu.RUnlock()
+	return defaultFormatType
+}
+
+// SetDefaultFormatOptions sets the options used when rendering templates by FormatDefault,
+// while calling the Format method of a MessagesTemplate directly keeps the behavior of each format type.
+// It returns an error if opts.MissingKey is unknown or opts.Funcs is not a valid text/template.FuncMap,
+// e.g. a function with no return value, in which case the options in effect are kept.
+// opts.Funcs is copied, so modifying it afterwards has no effect.
+// It is safe for concurrent use, though it is meant to be called once at init.
+// e.g.
+//
+//	err := schema.SetDefaultFormatOptions(schema.FormatOptions{
+//		Funcs: map[string]any{"upper": strings.ToUpper},
+//	})
+func SetDefaultFormatOptions(opts FormatOptions) error {
+	if opts.MissingKey > MissingKeyZero {
+		return fmt.Errorf("unknown missing key mode: %d", opts.MissingKey)
+	}
+	if opts.Funcs != nil {
+		funcs := make(map[string]any, len(opts.Funcs))
+		for name, fn := range opts.Funcs {
+			funcs[name] = fn
+		}
+		if err := validateTemplateFuncs(funcs); err != nil {
+			return err
+		}
+		opts.Funcs = funcs
+	}
+
+	defaultFormatMu.Lock()
+	defer defaultFormatMu.Unlock()
+	defaultFormatOptions = opts
+	return nil
+}
+
+// validateTemplateFuncs checks funcs by text/template, which panics on an invalid function.
+func validateTemplateFuncs(funcs map[string]any) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("invalid template funcs: %v", r)
+		}
+	}()
+	template.New("").Funcs(funcs)
+	return nil
+}
+
+func getDefaultFormatOptions() FormatOptions {
+	defaultFormatMu.RLock()
+	defer defaultFormatMu.RUnlock()
+	return defaultFormatOptions
+}
+
+type formatOptionsKey struct{}
+
+// getFormatOptions returns the options carried by ctx, which are only set by FormatDefault.
+func getFormatOptions(ctx context.Context) FormatOptions {
+	if ctx == nil {
+		return FormatOptions{}
+	}
+	opts, _ := ctx.Value(formatOptionsKey{}).(FormatOptions)
+	return opts
+}
+
+// FormatDefault renders the template by the format type set by SetDefaultFormatType,
+// with the options set by SetDefaultFormatOptions.
+// The options reach the messages of m through ctx, so a custom MessagesTemplate should pass ctx on to them.
+// e.g.
+//
+//	schema.SetDefaultFormatType(schema.Jinja2)
+//	msgs, err := schema.FormatDefault(ctx, schema.UserMessage("hello, {{name}}"), map[string]any{"name": "eino"})
+func FormatDefault(ctx context.Context, m MessagesTemplate, vs map[string]any) ([]*Message, error) {
+	ctx = context.WithValue(ctx, formatOptionsKey{}, getDefaultFormatOptions())
+	return m.Format(ctx, vs, GetDefaultFormatType())
+}
+
+// templateSegment is a piece of a template, which is either literal text or a variable reference if isVar.
+type templateSegment struct {
+	text  string
+	isVar bool
+}
+
+var templateVarPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)*$`)
+
+// ConvertTemplate translates the template src from the format type from to the format type to, e.g. for migrating prompts
+// between template engines. Only literal text and simple variable references are translated, e.g. "{x}" in FString,
+// "{{x}}" in Jinja2 and Mustache, and "{{.x}}" in GoTemplate, where the variable can be a dotted path like "user.name".
+// Other constructs, e.g. loops, conditionals, filters or format specs, make it return an error,
+// and so does literal text which can't be expressed in Mustache, i.e. containing "{{".
+// e.g.
+//
+//	tpl, err := schema.ConvertTemplate("hello, {name}", schema.FString, schema.GoTemplate) // "hello, {{.name}}"
+func ConvertTemplate(src string, from, to FormatType) (string, error) {
+	if !from.Valid() {
+		return "", unsupportedFormatTypeErr(from)
+	}
+	if !to.Valid() {
+		return "", unsupportedFormatTypeErr(to)
+	}
+
+	var (
+		segments []templateSegment
+		err      error
+	)
+	if from == FString {
+		segments, err = parseFStringTemplate(src)
+	} else {
+		segments, err = parseBraceTemplate(src, from)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to convert %s template: %w", from, err)
+	}
+
+	sb := new(strings.Builder)
+	for i, seg := range segments {
+		if seg.isVar {
+			switch to {
+			case FString:
+				sb.WriteString("{" + seg.text + "}")
+			case GoTemplate:
+				sb.WriteString("{{." + seg.text + "}}")
+			default:
+				sb.WriteString("{{" + seg.text + "}}")
+			}
+			continue
+		}
+
+		beforeVar := i+1 < len(segments) && segments[i+1].isVar
+		if err = writeTemplateLiteral(sb, seg.text, to, beforeVar); err != nil {
+			return "", fmt.Errorf("failed to convert template to %s: %w", to, err)
+		}
+	}
+
+	return sb.String(), nil
+}
+
+// parseFStringTemplate parses an FString template, where "{{" and "}}" are the escaped braces.
+func parseFStringTemplate(src string) ([]templateSegment, error) {
+	var (
+		segments []templateSegment
+		literal  strings.Builder
+	)
+	for i := 0; i < len(src); i++ {
+		c := src[i]
+		switch {
+		case c == '{' && strings.HasPrefix(src[i:], "{{"), c == '}' && strings.HasPrefix(src[i:], "}}"):
+			literal.WriteByte(c)
+			i++
+		case c == '{':
+			end := strings.IndexByte(src[i:], '}')
+			if end < 0 {
+				return nil, fmt.Errorf("unclosed variable at offset %d", i)
+			}
+			name := src[i+1 : i+end]
+			if !templateVarPattern.MatchString(name) {
+				return nil, fmt.Errorf("unsupported construct %q", src[i:i+end+1])
+			}
+			if literal.Len() > 0 {
+				segments = append(segments, templateSegment{text: literal.String()})
+				literal.Reset()
+			}
+			segments = append(segments, templateSegment{text: name, isVar: true})
+			i += end
+		case c == '}':
+			return nil, fmt.Errorf("single '}' at offset %d", i)
+		default:
+			literal.WriteByte(c)
+		}
+	}
+	if literal.Len() > 0 {
+		segments = append(segments, templateSegment{text: literal.String()})
+	}
+
+	return segments, nil
+}
+
+// parseBraceTemplate parses a GoTemplate, Jinja2 or Mustache template, whose tags are delimited by "{{" and "}}".
+func parseBraceTemplate(src string, ft FormatType) ([]templateSegment, error) {
+	var segments []templateSegment
+	for len(src) > 0 {
+		start := strings.Index(src, "{{")
+		if ft == Jinja2 {
+			// statements and comments of jinja2
+			for _, delim := range []string{"{%", "{#"} {
+				if idx := strings.Index(src, delim); idx >= 0 && (start < 0 || idx < start) {
+					return nil, fmt.Errorf("unsupported construct at %q", src[idx:])
+				}
+			}
+		}
+		if start < 0 {
+			segments = append(segments, templateSegment{text: src})
+			break
+		}
+		if start > 0 {
+			segments = append(segments, templateSegment{text: src[:start]})
+		}
+		src = src[start:]
+
+		open, closing := "{{", "}}"
+		if ft == Mustache && strings.HasPrefix(src, "{{{") {
+			// unescaped variable of mustache
+			open, closing = "{{{", "}}}"
+		}
+		end := strings.Index(src[len(open):], closing)
+		if end < 0 {
+			return nil, fmt.Errorf("unclosed tag %q", src)
+		}
+		tag := src[:len(open)+end+len(closing)]
+		name := strings.TrimSpace(src[len(open) : len(open)+end])
+		src = src[len(tag):]
+
+		if ft == GoTemplate {
+			if !strings.HasPrefix(name, ".") {
+				return nil, fmt.Errorf("unsupported construct %q", tag)
+			}
+			name = name[1:]
+		}
+		if !templateVarPattern.MatchString(name) {
+			return nil, fmt.Errorf("unsupported construct %q", tag)
+		}
+		segments = append(segments, templateSegment{text: name, isVar: true})
+	}
+
+	return segments, nil
+}
+
+// writeTemplateLiteral writes text to sb as literal text of the format type ft, escaping the delimiters if needed.
+// If beforeVar, a trailing "{" is escaped too, as it would run into the delimiter of the following variable.
+func writeTemplateLiteral(sb *strings.Builder, text string, ft FormatType, beforeVar bool) error {
+	trailingBrace := beforeVar && ft != FString && strings.HasSuffix(text, "{")
+
+	switch ft {
+	case FString:
+		text = strings.NewReplacer("{", "{{", "}", "}}").Replace(text)
+	case GoTemplate:
+		text = strings.ReplaceAll(text, "{{", `{{"{{"}}`)
+		if trailingBrace && strings.HasSuffix(text, "{") {
+			text = text[:len(text)-1] + `{{"{"}}`
+		}
+	case Jinja2:
+		text = strings.NewReplacer("{{", `{{ "{{" }}`, "{%", `{{ "{%" }}`, "{#", `{{ "{#" }}`).Replace(text)
+		if trailingBrace && strings.HasSuffix(text, "{") {
+			text = text[:len(text)-1] + `{{ "{" }}`
+		}
+	case Mustache:
+		if strings.Contains(text, "{{") || trailingBrace {
+			return fmt.Errorf("literal text %q can't be expressed", text)
+		}
+	}
+
+	sb.WriteString(text)
+	return nil
+}
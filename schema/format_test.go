@@ -0,0 +1,224 @@
+/*
+ * Copyright 2026 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package schema
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/cbroglie/mustache"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormatDefault(t *testing.T) {
+	defer SetDefaultFormatType(FString)
+	defer func() { _ = SetDefaultFormatOptions(FormatOptions{}) }()
+	ctx := context.Background()
+
+	assert.Equal(t, FString, GetDefaultFormatType())
+	msgs, err := FormatDefault(ctx, UserMessage("hello, {name}"), map[string]any{"name": "eino"})
+	assert.NoError(t, err)
+	assert.Equal(t, "hello, eino", msgs[0].Content)
+
+	wg := sync.WaitGroup{}
+	wg.Add(10)
+	for i := 0; i < 10; i++ {
+		go func() {
+			defer wg.Done()
+			SetDefaultFormatType(Jinja2)
+		}()
+	}
+	wg.Wait()
+
+	msgs, err = FormatDefault(ctx, UserMessage("hello, {{name}}"), map[string]any{"name": "eino"})
+	assert.NoError(t, err)
+	assert.Equal(t, "hello, eino", msgs[0].Content)
+
+	SetDefaultFormatType(GoTemplate)
+	_, err = FormatDefault(ctx, UserMessage("hello, {{.name}}"), map[string]any{})
+	assert.Error(t, err)
+
+	err = SetDefaultFormatOptions(FormatOptions{
+		MissingKey: MissingKeyZero,
+		Funcs:      map[string]any{"upper": strings.ToUpper},
+	})
+	assert.NoError(t, err)
+	msgs, err = FormatDefault(ctx, UserMessage("hello, {{upper .name}}{{.missing}}"), map[string]any{"name": "eino"})
+	assert.NoError(t, err)
+	assert.Equal(t, "hello, EINO<no value>", msgs[0].Content)
+}
+
+func TestSetDefaultFormatOptions(t *testing.T) {
+	defer SetDefaultFormatType(FString)
+	defer func() { _ = SetDefaultFormatOptions(FormatOptions{}) }()
+	ctx := context.Background()
+	format := func(tpl MessagesTemplate, vs map[string]any, ft FormatType) ([]*Message, error) {
+		SetDefaultFormatType(ft)
+		return FormatDefault(ctx, tpl, vs)
+	}
+
+	t.Run("jinja2", func(t *testing.T) {
+		tpl := UserMessage("hello, {{ upper(name) }}{{ missing }}")
+		vs := map[string]any{"name": "eino"}
+
+		funcs := map[string]any{"upper": strings.ToUpper}
+		assert.NoError(t, SetDefaultFormatOptions(FormatOptions{Funcs: funcs}))
+		funcs["upper"] = strings.ToLower
+		msgs, err := format(tpl, vs, Jinja2)
+		assert.NoError(t, err)
+		assert.Equal(t, "hello, EINO", msgs[0].Content)
+
+		assert.NoError(t, SetDefaultFormatOptions(FormatOptions{MissingKey: MissingKeyError, Funcs: funcs}))
+		_, err = format(tpl, vs, Jinja2)
+		assert.Error(t, err)
+		msgs, err = format(tpl, map[string]any{"name": "eino", "missing": "!"}, Jinja2)
+		assert.NoError(t, err)
+		assert.Equal(t, "hello, eino!", msgs[0].Content)
+
+		// the options are per call, and don't leak into the shared environment
+		assert.NoError(t, SetDefaultFormatOptions(FormatOptions{}))
+		msgs, err = format(UserMessage("hello, {{ name }}{{ missing }}"), vs, Jinja2)
+		assert.NoError(t, err)
+		assert.Equal(t, "hello, eino", msgs[0].Content)
+	})
+
+	t.Run("mustache", func(t *testing.T) {
+		tpl := UserMessage("hello, {{name}}{{missing}}")
+		vs := map[string]any{"name": "eino"}
+
+		assert.NoError(t, SetDefaultFormatOptions(FormatOptions{MissingKey: MissingKeyError}))
+		_, err := format(tpl, vs, Mustache)
+		assert.ErrorContains(t, err, `missing variable "missing"`)
+		// the global switch of mustache is left alone
+		assert.True(t, mustache.AllowMissingVariables)
+
+		for src, wantErr := range map[string]bool{
+			"{{#user}}{{name}} {{age}}{{/user}}":                   false, // looked up in the section, then the outer context
+			"{{#user}}{{email}}{{/user}}":                          true,
+			"{{user.age}}{{user.email}}":                           true,
+			"{{#items}}{{title}}{{/items}}":                        false,
+			"{{#items}}{{.}}{{score}}{{/items}}":                   true,
+			"{{#empty}}{{missing}}{{/empty}}{{^empty}}-{{/empty}}": false, // not rendered
+			"{{^user}}{{missing}}{{/user}}":                        false,
+			"{{^empty}}{{missing}}{{/empty}}":                      true,
+			"{{#missing}}x{{/missing}}":                            true,
+		} {
+			_, err = format(UserMessage(src), map[string]any{
+				"name":  "eino",
+				"user":  map[string]any{"age": 1},
+				"items": []map[string]string{{"title": "a"}, {"title": "b"}},
+				"empty": []string{},
+			}, Mustache)
+			assert.Equal(t, wantErr, err != nil, src)
+		}
+
+		assert.NoError(t, SetDefaultFormatOptions(FormatOptions{MissingKey: MissingKeyZero}))
+		msgs, err := format(tpl, vs, Mustache)
+		assert.NoError(t, err)
+		assert.Equal(t, "hello, eino", msgs[0].Content)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		assert.NoError(t, SetDefaultFormatOptions(FormatOptions{Funcs: map[string]any{"upper": strings.ToUpper}}))
+
+		err := SetDefaultFormatOptions(FormatOptions{Funcs: map[string]any{"noop": func() {}}})
+		assert.Error(t, err)
+		err = SetDefaultFormatOptions(FormatOptions{Funcs: map[string]any{"bad name": strings.ToUpper}})
+		assert.Error(t, err)
+		err = SetDefaultFormatOptions(FormatOptions{MissingKey: MissingKeyZero + 1})
+		assert.Error(t, err)
+
+		// the options in effect are kept
+		msgs, err := format(UserMessage("{{upper .name}}"), map[string]any{"name": "eino"}, GoTemplate)
+		assert.NoError(t, err)
+		assert.Equal(t, "EINO", msgs[0].Content)
+	})
+
+	t.Run("format", func(t *testing.T) {
+		assert.NoError(t, SetDefaultFormatOptions(FormatOptions{
+			MissingKey: MissingKeyZero,
+			Funcs:      map[string]any{"upper": strings.ToUpper},
+		}))
+
+		// calling Format directly keeps the behavior of each format type
+		_, err := UserMessage("{{upper .name}}").Format(ctx, map[string]any{"name": "eino"}, GoTemplate)
+		assert.Error(t, err)
+		_, err = UserMessage("{{.missing}}").Format(ctx, map[string]any{}, GoTemplate)
+		assert.Error(t, err)
+
+		// the messages in a chat template formatted by FormatDefault get the options
+		tpl := &conditionalMessage{condKey: "on", msg: UserMessage("{{upper .name}}{{.missing}}")}
+		msgs, err := format(tpl, map[string]any{"on": true, "name": "eino"}, GoTemplate)
+		assert.NoError(t, err)
+		assert.Equal(t, "EINO<no value>", msgs[0].Content)
+	})
+}
+
+func TestConvertTemplate(t *testing.T) {
+	templates := map[FormatType]string{
+		FString:    "input: {question}",
+		Jinja2:     "input: {{question}}",
+		GoTemplate: "input: {{.question}}",
+	}
+	for from, src := range templates {
+		for to, want := range templates {
+			got, err := ConvertTemplate(src, from, to)
+			assert.NoError(t, err)
+			assert.Equal(t, want, got, "%s to %s", from, to)
+		}
+	}
+
+	got, err := ConvertTemplate("{{ user.name }}: {{{ question }}}", Mustache, FString)
+	assert.NoError(t, err)
+	assert.Equal(t, "{user.name}: {question}", got)
+
+	// literal braces are escaped, so that the rendered output is kept
+	vs := map[string]any{"question": "why"}
+	src := "json: {{\"a\": {question}}}, {{{question}}}"
+	want, err := formatContent(src, vs, FString, FormatOptions{})
+	assert.NoError(t, err)
+	for _, to := range []FormatType{FString, GoTemplate, Jinja2} {
+		converted, err := ConvertTemplate(src, FString, to)
+		assert.NoError(t, err)
+		out, err := formatContent(converted, vs, to, FormatOptions{})
+		assert.NoError(t, err)
+		assert.Equal(t, want, out, "to %s: %s", to, converted)
+	}
+	_, err = ConvertTemplate(src, FString, Mustache)
+	assert.ErrorContains(t, err, "can't be expressed")
+
+	for _, tt := range []struct {
+		src  string
+		from FormatType
+	}{
+		{"{% for x in items %}{{x}}{% endfor %}", Jinja2},
+		{"{{ name | upper }}", Jinja2},
+		{"{{range .items}}{{.}}{{end}}", GoTemplate},
+		{"{{if .ok}}yes{{end}}", GoTemplate},
+		{"{{#items}}{{name}}{{/items}}", Mustache},
+		{"{price:.2f}", FString},
+		{"{unclosed", FString},
+	} {
+		_, err = ConvertTemplate(tt.src, tt.from, FString)
+		assert.Error(t, err, tt.src)
+	}
+
+	_, err = ConvertTemplate("x", FString, FormatType(9))
+	assert.Error(t, err)
+}
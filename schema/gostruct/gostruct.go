@@ -0,0 +1,249 @@
+/*
+ * Copyright 2026 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package gostruct generates go struct types from tool parameter schemas.
+package gostruct
+
+import (
+	"errors"
+	"fmt"
+	"go/format"
+	"go/token"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/eino-contrib/jsonschema"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+// Generate generates the go source of a struct type named typeName describing the parameters,
+// e.g. to use a dynamically loaded schema with utils.InferTool, which infers back an equivalent schema.
+// The nested objects with properties are generated as separate struct types named after their parent and property,
+// e.g. "SearchFilter" for the property "filter" of "Search", and referenced by pointers.
+// Objects without properties are generated as maps, and arrays as slices.
+// The optional properties get 'omitempty' in their json tags, and the descriptions and string enums are kept in jsonschema tags.
+// The schema should be self-contained, i.e. $ref is not supported, and the combinators like anyOf are generated as any.
+// The returned source has no package clause and is gofmt-ed.
+// e.g.
+//
+//	src, err := gostruct.Generate(toolInfo.ParamsOneOf, "SearchInput")
+func Generate(p *schema.ParamsOneOf, typeName string) (string, error) {
+	if !token.IsIdentifier(typeName) {
+		return "", fmt.Errorf("invalid type name: %q", typeName)
+	}
+
+	js, err := p.ToJSONSchema()
+	if err != nil {
+		return "", err
+	}
+	if js == nil {
+		return "", errors.New("params is nil")
+	}
+	if js.Type != "" && js.Type != string(schema.Object) {
+		return "", fmt.Errorf("params must be an object, got %s", js.Type)
+	}
+
+	g := &goStructGenerator{typeNames: map[string]bool{}}
+	if err = g.genStruct(typeName, js); err != nil {
+		return "", err
+	}
+
+	src, err := format.Source([]byte(g.sb.String()))
+	if err != nil {
+		return "", fmt.Errorf("failed to format generated source: %w", err)
+	}
+
+	return strings.TrimRight(string(src), "\n") + "\n", nil
+}
+
+type goStructGenerator struct {
+	sb        strings.Builder
+	typeNames map[string]bool
+}
+
+func (g *goStructGenerator) genStruct(typeName string, sc *jsonschema.Schema) error {
+	if g.typeNames[typeName] {
+		return fmt.Errorf("duplicate generated type name: %s", typeName)
+	}
+	g.typeNames[typeName] = true
+
+	required := make(map[string]bool, len(sc.Required))
+	for _, name := range sc.Required {
+		required[name] = true
+	}
+
+	var (
+		fields     strings.Builder
+		nested     []func() error
+		fieldNames = map[string]bool{}
+	)
+	var props []string
+	if sc.Properties != nil {
+		for pair := sc.Properties.Oldest(); pair != nil; pair = pair.Next() {
+			props = append(props, pair.Key)
+		}
+	}
+
+	for _, name := range props {
+		prop, _ := sc.Properties.Get(name)
+
+		fieldName := goFieldName(name)
+		for i := 2; fieldNames[fieldName]; i++ {
+			fieldName = goFieldName(name) + strconv.Itoa(i)
+		}
+		fieldNames[fieldName] = true
+
+		goType, gen, err := g.goType(typeName+fieldName, prop)
+		if err != nil {
+			return fmt.Errorf("property %q: %w", name, err)
+		}
+		if gen != nil {
+			nested = append(nested, gen)
+		}
+
+		jsonTag := name
+		if !required[name] {
+			jsonTag += ",omitempty"
+		}
+		tags := []string{"json:" + strconv.Quote(jsonTag)}
+		if prop != nil && prop.Description != "" {
+			tags = append(tags, "jsonschema_description:"+strconv.Quote(strings.ReplaceAll(prop.Description, "`", "'")))
+		}
+		if enum := goEnumTag(prop); enum != "" {
+			tags = append(tags, "jsonschema:"+strconv.Quote(enum))
+		}
+
+		fmt.Fprintf(&fields, "\t%s %s `%s`\n", fieldName, goType, strings.Join(tags, " "))
+	}
+
+	if sc.Description != "" {
+		for _, line := range strings.Split(sc.Description, "\n") {
+			fmt.Fprintf(&g.sb, "// %s\n", line)
+		}
+	}
+	fmt.Fprintf(&g.sb, "type %s struct {\n%s}\n\n", typeName, fields.String())
+
+	for _, gen := range nested {
+		if err := gen(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// goType returns the go type of sc, and the generator of the struct type if sc is an object with properties.
+func (g *goStructGenerator) goType(nestedName string, sc *jsonschema.Schema) (string, func() error, error) {
+	if sc == nil {
+		return "any", nil, nil
+	}
+	if sc.Ref != "" {
+		return "", nil, fmt.Errorf("$ref is not supported: %s", sc.Ref)
+	}
+
+	typ, nullable := sc.Type, false
+	if typ == "" && len(sc.TypeEnhanced) > 0 {
+		var nonNull []string
+		for _, t := range sc.TypeEnhanced {
+			if t == string(schema.Null) {
+				nullable = true
+			} else {
+				nonNull = append(nonNull, t)
+			}
+		}
+		if len(nonNull) == 1 {
+			typ = nonNull[0]
+		}
+	}
+
+	var goType string
+	switch schema.DataType(typ) {
+	case schema.String:
+		goType = "string"
+	case schema.Integer:
+		goType = "int64"
+	case schema.Number:
+		goType = "float64"
+	case schema.Boolean:
+		goType = "bool"
+	case schema.Array:
+		elem, elemGen, err := g.goType(nestedName+"Item", sc.Items)
+		if err != nil {
+			return "", nil, err
+		}
+		return "[]" + elem, elemGen, nil
+	case schema.Object:
+		if sc.Properties != nil && sc.Properties.Len() > 0 {
+			return "*" + nestedName, func() error {
+				return g.genStruct(nestedName, sc)
+			}, nil
+		}
+		value, valueGen, err := g.goType(nestedName+"Value", sc.AdditionalProperties)
+		if err != nil {
+			return "", nil, err
+		}
+		return "map[string]" + value, valueGen, nil
+	default:
+		return "any", nil, nil
+	}
+
+	if nullable {
+		goType = "*" + goType
+	}
+	return goType, nil, nil
+}
+
+// goEnumTag returns the jsonschema tag of the enum values of a string schema, or "" if they can't be expressed in the tag.
+func goEnumTag(sc *jsonschema.Schema) string {
+	if sc == nil || len(sc.Enum) == 0 {
+		return ""
+	}
+
+	values := make([]string, 0, len(sc.Enum))
+	for _, e := range sc.Enum {
+		s, ok := e.(string)
+		if !ok || s == "" || strings.ContainsAny(s, ",=`\"") {
+			return ""
+		}
+		values = append(values, "enum="+s)
+	}
+	return strings.Join(values, ",")
+}
+
+// goFieldName converts a json property name to an exported go identifier, e.g. "user_name" to "UserName".
+func goFieldName(name string) string {
+	var sb strings.Builder
+	upper := true
+	for _, r := range name {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			upper = true
+			continue
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+		sb.WriteRune(r)
+	}
+
+	s := sb.String()
+	if s == "" || !unicode.IsUpper([]rune(s)[0]) {
+		s = "F" + s
+	}
+	return s
+}
@@ -0,0 +1,89 @@
+/*
+ * Copyright 2026 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gostruct
+
+import (
+	"go/parser"
+	"go/token"
+	"testing"
+
+	"github.com/eino-contrib/jsonschema"
+	"github.com/stretchr/testify/assert"
+	orderedmap "github.com/wk8/go-ordered-map/v2"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+func TestGenerate(t *testing.T) {
+	params := schema.NewParamsOneOfByParams(map[string]*schema.ParameterInfo{
+		"city": {Type: schema.String, Desc: "the city name", Required: true},
+		"days": {Type: schema.Integer},
+	})
+
+	src, err := Generate(params, "WeatherInput")
+	assert.NoError(t, err)
+	assert.Equal(t, "type WeatherInput struct {\n"+
+		"\tCity string `json:\"city\" jsonschema_description:\"the city name\"`\n"+
+		"\tDays int64  `json:\"days,omitempty\"`\n"+
+		"}\n", src)
+
+	_, err = parser.ParseFile(token.NewFileSet(), "gen.go", "package gen\n\n"+src, 0)
+	assert.NoError(t, err)
+
+	t.Run("nested", func(t *testing.T) {
+		params := schema.NewParamsOneOfByParams(map[string]*schema.ParameterInfo{
+			"query": {Type: schema.String, Enum: []string{"web", "news"}, Required: true},
+			"filter": {Type: schema.Object, SubParams: map[string]*schema.ParameterInfo{
+				"site_name": {Type: schema.String},
+				"tags":      {Type: schema.Array, ElemInfo: &schema.ParameterInfo{Type: schema.String}},
+			}},
+			"results": {Type: schema.Array, ElemInfo: &schema.ParameterInfo{Type: schema.Object, SubParams: map[string]*schema.ParameterInfo{
+				"score": {Type: schema.Number, Required: true},
+			}}},
+			"meta": {Type: schema.Object},
+		})
+
+		src, err := Generate(params, "SearchInput")
+		assert.NoError(t, err)
+		assert.Equal(t, "type SearchInput struct {\n"+
+			"\tFilter  *SearchInputFilter        `json:\"filter,omitempty\"`\n"+
+			"\tMeta    map[string]any            `json:\"meta,omitempty\"`\n"+
+			"\tQuery   string                    `json:\"query\" jsonschema:\"enum=web,enum=news\"`\n"+
+			"\tResults []*SearchInputResultsItem `json:\"results,omitempty\"`\n"+
+			"}\n\n"+
+			"type SearchInputFilter struct {\n"+
+			"\tSiteName string   `json:\"site_name,omitempty\"`\n"+
+			"\tTags     []string `json:\"tags,omitempty\"`\n"+
+			"}\n\n"+
+			"type SearchInputResultsItem struct {\n"+
+			"\tScore float64 `json:\"score\"`\n"+
+			"}\n", src)
+
+		_, err = parser.ParseFile(token.NewFileSet(), "gen.go", "package gen\n\n"+src, 0)
+		assert.NoError(t, err)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		_, err := Generate(params, "not a name")
+		assert.Error(t, err)
+
+		_, err = Generate(schema.NewParamsOneOfByJSONSchema(&jsonschema.Schema{Type: string(schema.Object), Properties: orderedmap.New[string, *jsonschema.Schema](
+			orderedmap.WithInitialData(orderedmap.Pair[string, *jsonschema.Schema]{Key: "user", Value: &jsonschema.Schema{Ref: "#/$defs/User"}}),
+		)}), "Input")
+		assert.ErrorContains(t, err, "$ref")
+	})
+}
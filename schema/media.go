@@ -0,0 +1,434 @@
+/*
+ * Copyright 2026 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package schema
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"image/jpeg"
+	"image/png"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Image formats recognized by MessageInputImage.Validate.
+const (
+	ImageFormatPNG  = "png"
+	ImageFormatJPEG = "jpeg"
+	ImageFormatWebP = "webp"
+)
+
+type imageValidateOptions struct {
+	allowedFormats []string
+	maxWidth       int
+	maxHeight      int
+}
+
+// ImageValidateOption is the option for MessageInputImage.Validate.
+type ImageValidateOption func(*imageValidateOptions)
+
+// WithAllowedImageFormats sets the image formats accepted by Validate.
+// Default is ImageFormatPNG, ImageFormatJPEG and ImageFormatWebP.
+func WithAllowedImageFormats(formats ...string) ImageValidateOption {
+	return func(o *imageValidateOptions) {
+		o.allowedFormats = formats
+	}
+}
+
+// WithMaxImageSize sets the max width and height in pixels accepted by Validate.
+// A non-positive value means no limit.
+func WithMaxImageSize(width, height int) ImageValidateOption {
+	return func(o *imageValidateOptions) {
+		o.maxWidth = width
+		o.maxHeight = height
+	}
+}
+
+// Validate checks the image before it is sent to a model, to catch the images rejected by providers earlier.
+// For an image carrying Base64Data, it decodes the image header to check that the format is allowed,
+// that the format matches MIMEType if set, and that the size is within the limits set by WithMaxImageSize.
+// Images only carrying a URL are not checked.
+// e.g.
+//
+//	err := img.Validate(schema.WithMaxImageSize(2048, 2048))
+func (img *MessageInputImage) Validate(opts ...ImageValidateOption) error {
+	if img == nil {
+		return fmt.Errorf("image is nil")
+	}
+	if img.Base64Data == nil {
+		if img.URL == nil || *img.URL == "" {
+			return fmt.Errorf("neither url nor base64 data is set for image")
+		}
+		return nil
+	}
+
+	o := &imageValidateOptions{
+		allowedFormats: []string{ImageFormatPNG, ImageFormatJPEG, ImageFormatWebP},
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	data, err := base64.StdEncoding.DecodeString(*img.Base64Data)
+	if err != nil {
+		return fmt.Errorf("failed to decode base64 data of image: %w", err)
+	}
+
+	format, width, height, err := decodeImageHeader(data)
+	if err != nil {
+		return err
+	}
+
+	allowed := false
+	for _, f := range o.allowedFormats {
+		if f == format {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		return fmt.Errorf("image format %s is not allowed, allowed formats: %v", format, o.allowedFormats)
+	}
+
+	if img.MIMEType != "" && img.MIMEType != "image/"+format && !(format == ImageFormatJPEG && img.MIMEType == "image/jpg") {
+		return fmt.Errorf("image mime type %s mismatches the actual format %s", img.MIMEType, format)
+	}
+
+	if o.maxWidth > 0 && width > o.maxWidth {
+		return fmt.Errorf("image width %d exceeds max width %d", width, o.maxWidth)
+	}
+	if o.maxHeight > 0 && height > o.maxHeight {
+		return fmt.Errorf("image height %d exceeds max height %d", height, o.maxHeight)
+	}
+
+	return nil
+}
+
+var (
+	pngMagic  = []byte("\x89PNG\r\n\x1a\n")
+	jpegMagic = []byte{0xff, 0xd8, 0xff}
+)
+
+// decodeImageHeader detects the format of the image and decodes its size from the header.
+func decodeImageHeader(data []byte) (format string, width, height int, err error) {
+	switch {
+	case bytes.HasPrefix(data, pngMagic):
+		cfg, e := png.DecodeConfig(bytes.NewReader(data))
+		if e != nil {
+			return "", 0, 0, fmt.Errorf("failed to decode png header: %w", e)
+		}
+		return ImageFormatPNG, cfg.Width, cfg.Height, nil
+	case bytes.HasPrefix(data, jpegMagic):
+		cfg, e := jpeg.DecodeConfig(bytes.NewReader(data))
+		if e != nil {
+			return "", 0, 0, fmt.Errorf("failed to decode jpeg header: %w", e)
+		}
+		return ImageFormatJPEG, cfg.Width, cfg.Height, nil
+	case len(data) >= 12 && string(data[:4]) == "RIFF" && string(data[8:12]) == "WEBP":
+		width, height, err = decodeWebPSize(data)
+		if err != nil {
+			return "", 0, 0, err
+		}
+		return ImageFormatWebP, width, height, nil
+	default:
+		return "", 0, 0, fmt.Errorf("unsupported image format")
+	}
+}
+
+func decodeWebPSize(data []byte) (width, height int, err error) {
+	if len(data) < 30 {
+		return 0, 0, fmt.Errorf("failed to decode webp header: data too short")
+	}
+
+	switch string(data[12:16]) {
+	case "VP8X":
+		width = int(uint32(data[24])|uint32(data[25])<<8|uint32(data[26])<<16) + 1
+		height = int(uint32(data[27])|uint32(data[28])<<8|uint32(data[29])<<16) + 1
+	case "VP8L":
+		if data[20] != 0x2f {
+			return 0, 0, fmt.Errorf("failed to decode webp header: invalid lossless signature")
+		}
+		bits := binary.LittleEndian.Uint32(data[21:25])
+		width = int(bits&0x3fff) + 1
+		height = int((bits>>14)&0x3fff) + 1
+	case "VP8 ":
+		if !bytes.Equal(data[23:26], []byte{0x9d, 0x01, 0x2a}) {
+			return 0, 0, fmt.Errorf("failed to decode webp header: invalid lossy start code")
+		}
+		width = int(binary.LittleEndian.Uint16(data[26:28]) & 0x3fff)
+		height = int(binary.LittleEndian.Uint16(data[28:30]) & 0x3fff)
+	default:
+		return 0, 0, fmt.Errorf("failed to decode webp header: unknown chunk %q", data[12:16])
+	}
+
+	return width, height, nil
+}
+
+const dataURIPrefix = "data:"
+
+// ToDataURI returns the image as a data URI in the form of "data:image/png;base64,...",
+// built from Base64Data and MIMEType. If the image only carries a URL which is already a data URI, the URL is returned.
+func (img *MessageInputImage) ToDataURI() (string, error) {
+	if img == nil {
+		return "", fmt.Errorf("image is nil")
+	}
+
+	if img.Base64Data != nil && *img.Base64Data != "" {
+		if img.MIMEType == "" {
+			return "", fmt.Errorf("mime type is required to build data uri")
+		}
+		return dataURIPrefix + img.MIMEType + ";base64," + *img.Base64Data, nil
+	}
+
+	if img.URL != nil && strings.HasPrefix(*img.URL, dataURIPrefix) {
+		return *img.URL, nil
+	}
+
+	return "", fmt.Errorf("image has no base64 data")
+}
+
+// ImagePartFromDataURI parses a base64 encoded data URI such as "data:image/png;base64,..."
+// and returns an image MessageInputPart carrying its Base64Data and MIMEType.
+func ImagePartFromDataURI(uri string) (MessageInputPart, error) {
+	if !strings.HasPrefix(uri, dataURIPrefix) {
+		return MessageInputPart{}, fmt.Errorf("invalid data uri: missing %q prefix", dataURIPrefix)
+	}
+
+	meta, data, found := strings.Cut(uri[len(dataURIPrefix):], ",")
+	if !found {
+		return MessageInputPart{}, fmt.Errorf("invalid data uri: missing ','")
+	}
+
+	params := strings.Split(meta, ";")
+	if params[len(params)-1] != "base64" {
+		return MessageInputPart{}, fmt.Errorf("invalid data uri: only base64 encoding is supported")
+	}
+
+	mimeType := params[0]
+	if !strings.HasPrefix(mimeType, "image/") {
+		return MessageInputPart{}, fmt.Errorf("invalid data uri: unexpected mime type %q for image", mimeType)
+	}
+
+	if _, err := base64.StdEncoding.DecodeString(data); err != nil {
+		return MessageInputPart{}, fmt.Errorf("invalid data uri: failed to decode base64 data: %w", err)
+	}
+
+	return MessageInputPart{
+		Type: ChatMessagePartTypeImageURL,
+		Image: &MessageInputImage{
+			MessagePartCommon: MessagePartCommon{
+				Base64Data: &data,
+				MIMEType:   mimeType,
+			},
+		},
+	}, nil
+}
+
+// ImagePartFromFile reads a local image file and returns an image MessageInputPart carrying its base64 data.
+// The MIME type is detected from the file extension, or from the content if the extension is unknown.
+// e.g.
+//
+//	part, err := schema.ImagePartFromFile("./cat.png")
+//	msg := &schema.Message{
+//		Role:                  schema.User,
+//		UserInputMultiContent: []schema.MessageInputPart{part},
+//	}
+func ImagePartFromFile(path string) (MessageInputPart, error) {
+	common, err := loadMediaFile(path)
+	if err != nil {
+		return MessageInputPart{}, err
+	}
+
+	return MessageInputPart{
+		Type:  ChatMessagePartTypeImageURL,
+		Image: &MessageInputImage{MessagePartCommon: common},
+	}, nil
+}
+
+// AudioPartFromFile reads a local audio file and returns an audio MessageInputPart carrying its base64 data.
+// The MIME type is detected in the same way as ImagePartFromFile.
+func AudioPartFromFile(path string) (MessageInputPart, error) {
+	common, err := loadMediaFile(path)
+	if err != nil {
+		return MessageInputPart{}, err
+	}
+
+	return MessageInputPart{
+		Type:  ChatMessagePartTypeAudioURL,
+		Audio: &MessageInputAudio{MessagePartCommon: common},
+	}, nil
+}
+
+// VideoPartFromFile reads a local video file and returns a video MessageInputPart carrying its base64 data.
+// The MIME type is detected in the same way as ImagePartFromFile.
+func VideoPartFromFile(path string) (MessageInputPart, error) {
+	common, err := loadMediaFile(path)
+	if err != nil {
+		return MessageInputPart{}, err
+	}
+
+	return MessageInputPart{
+		Type:  ChatMessagePartTypeVideoURL,
+		Video: &MessageInputVideo{MessagePartCommon: common},
+	}, nil
+}
+
+// FilePartFromFile reads a local file and returns a file MessageInputPart carrying its base64 data and file name.
+// The MIME type is detected in the same way as ImagePartFromFile.
+func FilePartFromFile(path string) (MessageInputPart, error) {
+	common, err := loadMediaFile(path)
+	if err != nil {
+		return MessageInputPart{}, err
+	}
+
+	return MessageInputPart{
+		Type: ChatMessagePartTypeFileURL,
+		File: &MessageInputFile{
+			MessagePartCommon: common,
+			Name:              filepath.Base(path),
+		},
+	}, nil
+}
+
+func loadMediaFile(path string) (MessagePartCommon, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return MessagePartCommon{}, fmt.Errorf("failed to read media file %s: %w", path, err)
+	}
+
+	mimeType := mime.TypeByExtension(filepath.Ext(path))
+	if mimeType == "" {
+		mimeType = http.DetectContentType(data)
+	}
+	if mediaType, _, e := mime.ParseMediaType(mimeType); e == nil {
+		mimeType = mediaType
+	}
+
+	b64 := base64.StdEncoding.EncodeToString(data)
+
+	return MessagePartCommon{
+		Base64Data: &b64,
+		MIMEType:   mimeType,
+	}, nil
+}
+
+// MediaURLs returns the URLs of all image, audio, video and file parts of the message in order,
+// across the deprecated MultiContent, UserInputMultiContent and AssistantGenMultiContent.
+// Parts carrying only base64 data are skipped.
+// It is useful to validate or prefetch the media before sending the message.
+// It returns nil for a nil message.
+func (m *Message) MediaURLs() []string {
+	if m == nil {
+		return nil
+	}
+
+	var urls []string
+	add := func(url string) {
+		if url != "" {
+			urls = append(urls, url)
+		}
+	}
+	addCommon := func(common *MessagePartCommon) {
+		if common.URL != nil {
+			add(*common.URL)
+		}
+	}
+
+	for _, part := range m.MultiContent {
+		switch {
+		case part.ImageURL != nil:
+			add(part.ImageURL.URL)
+		case part.AudioURL != nil:
+			add(part.AudioURL.URL)
+		case part.VideoURL != nil:
+			add(part.VideoURL.URL)
+		case part.FileURL != nil:
+			add(part.FileURL.URL)
+		}
+	}
+
+	for _, part := range m.UserInputMultiContent {
+		switch {
+		case part.Image != nil:
+			addCommon(&part.Image.MessagePartCommon)
+		case part.Audio != nil:
+			addCommon(&part.Audio.MessagePartCommon)
+		case part.Video != nil:
+			addCommon(&part.Video.MessagePartCommon)
+		case part.File != nil:
+			addCommon(&part.File.MessagePartCommon)
+		}
+	}
+
+	for _, part := range m.AssistantGenMultiContent {
+		switch {
+		case part.Image != nil:
+			addCommon(&part.Image.MessagePartCommon)
+		case part.Audio != nil:
+			addCommon(&part.Audio.MessagePartCommon)
+		case part.Video != nil:
+			addCommon(&part.Video.MessagePartCommon)
+		}
+	}
+
+	return urls
+}
+
+// Modalities returns the sorted distinct modalities used by the message, e.g. ["image", "text"],
+// across Content, the deprecated MultiContent, UserInputMultiContent and AssistantGenMultiContent,
+// where the modality of a part is its type without the "_url" suffix, i.e. one of "text", "image", "audio", "video" and "file".
+// It is useful to route the message to a model capable of all its modalities.
+// It returns nil for a nil message.
+func (m *Message) Modalities() []string {
+	if m == nil {
+		return nil
+	}
+
+	set := make(map[string]bool)
+	add := func(typ ChatMessagePartType) {
+		if typ != "" {
+			set[strings.TrimSuffix(string(typ), "_url")] = true
+		}
+	}
+
+	if m.Content != "" {
+		add(ChatMessagePartTypeText)
+	}
+	for _, part := range m.MultiContent {
+		add(part.Type)
+	}
+	for _, part := range m.UserInputMultiContent {
+		add(part.Type)
+	}
+	for _, part := range m.AssistantGenMultiContent {
+		add(part.Type)
+	}
+
+	ret := make([]string, 0, len(set))
+	for modality := range set {
+		ret = append(ret, modality)
+	}
+	sort.Strings(ret)
+
+	return ret
+}
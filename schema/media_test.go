@@ -0,0 +1,253 @@
+/*
+ * Copyright 2026 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package schema
+
+import (
+	"bytes"
+	"encoding/base64"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func encodeTestPNG(t *testing.T, width, height int) string {
+	buf := &bytes.Buffer{}
+	err := png.Encode(buf, image.NewRGBA(image.Rect(0, 0, width, height)))
+	assert.NoError(t, err)
+	return base64.StdEncoding.EncodeToString(buf.Bytes())
+}
+
+func TestMessageInputImageValidate(t *testing.T) {
+	pngData := encodeTestPNG(t, 20, 10)
+
+	t.Run("valid png", func(t *testing.T) {
+		img := &MessageInputImage{MessagePartCommon: MessagePartCommon{Base64Data: &pngData, MIMEType: "image/png"}}
+		assert.NoError(t, img.Validate())
+		assert.NoError(t, img.Validate(WithMaxImageSize(20, 10)))
+	})
+
+	t.Run("oversized", func(t *testing.T) {
+		img := &MessageInputImage{MessagePartCommon: MessagePartCommon{Base64Data: &pngData}}
+		err := img.Validate(WithMaxImageSize(16, 0))
+		assert.ErrorContains(t, err, "image width 20 exceeds max width 16")
+		err = img.Validate(WithMaxImageSize(0, 8))
+		assert.ErrorContains(t, err, "image height 10 exceeds max height 8")
+	})
+
+	t.Run("format not allowed", func(t *testing.T) {
+		img := &MessageInputImage{MessagePartCommon: MessagePartCommon{Base64Data: &pngData}}
+		err := img.Validate(WithAllowedImageFormats(ImageFormatJPEG))
+		assert.ErrorContains(t, err, "image format png is not allowed")
+	})
+
+	t.Run("mime type mismatch", func(t *testing.T) {
+		img := &MessageInputImage{MessagePartCommon: MessagePartCommon{Base64Data: &pngData, MIMEType: "image/jpeg"}}
+		assert.Error(t, img.Validate())
+	})
+
+	t.Run("unsupported format", func(t *testing.T) {
+		gifData := base64.StdEncoding.EncodeToString([]byte("GIF89a\x01\x00\x01\x00\x00\x00\x00"))
+		img := &MessageInputImage{MessagePartCommon: MessagePartCommon{Base64Data: &gifData}}
+		assert.ErrorContains(t, img.Validate(), "unsupported image format")
+	})
+
+	t.Run("webp", func(t *testing.T) {
+		header := []byte("RIFF\x00\x00\x00\x00WEBPVP8X\x0a\x00\x00\x00\x00\x00\x00\x00")
+		header = append(header, 0x1f, 0x00, 0x00, 0x0f, 0x00, 0x00) // 32x16
+		webpData := base64.StdEncoding.EncodeToString(header)
+		img := &MessageInputImage{MessagePartCommon: MessagePartCommon{Base64Data: &webpData, MIMEType: "image/webp"}}
+		assert.NoError(t, img.Validate(WithMaxImageSize(32, 16)))
+		assert.Error(t, img.Validate(WithMaxImageSize(31, 16)))
+	})
+
+	t.Run("invalid base64", func(t *testing.T) {
+		invalid := "!!!"
+		img := &MessageInputImage{MessagePartCommon: MessagePartCommon{Base64Data: &invalid}}
+		assert.Error(t, img.Validate())
+	})
+
+	t.Run("url only", func(t *testing.T) {
+		url := "https://example.com/cat.png"
+		img := &MessageInputImage{MessagePartCommon: MessagePartCommon{URL: &url}}
+		assert.NoError(t, img.Validate(WithMaxImageSize(1, 1)))
+	})
+}
+
+func TestMediaPartFromFile(t *testing.T) {
+	dir := t.TempDir()
+
+	pngData := encodeTestPNG(t, 2, 2)
+	rawPNG, err := base64.StdEncoding.DecodeString(pngData)
+	assert.NoError(t, err)
+
+	imagePath := filepath.Join(dir, "cat.png")
+	assert.NoError(t, os.WriteFile(imagePath, rawPNG, 0o600))
+	noExtPath := filepath.Join(dir, "cat")
+	assert.NoError(t, os.WriteFile(noExtPath, rawPNG, 0o600))
+	textPath := filepath.Join(dir, "notes.txt")
+	assert.NoError(t, os.WriteFile(textPath, []byte("hello"), 0o600))
+
+	t.Run("image", func(t *testing.T) {
+		part, err := ImagePartFromFile(imagePath)
+		assert.NoError(t, err)
+		assert.Equal(t, ChatMessagePartTypeImageURL, part.Type)
+		assert.Equal(t, "image/png", part.Image.MIMEType)
+		assert.Equal(t, pngData, *part.Image.Base64Data)
+		assert.NoError(t, part.Image.Validate())
+	})
+
+	t.Run("detect from content", func(t *testing.T) {
+		part, err := ImagePartFromFile(noExtPath)
+		assert.NoError(t, err)
+		assert.Equal(t, "image/png", part.Image.MIMEType)
+	})
+
+	t.Run("file", func(t *testing.T) {
+		part, err := FilePartFromFile(textPath)
+		assert.NoError(t, err)
+		assert.Equal(t, ChatMessagePartTypeFileURL, part.Type)
+		assert.Equal(t, "text/plain", part.File.MIMEType)
+		assert.Equal(t, "notes.txt", part.File.Name)
+		assert.Equal(t, base64.StdEncoding.EncodeToString([]byte("hello")), *part.File.Base64Data)
+	})
+
+	t.Run("audio and video", func(t *testing.T) {
+		part, err := AudioPartFromFile(textPath)
+		assert.NoError(t, err)
+		assert.Equal(t, ChatMessagePartTypeAudioURL, part.Type)
+		assert.NotNil(t, part.Audio.Base64Data)
+
+		part, err = VideoPartFromFile(textPath)
+		assert.NoError(t, err)
+		assert.Equal(t, ChatMessagePartTypeVideoURL, part.Type)
+		assert.NotNil(t, part.Video.Base64Data)
+	})
+
+	t.Run("missing file", func(t *testing.T) {
+		_, err := ImagePartFromFile(filepath.Join(dir, "missing.png"))
+		assert.ErrorIs(t, err, os.ErrNotExist)
+		assert.ErrorContains(t, err, "missing.png")
+	})
+}
+
+func TestImageDataURI(t *testing.T) {
+	b64 := encodeTestPNG(t, 2, 2)
+	img := &MessageInputImage{
+		MessagePartCommon: MessagePartCommon{Base64Data: &b64, MIMEType: "image/png"},
+	}
+
+	uri, err := img.ToDataURI()
+	assert.NoError(t, err)
+	assert.Equal(t, "data:image/png;base64,"+b64, uri)
+
+	part, err := ImagePartFromDataURI(uri)
+	assert.NoError(t, err)
+	assert.Equal(t, ChatMessagePartTypeImageURL, part.Type)
+	assert.Equal(t, "image/png", part.Image.MIMEType)
+	assert.Equal(t, b64, *part.Image.Base64Data)
+	assert.NoError(t, part.Image.Validate())
+
+	roundTrip, err := part.Image.ToDataURI()
+	assert.NoError(t, err)
+	assert.Equal(t, uri, roundTrip)
+
+	t.Run("url only", func(t *testing.T) {
+		_, err := (&MessageInputImage{MessagePartCommon: MessagePartCommon{URL: &uri}}).ToDataURI()
+		assert.NoError(t, err)
+
+		url := "https://example.com/cat.png"
+		_, err = (&MessageInputImage{MessagePartCommon: MessagePartCommon{URL: &url}}).ToDataURI()
+		assert.Error(t, err)
+	})
+
+	t.Run("missing mime type", func(t *testing.T) {
+		_, err := (&MessageInputImage{MessagePartCommon: MessagePartCommon{Base64Data: &b64}}).ToDataURI()
+		assert.Error(t, err)
+	})
+
+	t.Run("invalid uri", func(t *testing.T) {
+		for _, u := range []string{
+			"https://example.com/cat.png",
+			"data:image/png;base64",
+			"data:image/png," + b64,
+			"data:text/plain;base64," + b64,
+			"data:image/png;base64,!!!",
+		} {
+			_, err := ImagePartFromDataURI(u)
+			assert.Error(t, err, u)
+		}
+	})
+}
+
+func TestMessageMediaURLs(t *testing.T) {
+	imgURL := "https://example.com/cat.png"
+	audioURL := "https://example.com/hi.wav"
+	fileURL := "https://example.com/doc.pdf"
+	genURL := "https://example.com/gen.png"
+	b64 := encodeTestPNG(t, 1, 1)
+
+	msg := &Message{
+		MultiContent: []ChatMessagePart{
+			{Type: ChatMessagePartTypeText, Text: "hello"},
+			{Type: ChatMessagePartTypeVideoURL, VideoURL: &ChatMessageVideoURL{URL: "https://example.com/old.mp4"}},
+		},
+		UserInputMultiContent: []MessageInputPart{
+			{Type: ChatMessagePartTypeImageURL, Image: &MessageInputImage{MessagePartCommon: MessagePartCommon{URL: &imgURL}}},
+			{Type: ChatMessagePartTypeImageURL, Image: &MessageInputImage{MessagePartCommon: MessagePartCommon{Base64Data: &b64, MIMEType: "image/png"}}},
+			{Type: ChatMessagePartTypeText, Text: "describe"},
+			{Type: ChatMessagePartTypeAudioURL, Audio: &MessageInputAudio{MessagePartCommon: MessagePartCommon{URL: &audioURL}}},
+			{Type: ChatMessagePartTypeFileURL, File: &MessageInputFile{MessagePartCommon: MessagePartCommon{URL: &fileURL}}},
+		},
+		AssistantGenMultiContent: []MessageOutputPart{
+			{Type: ChatMessagePartTypeImageURL, Image: &MessageOutputImage{MessagePartCommon: MessagePartCommon{URL: &genURL}}},
+		},
+	}
+
+	assert.Equal(t, []string{"https://example.com/old.mp4", imgURL, audioURL, fileURL, genURL}, msg.MediaURLs())
+	assert.Empty(t, UserMessage("text only").MediaURLs())
+
+	var nilMsg *Message
+	assert.Nil(t, nilMsg.MediaURLs())
+}
+
+func TestMessageModalities(t *testing.T) {
+	url := "https://example.com/cat.png"
+	msg := &Message{
+		Role:    User,
+		Content: "what's in it?",
+		UserInputMultiContent: []MessageInputPart{
+			{Type: ChatMessagePartTypeImageURL, Image: &MessageInputImage{MessagePartCommon: MessagePartCommon{URL: &url}}},
+			{Type: ChatMessagePartTypeAudioURL, Audio: &MessageInputAudio{}},
+			{Type: ChatMessagePartTypeImageURL, Image: &MessageInputImage{}},
+		},
+		MultiContent: []ChatMessagePart{{Type: ChatMessagePartTypeText, Text: "legacy"}},
+	}
+	assert.Equal(t, []string{"audio", "image", "text"}, msg.Modalities())
+
+	assert.Equal(t, []string{"video"}, (&Message{
+		Role:                     Assistant,
+		AssistantGenMultiContent: []MessageOutputPart{{Type: ChatMessagePartTypeVideoURL}},
+	}).Modalities())
+	assert.Empty(t, (&Message{Role: User}).Modalities())
+
+	var nilMsg *Message
+	assert.Nil(t, nilMsg.Modalities())
+}
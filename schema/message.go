@@ -18,6 +18,7 @@ package schema
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	"reflect"
@@ -25,9 +26,12 @@ import (
 	"strings"
 	"sync"
 	"text/template"
+	"time"
 
+	"github.com/cbroglie/mustache"
 	"github.com/nikolalohinski/gonja"
 	"github.com/nikolalohinski/gonja/config"
+	"github.com/nikolalohinski/gonja/exec"
 	"github.com/nikolalohinski/gonja/nodes"
 	"github.com/nikolalohinski/gonja/parser"
 	"github.com/slongfield/pyfmt"
@@ -93,8 +97,41 @@ const (
 	GoTemplate FormatType = 1
 	// Jinja2 Supported by gonja(github.com/nikolalohinski/gonja), which is a implementation of https://jinja.palletsprojects.com/en/3.1.x/templates/.
 	Jinja2 FormatType = 2
+	// Mustache Supported by mustache(github.com/cbroglie/mustache), which is an implementation of https://mustache.github.io/mustache.5.html.
+	// Variables are rendered without HTML escaping, and partials are disabled.
+	Mustache FormatType = 3
 )
 
+// String returns the name of the format type, e.g. "FString", or "FormatType(N)" for an unsupported value.
+func (ft FormatType) String() string {
+	switch ft {
+	case FString:
+		return "FString"
+	case GoTemplate:
+		return "GoTemplate"
+	case Jinja2:
+		return "Jinja2"
+	case Mustache:
+		return "Mustache"
+	default:
+		return fmt.Sprintf("FormatType(%d)", uint8(ft))
+	}
+}
+
+// Valid reports whether the format type is one of FString, GoTemplate, Jinja2 and Mustache.
+func (ft FormatType) Valid() bool {
+	switch ft {
+	case FString, GoTemplate, Jinja2, Mustache:
+		return true
+	default:
+		return false
+	}
+}
+
+func unsupportedFormatTypeErr(ft FormatType) error {
+	return fmt.Errorf("unsupported format type: %d", uint8(ft))
+}
+
 // RoleType is the type of the role of a message.
 type RoleType string
 
@@ -273,8 +310,24 @@ const (
 
 	// ToolPartTypeFile means the part is a file url.
 	ToolPartTypeFile ToolPartType = "file"
+
+	// ToolPartTypeProgress means the part is a progress report of a long-running tool, e.g. "25% done".
+	// It is transient: ConcatToolResults keeps only the latest one, and ToMessageInputParts skips it.
+	ToolPartTypeProgress ToolPartType = "progress"
+
+	// ToolPartTypeJSON means the part is structured JSON data, which the model should reason over as data rather than prose.
+	// In streaming, the JSON can be split into fragments across chunks, which ConcatToolResults reassembles.
+	ToolPartTypeJSON ToolPartType = "json"
 )
 
+// ToolProgress is the progress report of a long-running tool.
+type ToolProgress struct {
+	// Percent is the completion percentage, from 0 to 100.
+	Percent float64 `json:"percent,omitempty"`
+	// Message describes the current progress.
+	Message string `json:"message,omitempty"`
+}
+
 // ToolOutputImage represents an image in tool output.
 // It contains URL or Base64-encoded data along with MIME type information.
 type ToolOutputImage struct {
@@ -321,6 +374,12 @@ type ToolOutputPart struct {
 	// File is the file content, used when Type is ToolPartTypeFile.
 	File *ToolOutputFile `json:"file,omitempty"`
 
+	// Progress is the progress report, used when Type is ToolPartTypeProgress.
+	Progress *ToolProgress `json:"progress,omitempty"`
+
+	// JSON is the structured JSON data, used when Type is ToolPartTypeJSON.
+	JSON json.RawMessage `json:"json,omitempty"`
+
 	// Extra is used to store extra information.
 	Extra map[string]any `json:"extra,omitempty"`
 }
@@ -339,6 +398,196 @@ type ToolResult struct {
 	// Parts contains the multimodal output parts. Each part can be a different
 	// type of content, like text, an image, or a file.
 	Parts []ToolOutputPart `json:"parts,omitempty"`
+
+	// Sources contains the sources the output is based on, e.g. the pages returned by a search tool.
+	Sources []Source `json:"sources,omitempty"`
+
+	// Meta is the execution status of the tool, e.g. set by the enhanced tools created by components/tool/utils.
+	// In streaming, it is usually carried by the last chunk, e.g. a chunk with only the Meta appended by the enhanced streamable tools created with utils.WithResultMeta.
+	Meta *ToolResultMeta `json:"meta,omitempty"`
+}
+
+// ToolResultMeta is the execution status of a tool, for the observability of tools.
+type ToolResultMeta struct {
+	// Success reports whether the tool execution succeeded.
+	Success bool `json:"success"`
+	// ErrorCode is the code of the error if the execution failed, defined by the tool.
+	ErrorCode string `json:"error_code,omitempty"`
+	// Duration is the time the execution took.
+	Duration time.Duration `json:"duration,omitempty"`
+}
+
+// Source is a reference to where the tool output comes from, used for source attribution.
+type Source struct {
+	// URL is the location of the source, it identifies the source.
+	URL string `json:"url,omitempty"`
+	// Title is the title of the source.
+	Title string `json:"title,omitempty"`
+	// Snippet is the excerpt of the source that the output is based on.
+	Snippet string `json:"snippet,omitempty"`
+}
+
+// String returns the string representation of the tool result.
+// e.g.
+//
+//	tr := &schema.ToolResult{
+//		Parts:   []schema.ToolOutputPart{{Type: schema.ToolPartTypeText, Text: "sunny"}},
+//		Sources: []schema.Source{{URL: "https://weather.com", Title: "Weather"}},
+//	}
+//	fmt.Println(tr.String())
+//	Output will be:
+//		parts:
+//		  [0] text: sunny
+//		sources:
+//		  [0] Weather (https://weather.com)
+func (tr *ToolResult) String() string {
+	if tr == nil {
+		return "<nil>"
+	}
+
+	sb := &strings.Builder{}
+	sb.WriteString("parts:")
+	for i, part := range tr.Parts {
+		sb.WriteString(fmt.Sprintf("\n  [%d] %s", i, formatToolOutputPart(part)))
+	}
+
+	if len(tr.Sources) > 0 {
+		sb.WriteString("\nsources:")
+		for i, src := range tr.Sources {
+			sb.WriteString(fmt.Sprintf("\n  [%d] %s (%s)", i, src.Title, src.URL))
+			if src.Snippet != "" {
+				sb.WriteString(fmt.Sprintf(": %s", src.Snippet))
+			}
+		}
+	}
+
+	return sb.String()
+}
+
+// ToolOutputPartsToMessageOutputParts converts the parts of a tool output to the parts of an assistant-generated message,
+// e.g. to fold a ToolResult into an assistant message. MessagePartCommon and Extra of the parts are preserved.
+// Text, image, audio and video parts are mapped to their counterparts, and JSON parts are converted to text parts.
+// File parts are skipped as MessageOutputPart has no file content, and so are the transient progress parts.
+func ToolOutputPartsToMessageOutputParts(parts []ToolOutputPart) []MessageOutputPart {
+	if parts == nil {
+		return nil
+	}
+
+	ret := make([]MessageOutputPart, 0, len(parts))
+	for _, part := range parts {
+		p := MessageOutputPart{Extra: part.Extra}
+		switch part.Type {
+		case ToolPartTypeText:
+			p.Type, p.Text = ChatMessagePartTypeText, part.Text
+		case ToolPartTypeJSON:
+			p.Type, p.Text = ChatMessagePartTypeText, string(part.JSON)
+		case ToolPartTypeImage:
+			p.Type = ChatMessagePartTypeImageURL
+			if part.Image != nil {
+				p.Image = &MessageOutputImage{MessagePartCommon: part.Image.MessagePartCommon}
+			}
+		case ToolPartTypeAudio:
+			p.Type = ChatMessagePartTypeAudioURL
+			if part.Audio != nil {
+				p.Audio = &MessageOutputAudio{MessagePartCommon: part.Audio.MessagePartCommon}
+			}
+		case ToolPartTypeVideo:
+			p.Type = ChatMessagePartTypeVideoURL
+			if part.Video != nil {
+				p.Video = &MessageOutputVideo{MessagePartCommon: part.Video.MessagePartCommon}
+			}
+		default:
+			continue
+		}
+		ret = append(ret, p)
+	}
+
+	return ret
+}
+
+// MessageOutputPartsToToolOutputParts converts the parts of an assistant-generated message to the parts of a tool output,
+// which is the reverse of ToolOutputPartsToMessageOutputParts. MessagePartCommon and Extra of the parts are preserved.
+// Text, image, audio and video parts are mapped to their counterparts, and parts of other types are skipped.
+func MessageOutputPartsToToolOutputParts(parts []MessageOutputPart) []ToolOutputPart {
+	if parts == nil {
+		return nil
+	}
+
+	ret := make([]ToolOutputPart, 0, len(parts))
+	for _, part := range parts {
+		p := ToolOutputPart{Extra: part.Extra}
+		switch part.Type {
+		case ChatMessagePartTypeText:
+			p.Type, p.Text = ToolPartTypeText, part.Text
+		case ChatMessagePartTypeImageURL:
+			p.Type = ToolPartTypeImage
+			if part.Image != nil {
+				p.Image = &ToolOutputImage{MessagePartCommon: part.Image.MessagePartCommon}
+			}
+		case ChatMessagePartTypeAudioURL:
+			p.Type = ToolPartTypeAudio
+			if part.Audio != nil {
+				p.Audio = &ToolOutputAudio{MessagePartCommon: part.Audio.MessagePartCommon}
+			}
+		case ChatMessagePartTypeVideoURL:
+			p.Type = ToolPartTypeVideo
+			if part.Video != nil {
+				p.Video = &ToolOutputVideo{MessagePartCommon: part.Video.MessagePartCommon}
+			}
+		default:
+			continue
+		}
+		ret = append(ret, p)
+	}
+
+	return ret
+}
+
+// MergeNamedToolResults merges the results of several named tools into one ToolResult, e.g. in fan-in agents.
+// The results are merged in the order of their names. For each result, its text parts are joined by newlines
+// and prefixed with the label of its name in one text part, followed by its other parts, e.g. images, as is.
+// The sources of all results are concatenated, while Meta is not merged. Nil results are skipped.
+// If labelFn is nil, the label is the name in square brackets.
+// e.g.
+//
+//	merged := schema.MergeNamedToolResults(map[string]*schema.ToolResult{"weather": wr, "news": nr},
+//		func(name string) string { return "## " + name })
+//	// merged.Parts[0].Text is "## news\n<text of nr>"
+func MergeNamedToolResults(results map[string]*ToolResult, labelFn func(name string) string) *ToolResult {
+	if labelFn == nil {
+		labelFn = func(name string) string {
+			return "[" + name + "]"
+		}
+	}
+
+	names := make([]string, 0, len(results))
+	for name, r := range results {
+		if r != nil {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	merged := &ToolResult{}
+	for _, name := range names {
+		r := results[name]
+
+		texts := []string{labelFn(name)}
+		var others []ToolOutputPart
+		for _, part := range r.Parts {
+			if part.Type == ToolPartTypeText {
+				texts = append(texts, part.Text)
+			} else {
+				others = append(others, part)
+			}
+		}
+
+		merged.Parts = append(merged.Parts, ToolOutputPart{Type: ToolPartTypeText, Text: strings.Join(texts, "\n")})
+		merged.Parts = append(merged.Parts, others...)
+		merged.Sources = append(merged.Sources, r.Sources...)
+	}
+
+	return merged
 }
 
 func convToolOutputPartToMessageInputPart(toolPart ToolOutputPart) (MessageInputPart, error) {
@@ -380,6 +629,11 @@ func convToolOutputPartToMessageInputPart(toolPart ToolOutputPart) (MessageInput
 			Type: ChatMessagePartTypeFileURL,
 			File: &MessageInputFile{MessagePartCommon: toolPart.File.MessagePartCommon},
 		}, nil
+	case ToolPartTypeJSON:
+		return MessageInputPart{
+			Type: ChatMessagePartTypeText,
+			Text: string(toolPart.JSON),
+		}, nil
 	default:
 		return MessageInputPart{}, fmt.Errorf("unknown tool part type: %v", toolPart.Type)
 	}
@@ -392,7 +646,8 @@ func convToolOutputPartToMessageInputPart(toolPart ToolOutputPart) (MessageInput
 //   - None (method receiver is *ToolResult)
 //
 // Returns:
-//   - []MessageInputPart: The converted message input parts that can be used in a Message.
+//   - []MessageInputPart: The converted message input parts that can be used in a Message. Progress parts are skipped,
+//     and JSON parts are converted to text parts.
 //   - error: An error if conversion fails due to unknown part types or nil content fields.
 //
 // Example:
@@ -408,17 +663,46 @@ func (tr *ToolResult) ToMessageInputParts() ([]MessageInputPart, error) {
 	if tr == nil || len(tr.Parts) == 0 {
 		return nil, nil
 	}
-	result := make([]MessageInputPart, len(tr.Parts))
-	for i, part := range tr.Parts {
-		var err error
-		result[i], err = convToolOutputPartToMessageInputPart(part)
+	result := make([]MessageInputPart, 0, len(tr.Parts))
+	for _, part := range tr.Parts {
+		if part.Type == ToolPartTypeProgress {
+			continue
+		}
+		inputPart, err := convToolOutputPartToMessageInputPart(part)
 		if err != nil {
 			return nil, err
 		}
+		result = append(result, inputPart)
 	}
 	return result, nil
 }
 
+// ToolResultStreamToText converts a stream of ToolResult, e.g. returned by an EnhancedStreamableTool,
+// to a stream of text, for consumers that only handle text.
+// The text parts of each ToolResult are joined into one chunk, while the non-text parts are skipped.
+// ToolResults without any text part are omitted from the returned stream.
+func ToolResultStreamToText(sr *StreamReader[*ToolResult]) *StreamReader[string] {
+	return StreamReaderWithConvert(sr, func(tr *ToolResult) (string, error) {
+		if tr == nil {
+			return "", ErrNoValue
+		}
+
+		var sb strings.Builder
+		hasText := false
+		for _, part := range tr.Parts {
+			if part.Type == ToolPartTypeText {
+				sb.WriteString(part.Text)
+				hasText = true
+			}
+		}
+		if !hasText {
+			return "", ErrNoValue
+		}
+
+		return sb.String(), nil
+	})
+}
+
 // Deprecated: This struct is deprecated as the MultiContent field is deprecated.
 // For the image input part of the model, use MessageInputImage.
 // For the image output part of the model, use MessageOutputImage.
@@ -564,6 +848,24 @@ type TopLogProb struct {
 	Bytes []int64 `json:"bytes,omitempty"`
 }
 
+// MinConfidenceToken returns the content token with the lowest LogProb, i.e. the one the model was least confident about,
+// which helps to flag uncertain outputs. The first one is returned on ties.
+// ok is false if there is no content token.
+func (lp *LogProbs) MinConfidenceToken() (token LogProb, ok bool) {
+	if lp == nil || len(lp.Content) == 0 {
+		return LogProb{}, false
+	}
+
+	minIdx := 0
+	for i := 1; i < len(lp.Content); i++ {
+		if lp.Content[i].LogProb < lp.Content[minIdx].LogProb {
+			minIdx = i
+		}
+	}
+
+	return lp.Content[minIdx], true
+}
+
 // ResponseMeta collects meta information about a chat response.
 type ResponseMeta struct {
 	// FinishReason is the reason why the chat response is finished.
@@ -649,6 +951,72 @@ type Message struct {
 
 	// customized information for model implementation
 	Extra map[string]any `json:"extra,omitempty"`
+
+	// RawResponse is the raw JSON returned by the model provider, kept as is for debugging parsing discrepancies.
+	// When concatenating stream chunks, the last non-empty RawResponse is kept, since raw chunks are separate JSON documents.
+	RawResponse json.RawMessage `json:"raw_response,omitempty"`
+
+	// Annotations are the annotations of the content returned by the model, e.g. url citations of web search.
+	// When concatenating stream chunks, the annotations of all chunks are kept in order.
+	Annotations []Annotation `json:"annotations,omitempty"`
+
+	// Prefix marks an assistant message as the prefill of the response, which the model continues from
+	// instead of starting a new message. It should be set only on the last message of the input.
+	// Only supported by some providers, see ToOpenAIJSON and ToAnthropicBlocks.
+	Prefix bool `json:"prefix,omitempty"`
+
+	// CacheControl marks the message as a breakpoint of prompt caching, e.g. at the end of a large static system prompt,
+	// so that the provider can cache the prompt up to and including this message.
+	// Only supported by some providers, see ToAnthropicBlocks, and ignored by the others.
+	CacheControl *CacheControl `json:"cache_control,omitempty"`
+
+	// CreatedAt is the time the message was created, e.g. when the chunk was received from the model, for analytics like first-token latency.
+	// It is a pointer so that it is omitted from JSON when unset.
+	// When concatenating stream chunks, the timestamp is picked by WithCreatedAtPolicy, the first one by default.
+	CreatedAt *time.Time `json:"created_at,omitempty"`
+
+	// ModelName is the name of the model producing the message, set by the chat model implementation,
+	// e.g. for debugging and cost attribution in flows mixing multiple models.
+	// When concatenating stream chunks, the first non-empty one is kept.
+	ModelName string `json:"model_name,omitempty"`
+}
+
+// CacheControlTypeEphemeral is the cache control type of a short-lived cache, which is the default.
+const CacheControlTypeEphemeral = "ephemeral"
+
+// CacheControl is the prompt caching hint of a message.
+type CacheControl struct {
+	// Type is the type of the cache, defaults to CacheControlTypeEphemeral if empty.
+	Type string `json:"type,omitempty"`
+	// TTL is the time to live of the cache, the provider default is used if zero.
+	TTL time.Duration `json:"ttl,omitempty"`
+}
+
+// AnnotationType is the type of Annotation.
+type AnnotationType string
+
+const (
+	// AnnotationTypeURLCitation means the annotation cites a web resource.
+	AnnotationTypeURLCitation AnnotationType = "url_citation"
+)
+
+// Annotation is an annotation of the message content, e.g. a url citation.
+type Annotation struct {
+	// Type is the type of the annotation.
+	Type AnnotationType `json:"type"`
+
+	// URL is the url of the cited resource, used when Type is AnnotationTypeURLCitation.
+	URL string `json:"url,omitempty"`
+	// Title is the title of the cited resource, used when Type is AnnotationTypeURLCitation.
+	Title string `json:"title,omitempty"`
+
+	// StartIndex is the index of the first character of the annotated span in the content.
+	StartIndex int `json:"start_index,omitempty"`
+	// EndIndex is the index of the last character of the annotated span in the content.
+	EndIndex int `json:"end_index,omitempty"`
+
+	// Extra is used to store extra information of the annotation.
+	Extra map[string]any `json:"extra,omitempty"`
 }
 
 // TokenUsage Represents the token usage of chat model request.
@@ -747,13 +1115,65 @@ func (p *messagesPlaceholder) Format(_ context.Context, vs map[string]any, _ For
 	return msgs, nil
 }
 
-func formatContent(content string, vs map[string]any, formatType FormatType) (string, error) {
+type conditionalMessage struct {
+	condKey string
+	msg     *Message
+}
+
+// ConditionalMessage renders msg only when the value of condKey in params is truthy,
+// i.e. present and not nil, false, zero, or an empty string, slice or map. Otherwise, nothing is rendered.
+// It is useful for optional instructions.
+// e.g.
+//
+//	chatTemplate := prompt.FromMessages(schema.FString,
+//		schema.SystemMessage("you are eino helper"),
+//		schema.ConditionalMessage("style", schema.SystemMessage("answer in {style} style")), // <= only rendered if "style" is set
+//		schema.UserMessage("{query}"),
+//	)
+func ConditionalMessage(condKey string, msg *Message) MessagesTemplate {
+	return &conditionalMessage{
+		condKey: condKey,
+		msg:     msg,
+	}
+}
+
+// Format renders the message by the given formatType if the condition key is truthy, otherwise returns no message.
+func (c *conditionalMessage) Format(ctx context.Context, vs map[string]any, formatType FormatType) ([]*Message, error) {
+	if !isTruthy(vs[c.condKey]) {
+		return []*Message{}, nil
+	}
+
+	return c.msg.Format(ctx, vs, formatType)
+}
+
+func isTruthy(v any) bool {
+	if v == nil {
+		return false
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Map, reflect.Array, reflect.String, reflect.Chan:
+		return rv.Len() > 0
+	case reflect.Ptr, reflect.Interface:
+		return !rv.IsNil()
+	default:
+		return !rv.IsZero()
+	}
+}
+
+func formatContent(content string, vs map[string]any, formatType FormatType, opts FormatOptions) (string, error) {
 	switch formatType {
 	case FString:
 		return pyfmt.Fmt(content, vs)
 	case GoTemplate:
+		missingKey := "missingkey=error"
+		if opts.MissingKey == MissingKeyZero {
+			missingKey = "missingkey=zero"
+		}
 		parsedTmpl, err := template.New("template").
-			Option("missingkey=error").
+			Option(missingKey).
+			Funcs(opts.Funcs).
 			Parse(content)
 		if err != nil {
 			return "", err
@@ -769,7 +1189,15 @@ func formatContent(content string, vs map[string]any, formatType FormatType) (st
 		if err != nil {
 			return "", err
 		}
-		tpl, err := env.FromString(content)
+		evalCfg := env.EvalConfig
+		if opts.MissingKey == MissingKeyError || len(opts.Funcs) > 0 {
+			evalCfg = env.EvalConfig.Inherit()
+			evalCfg.StrictUndefined = opts.MissingKey == MissingKeyError
+			if len(opts.Funcs) > 0 {
+				evalCfg.Globals = env.Globals.Inherit().Update(opts.Funcs)
+			}
+		}
+		tpl, err := exec.NewTemplate("string", content, evalCfg)
 		if err != nil {
 			return "", err
 		}
@@ -778,8 +1206,15 @@ func formatContent(content string, vs map[string]any, formatType FormatType) (st
 			return "", err
 		}
 		return out, nil
+	case Mustache:
+		// prompts are not html, so variables are not escaped
+		tpl, err := mustache.ParseStringPartialsRaw(content, disabledMustachePartials{}, true)
+		if err != nil {
+			return "", err
+		}
+		return renderMustache(tpl, vs, opts.MissingKey == MissingKeyError)
 	default:
-		return "", fmt.Errorf("unknown format type: %v", formatType)
+		return "", unsupportedFormatTypeErr(formatType)
 	}
 }
 
@@ -789,8 +1224,14 @@ func formatContent(content string, vs map[string]any, formatType FormatType) (st
 //	msg := schema.UserMessage("hello world, {name}")
 //	msgs, err := msg.Format(ctx, map[string]any{"name": "eino"}, schema.FString) // <= this will render the content of msg by pyfmt
 //	// msgs[0].Content will be "hello world, eino"
-func (m *Message) Format(_ context.Context, vs map[string]any, formatType FormatType) ([]*Message, error) {
-	c, err := formatContent(m.Content, vs, formatType)
+func (m *Message) Format(ctx context.Context, vs map[string]any, formatType FormatType) ([]*Message, error) {
+	if !formatType.Valid() {
+		return nil, unsupportedFormatTypeErr(formatType)
+	}
+
+	opts := getFormatOptions(ctx)
+
+	c, err := formatContent(m.Content, vs, formatType, opts)
 	if err != nil {
 		return nil, err
 	}
@@ -798,14 +1239,14 @@ func (m *Message) Format(_ context.Context, vs map[string]any, formatType Format
 	copied.Content = c
 
 	if len(m.MultiContent) > 0 {
-		copied.MultiContent, err = formatMultiContent(m.MultiContent, vs, formatType)
+		copied.MultiContent, err = formatMultiContent(m.MultiContent, vs, formatType, opts)
 		if err != nil {
 			return nil, err
 		}
 	}
 
 	if len(m.UserInputMultiContent) > 0 {
-		copied.UserInputMultiContent, err = formatUserInputMultiContent(m.UserInputMultiContent, vs, formatType)
+		copied.UserInputMultiContent, err = formatUserInputMultiContent(m.UserInputMultiContent, vs, formatType, opts)
 		if err != nil {
 			return nil, err
 		}
@@ -814,14 +1255,14 @@ func (m *Message) Format(_ context.Context, vs map[string]any, formatType Format
 	return []*Message{&copied}, nil
 }
 
-func formatMultiContent(multiContent []ChatMessagePart, vs map[string]any, formatType FormatType) ([]ChatMessagePart, error) {
+func formatMultiContent(multiContent []ChatMessagePart, vs map[string]any, formatType FormatType, opts FormatOptions) ([]ChatMessagePart, error) {
 	copiedMC := make([]ChatMessagePart, len(multiContent))
 	copy(copiedMC, multiContent)
 
 	for i, mc := range copiedMC {
 		switch mc.Type {
 		case ChatMessagePartTypeText:
-			nmc, err := formatContent(mc.Text, vs, formatType)
+			nmc, err := formatContent(mc.Text, vs, formatType, opts)
 			if err != nil {
 				return nil, err
 			}
@@ -830,7 +1271,7 @@ func formatMultiContent(multiContent []ChatMessagePart, vs map[string]any, forma
 			if mc.ImageURL == nil {
 				continue
 			}
-			url, err := formatContent(mc.ImageURL.URL, vs, formatType)
+			url, err := formatContent(mc.ImageURL.URL, vs, formatType, opts)
 			if err != nil {
 				return nil, err
 			}
@@ -839,7 +1280,7 @@ func formatMultiContent(multiContent []ChatMessagePart, vs map[string]any, forma
 			if mc.AudioURL == nil {
 				continue
 			}
-			url, err := formatContent(mc.AudioURL.URL, vs, formatType)
+			url, err := formatContent(mc.AudioURL.URL, vs, formatType, opts)
 			if err != nil {
 				return nil, err
 			}
@@ -848,7 +1289,7 @@ func formatMultiContent(multiContent []ChatMessagePart, vs map[string]any, forma
 			if mc.VideoURL == nil {
 				continue
 			}
-			url, err := formatContent(mc.VideoURL.URL, vs, formatType)
+			url, err := formatContent(mc.VideoURL.URL, vs, formatType, opts)
 			if err != nil {
 				return nil, err
 			}
@@ -857,7 +1298,7 @@ func formatMultiContent(multiContent []ChatMessagePart, vs map[string]any, forma
 			if mc.FileURL == nil {
 				continue
 			}
-			url, err := formatContent(mc.FileURL.URL, vs, formatType)
+			url, err := formatContent(mc.FileURL.URL, vs, formatType, opts)
 			if err != nil {
 				return nil, err
 			}
@@ -868,14 +1309,14 @@ func formatMultiContent(multiContent []ChatMessagePart, vs map[string]any, forma
 	return copiedMC, nil
 }
 
-func formatUserInputMultiContent(userInputMultiContent []MessageInputPart, vs map[string]any, formatType FormatType) ([]MessageInputPart, error) {
+func formatUserInputMultiContent(userInputMultiContent []MessageInputPart, vs map[string]any, formatType FormatType, opts FormatOptions) ([]MessageInputPart, error) {
 	copiedUIMC := make([]MessageInputPart, len(userInputMultiContent))
 	copy(copiedUIMC, userInputMultiContent)
 
 	for i, uimc := range copiedUIMC {
 		switch uimc.Type {
 		case ChatMessagePartTypeText:
-			text, err := formatContent(uimc.Text, vs, formatType)
+			text, err := formatContent(uimc.Text, vs, formatType, opts)
 			if err != nil {
 				return nil, err
 			}
@@ -885,14 +1326,14 @@ func formatUserInputMultiContent(userInputMultiContent []MessageInputPart, vs ma
 				continue
 			}
 			if uimc.Image.URL != nil && *uimc.Image.URL != "" {
-				url, err := formatContent(*uimc.Image.URL, vs, formatType)
+				url, err := formatContent(*uimc.Image.URL, vs, formatType, opts)
 				if err != nil {
 					return nil, err
 				}
 				copiedUIMC[i].Image.URL = &url
 			}
 			if uimc.Image.Base64Data != nil && *uimc.Image.Base64Data != "" {
-				base64data, err := formatContent(*uimc.Image.Base64Data, vs, formatType)
+				base64data, err := formatContent(*uimc.Image.Base64Data, vs, formatType, opts)
 				if err != nil {
 					return nil, err
 				}
@@ -903,14 +1344,14 @@ func formatUserInputMultiContent(userInputMultiContent []MessageInputPart, vs ma
 				continue
 			}
 			if uimc.Audio.URL != nil && *uimc.Audio.URL != "" {
-				url, err := formatContent(*uimc.Audio.URL, vs, formatType)
+				url, err := formatContent(*uimc.Audio.URL, vs, formatType, opts)
 				if err != nil {
 					return nil, err
 				}
 				copiedUIMC[i].Audio.URL = &url
 			}
 			if uimc.Audio.Base64Data != nil && *uimc.Audio.Base64Data != "" {
-				base64data, err := formatContent(*uimc.Audio.Base64Data, vs, formatType)
+				base64data, err := formatContent(*uimc.Audio.Base64Data, vs, formatType, opts)
 				if err != nil {
 					return nil, err
 				}
@@ -921,14 +1362,14 @@ func formatUserInputMultiContent(userInputMultiContent []MessageInputPart, vs ma
 				continue
 			}
 			if uimc.Video.URL != nil && *uimc.Video.URL != "" {
-				url, err := formatContent(*uimc.Video.URL, vs, formatType)
+				url, err := formatContent(*uimc.Video.URL, vs, formatType, opts)
 				if err != nil {
 					return nil, err
 				}
 				copiedUIMC[i].Video.URL = &url
 			}
 			if uimc.Video.Base64Data != nil && *uimc.Video.Base64Data != "" {
-				base64data, err := formatContent(*uimc.Video.Base64Data, vs, formatType)
+				base64data, err := formatContent(*uimc.Video.Base64Data, vs, formatType, opts)
 				if err != nil {
 					return nil, err
 				}
@@ -939,14 +1380,14 @@ func formatUserInputMultiContent(userInputMultiContent []MessageInputPart, vs ma
 				continue
 			}
 			if uimc.File.URL != nil && *uimc.File.URL != "" {
-				url, err := formatContent(*uimc.File.URL, vs, formatType)
+				url, err := formatContent(*uimc.File.URL, vs, formatType, opts)
 				if err != nil {
 					return nil, err
 				}
 				copiedUIMC[i].File.URL = &url
 			}
 			if uimc.File.Base64Data != nil && *uimc.File.Base64Data != "" {
-				base64data, err := formatContent(*uimc.File.Base64Data, vs, formatType)
+				base64data, err := formatContent(*uimc.File.Base64Data, vs, formatType, opts)
 				if err != nil {
 					return nil, err
 				}
@@ -1192,6 +1633,62 @@ func formatMessageOutputMedia[T MessageOutputImage | MessageOutputAudio | Messag
 	return strings.Join(parts, ", ")
 }
 
+func formatToolOutputPart(part ToolOutputPart) string {
+	switch part.Type {
+	case ToolPartTypeText:
+		return fmt.Sprintf("text: %s", part.Text)
+	case ToolPartTypeImage:
+		if part.Image == nil {
+			return "image: <nil>"
+		}
+		return fmt.Sprintf("image: %s", formatMessagePartCommon(&part.Image.MessagePartCommon))
+	case ToolPartTypeAudio:
+		if part.Audio == nil {
+			return "audio: <nil>"
+		}
+		return fmt.Sprintf("audio: %s", formatMessagePartCommon(&part.Audio.MessagePartCommon))
+	case ToolPartTypeVideo:
+		if part.Video == nil {
+			return "video: <nil>"
+		}
+		return fmt.Sprintf("video: %s", formatMessagePartCommon(&part.Video.MessagePartCommon))
+	case ToolPartTypeFile:
+		if part.File == nil {
+			return "file: <nil>"
+		}
+		return fmt.Sprintf("file: %s", formatMessagePartCommon(&part.File.MessagePartCommon))
+	case ToolPartTypeProgress:
+		if part.Progress == nil {
+			return "progress: <nil>"
+		}
+		return fmt.Sprintf("progress: %g%% %s", part.Progress.Percent, part.Progress.Message)
+	case ToolPartTypeJSON:
+		return fmt.Sprintf("json: %s", part.JSON)
+	default:
+		return fmt.Sprintf("unknown type: %s", part.Type)
+	}
+}
+
+func formatMessagePartCommon(common *MessagePartCommon) string {
+	var parts []string
+	if common.URL != nil {
+		parts = append(parts, fmt.Sprintf("url=%s", *common.URL))
+	}
+	if common.Base64Data != nil {
+		parts = append(parts, fmt.Sprintf("base64[%d bytes]", len(*common.Base64Data)))
+	}
+	if common.MIMEType != "" {
+		parts = append(parts, fmt.Sprintf("mime=%s", common.MIMEType))
+	}
+	if len(common.Extra) > 0 {
+		parts = append(parts, fmt.Sprintf("extra=%v", common.Extra))
+	}
+	if len(parts) == 0 {
+		return "<empty>"
+	}
+	return strings.Join(parts, ", ")
+}
+
 func formatChatMessagePart(part ChatMessagePart) string {
 	switch part.Type {
 	case ChatMessagePartTypeText:
@@ -1275,14 +1772,38 @@ func ToolMessage(content string, toolCallID string, opts ...ToolMessageOption) *
 	}
 }
 
+type concatToolResultsOptions struct {
+	partOrdering []ToolPartType
+}
+
+// ConcatToolResultsOption is the option for ConcatToolResultsWithOptions.
+type ConcatToolResultsOption func(*concatToolResultsOptions)
+
+// WithPartOrdering reorders the merged parts by the priority of their types, e.g. text first, then media.
+// Parts whose type is not listed are placed after the listed ones.
+// The relative order of parts with the same priority is preserved.
+// By default, parts are kept in arrival order.
+func WithPartOrdering(order []ToolPartType) ConcatToolResultsOption {
+	return func(o *concatToolResultsOptions) {
+		o.partOrdering = order
+	}
+}
+
 // ConcatToolResults merges multiple ToolResult chunks into a single ToolResult.
 // It collects all ToolOutputParts from the input chunks and merges contiguous text parts within each chunk.
 //
 // Merge rules:
+//   - Sources: Sources of all chunks are accumulated in order, deduplicated by URL.
 //   - Text parts: Contiguous text parts within each chunk are concatenated into a single text part.
 //   - Non-text parts (image, audio, video, file): These parts are kept as-is without merging.
 //     Each non-text part type can only appear in one chunk; if the same non-text type appears
 //     in multiple chunks, an error is returned.
+//   - Progress parts: These parts are transient, only the latest one is kept and placed after the other parts.
+//   - Meta: The last non-nil Meta is kept, which is the final status of the execution.
+//   - JSON parts: A JSON part which is valid JSON on its own is kept as is. Otherwise, it starts a JSON document
+//     streamed in fragments, and the first JSON part of each following chunk is appended to it until it becomes valid JSON,
+//     where the reassembled document is placed where its first fragment appears.
+//     JSON parts in the same chunk are never fragments of one document. An error is returned if a document is left incomplete.
 //
 // This function is primarily used in streaming scenarios where tool output is delivered
 // in multiple chunks that need to be merged into a complete result.
@@ -1294,8 +1815,18 @@ func ToolMessage(content string, toolCallID string, opts ...ToolMessageOption) *
 // Returns:
 //   - *ToolResult: The merged ToolResult containing all content from the chunks.
 //     Returns an empty ToolResult if chunks is empty or all chunks are nil/empty.
-//   - error: An error if the same non-text part type appears in multiple chunks.
+//   - error: An error if the same non-text part type appears in multiple chunks, or a JSON document is left incomplete.
 func ConcatToolResults(chunks []*ToolResult) (*ToolResult, error) {
+	return ConcatToolResultsWithOptions(chunks)
+}
+
+// ConcatToolResultsWithOptions is ConcatToolResults with options, e.g. WithPartOrdering.
+func ConcatToolResultsWithOptions(chunks []*ToolResult, opts ...ConcatToolResultsOption) (*ToolResult, error) {
+	o := &concatToolResultsOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+
 	if len(chunks) == 0 {
 		return &ToolResult{}, nil
 	}
@@ -1303,12 +1834,55 @@ func ConcatToolResults(chunks []*ToolResult) (*ToolResult, error) {
 	nonTextPartTypes := make(map[ToolPartType]int)
 
 	var allParts []ToolOutputPart
+	var allSources []Source
+	var lastProgress *ToolOutputPart
+	var meta *ToolResultMeta
+	// the JSON of the json parts in order, and the one being reassembled from fragments, -1 if none
+	var jsonDocs []json.RawMessage
+	openJSONDoc := -1
 	for chunkIdx, chunk := range chunks {
-		if chunk == nil || len(chunk.Parts) == 0 {
+		if chunk == nil {
 			continue
 		}
 
-		for _, part := range chunk.Parts {
+		allSources = append(allSources, chunk.Sources...)
+		if chunk.Meta != nil {
+			meta = chunk.Meta
+		}
+
+		if len(chunk.Parts) == 0 {
+			continue
+		}
+
+		parts := make([]ToolOutputPart, 0, len(chunk.Parts))
+		jsonInChunk := false
+		for i, part := range chunk.Parts {
+			if part.Type == ToolPartTypeProgress {
+				// progress is transient, only the latest one is kept
+				lastProgress = &chunk.Parts[i]
+				continue
+			}
+			if part.Type == ToolPartTypeJSON {
+				firstInChunk := !jsonInChunk
+				jsonInChunk = true
+				if openJSONDoc >= 0 && firstInChunk {
+					// a fragment of the document started in a previous chunk
+					jsonDocs[openJSONDoc] = append(jsonDocs[openJSONDoc], part.JSON...)
+					if json.Valid(jsonDocs[openJSONDoc]) {
+						openJSONDoc = -1
+					}
+					continue
+				}
+
+				jsonDocs = append(jsonDocs, append(json.RawMessage(nil), part.JSON...))
+				if !json.Valid(part.JSON) {
+					openJSONDoc = len(jsonDocs) - 1
+				}
+				parts = append(parts, part)
+				continue
+			}
+			parts = append(parts, part)
+
 			if part.Type != ToolPartTypeText {
 				if prevChunkIdx, exists := nonTextPartTypes[part.Type]; exists {
 					return nil, fmt.Errorf("conflicting %s parts found in chunk %d and chunk %d: "+
@@ -1318,15 +1892,82 @@ func ConcatToolResults(chunks []*ToolResult) (*ToolResult, error) {
 			}
 		}
 
-		mergedChunkParts := mergeTextPartsInChunk(chunk.Parts)
+		mergedChunkParts := mergeTextPartsInChunk(parts)
 		allParts = append(allParts, mergedChunkParts...)
 	}
 
+	if len(jsonDocs) > 0 {
+		for _, doc := range jsonDocs {
+			if !json.Valid(doc) {
+				return nil, fmt.Errorf("json parts don't form valid json: %s", doc)
+			}
+		}
+		// the json parts are kept in the order their documents were started
+		docIdx := 0
+		for i := range allParts {
+			if allParts[i].Type == ToolPartTypeJSON {
+				allParts[i].JSON = jsonDocs[docIdx]
+				docIdx++
+			}
+		}
+	}
+
+	if lastProgress != nil {
+		allParts = append(allParts, *lastProgress)
+	}
+
+	sources := dedupSources(allSources)
+
 	if len(allParts) == 0 {
-		return &ToolResult{}, nil
+		return &ToolResult{Sources: sources, Meta: meta}, nil
+	}
+
+	if len(o.partOrdering) > 0 {
+		sortToolOutputParts(allParts, o.partOrdering)
+	}
+
+	return &ToolResult{Parts: allParts, Sources: sources, Meta: meta}, nil
+}
+
+// dedupSources keeps the first source of each URL. Sources without URL are all kept.
+func dedupSources(sources []Source) []Source {
+	if len(sources) == 0 {
+		return nil
+	}
+
+	seen := make(map[string]bool, len(sources))
+	ret := make([]Source, 0, len(sources))
+	for _, src := range sources {
+		if src.URL != "" {
+			if seen[src.URL] {
+				continue
+			}
+			seen[src.URL] = true
+		}
+		ret = append(ret, src)
+	}
+
+	return ret
+}
+
+func sortToolOutputParts(parts []ToolOutputPart, order []ToolPartType) {
+	priority := make(map[ToolPartType]int, len(order))
+	for i, typ := range order {
+		if _, ok := priority[typ]; !ok {
+			priority[typ] = i
+		}
+	}
+
+	getPriority := func(typ ToolPartType) int {
+		if p, ok := priority[typ]; ok {
+			return p
+		}
+		return len(order)
 	}
 
-	return &ToolResult{Parts: allParts}, nil
+	sort.SliceStable(parts, func(i, j int) bool {
+		return getPriority(parts[i].Type) < getPriority(parts[j].Type)
+	})
 }
 
 func mergeTextPartsInChunk(parts []ToolOutputPart) []ToolOutputPart {
@@ -1381,57 +2022,12 @@ func concatToolCalls(chunks []ToolCall) ([]ToolCall, error) {
 		}
 	}
 
-	var args strings.Builder
-	for k, v := range m {
-		index := k
-		toolCall := ToolCall{Index: &index}
-		if len(v) > 0 {
-			toolCall = chunks[v[0]]
-		}
-
-		args.Reset()
-		toolID, toolType, toolName := "", "", "" // these field will output atomically in any chunk
-
-		for _, n := range v {
-			chunk := chunks[n]
-			if chunk.ID != "" {
-				if toolID == "" {
-					toolID = chunk.ID
-				} else if toolID != chunk.ID {
-					return nil, fmt.Errorf("cannot concat ToolCalls with different tool id: '%s' '%s'", toolID, chunk.ID)
-				}
-
-			}
-
-			if chunk.Type != "" {
-				if toolType == "" {
-					toolType = chunk.Type
-				} else if toolType != chunk.Type {
-					return nil, fmt.Errorf("cannot concat ToolCalls with different tool type: '%s' '%s'", toolType, chunk.Type)
-				}
-			}
-
-			if chunk.Function.Name != "" {
-				if toolName == "" {
-					toolName = chunk.Function.Name
-				} else if toolName != chunk.Function.Name {
-					return nil, fmt.Errorf("cannot concat ToolCalls with different tool name: '%s' '%s'", toolName, chunk.Function.Name)
-				}
-			}
-
-			if chunk.Function.Arguments != "" {
-				_, err := args.WriteString(chunk.Function.Arguments)
-				if err != nil {
-					return nil, err
-				}
-			}
+	for _, v := range m {
+		toolCall, err := mergeToolCallChunks(chunks, v)
+		if err != nil {
+			return nil, err
 		}
 
-		toolCall.ID = toolID
-		toolCall.Type = toolType
-		toolCall.Function.Name = toolName
-		toolCall.Function.Arguments = args.String()
-
 		merged = append(merged, toolCall)
 	}
 
@@ -1453,6 +2049,96 @@ func concatToolCalls(chunks []ToolCall) ([]ToolCall, error) {
 	return merged, nil
 }
 
+// concatToolCallsByID groups the chunks by ID instead of Index, for providers streaming tool calls keyed by ID.
+// A chunk without ID continues the tool call of the closest preceding chunk with ID,
+// and chunks without ID before any chunk with ID are kept as is.
+// The merged tool calls are in the order of their first chunk.
+func concatToolCallsByID(chunks []ToolCall) ([]ToolCall, error) {
+	var (
+		groups  [][]int
+		idGroup = make(map[string]int)
+		current = -1
+	)
+	for i := range chunks {
+		id := chunks[i].ID
+		if id != "" {
+			g, ok := idGroup[id]
+			if !ok {
+				g = len(groups)
+				idGroup[id] = g
+				groups = append(groups, nil)
+			}
+			current = g
+		} else if current < 0 {
+			groups = append(groups, []int{i})
+			continue
+		}
+
+		groups[current] = append(groups[current], i)
+	}
+
+	merged := make([]ToolCall, 0, len(groups))
+	for _, g := range groups {
+		toolCall, err := mergeToolCallChunks(chunks, g)
+		if err != nil {
+			return nil, err
+		}
+		merged = append(merged, toolCall)
+	}
+
+	return merged, nil
+}
+
+// mergeToolCallChunks merges chunks[idxs...] into one ToolCall, based on the first chunk.
+func mergeToolCallChunks(chunks []ToolCall, idxs []int) (ToolCall, error) {
+	toolCall := chunks[idxs[0]]
+
+	var args strings.Builder
+	toolID, toolType, toolName := "", "", "" // these field will output atomically in any chunk
+
+	for _, n := range idxs {
+		chunk := chunks[n]
+		if chunk.ID != "" {
+			if toolID == "" {
+				toolID = chunk.ID
+			} else if toolID != chunk.ID {
+				return ToolCall{}, fmt.Errorf("cannot concat ToolCalls with different tool id: '%s' '%s'", toolID, chunk.ID)
+			}
+
+		}
+
+		if chunk.Type != "" {
+			if toolType == "" {
+				toolType = chunk.Type
+			} else if toolType != chunk.Type {
+				return ToolCall{}, fmt.Errorf("cannot concat ToolCalls with different tool type: '%s' '%s'", toolType, chunk.Type)
+			}
+		}
+
+		if chunk.Function.Name != "" {
+			if toolName == "" {
+				toolName = chunk.Function.Name
+			} else if toolName != chunk.Function.Name {
+				return ToolCall{}, fmt.Errorf("cannot concat ToolCalls with different tool name: '%s' '%s'", toolName, chunk.Function.Name)
+			}
+		}
+
+		if chunk.Function.Arguments != "" {
+			_, err := args.WriteString(chunk.Function.Arguments)
+			if err != nil {
+				return ToolCall{}, err
+			}
+		}
+	}
+
+	toolCall.ID = toolID
+	toolCall.Type = toolType
+	toolCall.Function.Name = toolName
+	toolCall.Function.Arguments = args.String()
+
+	return toolCall, nil
+}
+
 func concatAssistantMultiContent(parts []MessageOutputPart) ([]MessageOutputPart, error) {
 	if len(parts) == 0 {
 		return parts, nil
@@ -1605,6 +2291,146 @@ func concatExtra(extraList []map[string]any) (map[string]any, error) {
 	return internal.ConcatItems(extraList)
 }
 
+// FinishReasonPolicy decides which FinishReason is kept when ConcatMessagesWithOptions merges the ResponseMeta of chunks.
+type FinishReasonPolicy string
+
+const (
+	// FinishReasonPolicyLast keeps the last non-empty FinishReason. This is the default policy.
+	FinishReasonPolicyLast FinishReasonPolicy = "last"
+	// FinishReasonPolicyFirst keeps the first non-empty FinishReason.
+	FinishReasonPolicyFirst FinishReasonPolicy = "first"
+)
+
+// UsagePolicy decides how ConcatMessagesWithOptions merges the TokenUsage of chunks.
+type UsagePolicy string
+
+const (
+	// UsagePolicyMax keeps the maximum value of each token count. This is the default policy.
+	UsagePolicyMax UsagePolicy = "max"
+	// UsagePolicySum adds up the token counts, for providers that send per-chunk usage deltas.
+	UsagePolicySum UsagePolicy = "sum"
+	// UsagePolicyLast keeps the usage of the last chunk carrying one, for providers that send cumulative usage.
+	UsagePolicyLast UsagePolicy = "last"
+	// UsagePolicyFirst keeps the usage of the first chunk carrying one.
+	UsagePolicyFirst UsagePolicy = "first"
+)
+
+// ToolCallMatchKey decides how ConcatMessagesWithOptions groups the streamed tool call chunks into tool calls.
+type ToolCallMatchKey string
+
+const (
+	// ToolCallMatchByIndex groups chunks by ToolCall.Index, and keeps chunks with nil Index as separate tool calls.
+	// This is the default.
+	ToolCallMatchByIndex ToolCallMatchKey = "index"
+	// ToolCallMatchByID groups chunks by ToolCall.ID, for providers streaming tool calls keyed by ID
+	// whose Index is absent or unreliable. A chunk without ID continues the closest preceding tool call with ID.
+	ToolCallMatchByID ToolCallMatchKey = "id"
+)
+
+// CreatedAtPolicy decides which CreatedAt is kept when ConcatMessagesWithOptions merges chunks.
+type CreatedAtPolicy string
+
+const (
+	// CreatedAtPolicyFirst keeps the first non-nil CreatedAt, i.e. the time of the first chunk. This is the default policy.
+	CreatedAtPolicyFirst CreatedAtPolicy = "first"
+	// CreatedAtPolicyLast keeps the last non-nil CreatedAt, i.e. the time of the last chunk.
+	CreatedAtPolicyLast CreatedAtPolicy = "last"
+)
+
+type concatMessagesOptions struct {
+	finishReasonPolicy FinishReasonPolicy
+	usagePolicy        UsagePolicy
+	toolCallMatchKey   ToolCallMatchKey
+	skipNilChunks      bool
+	createdAtPolicy    CreatedAtPolicy
+}
+
+// ConcatMessagesOption is the option for ConcatMessagesWithOptions and ConcatMessageStreamWithOptions.
+type ConcatMessagesOption func(*concatMessagesOptions)
+
+// WithFinishReasonPolicy sets how the FinishReason of the concatenated message is picked.
+// Default is FinishReasonPolicyLast.
+func WithFinishReasonPolicy(policy FinishReasonPolicy) ConcatMessagesOption {
+	return func(o *concatMessagesOptions) {
+		o.finishReasonPolicy = policy
+	}
+}
+
+// WithUsagePolicy sets how the TokenUsage of the concatenated message is merged.
+// Default is UsagePolicyMax.
+func WithUsagePolicy(policy UsagePolicy) ConcatMessagesOption {
+	return func(o *concatMessagesOptions) {
+		o.usagePolicy = policy
+	}
+}
+
+// WithToolCallMatchKey sets how the streamed tool call chunks are grouped into tool calls.
+// Default is ToolCallMatchByIndex.
+func WithToolCallMatchKey(key ToolCallMatchKey) ConcatMessagesOption {
+	return func(o *concatMessagesOptions) {
+		o.toolCallMatchKey = key
+	}
+}
+
+// WithSkipNilChunks sets whether nil chunks, e.g. keepalives of some streams, are dropped instead of failing the concatenation.
+// Default is false, which returns an error on any nil chunk.
+func WithSkipNilChunks(skip bool) ConcatMessagesOption {
+	return func(o *concatMessagesOptions) {
+		o.skipNilChunks = skip
+	}
+}
+
+// WithCreatedAtPolicy sets how the CreatedAt of the concatenated message is picked.
+// Default is CreatedAtPolicyFirst.
+func WithCreatedAtPolicy(policy CreatedAtPolicy) ConcatMessagesOption {
+	return func(o *concatMessagesOptions) {
+		o.createdAtPolicy = policy
+	}
+}
+
+func getConcatMessagesOptions(opts ...ConcatMessagesOption) *concatMessagesOptions {
+	o := &concatMessagesOptions{
+		finishReasonPolicy: FinishReasonPolicyLast,
+		usagePolicy:        UsagePolicyMax,
+		toolCallMatchKey:   ToolCallMatchByIndex,
+		createdAtPolicy:    CreatedAtPolicyFirst,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+func mergeTokenUsage(dst, src *TokenUsage, policy UsagePolicy) {
+	switch policy {
+	case UsagePolicyFirst:
+		// dst has been initialized with the first usage.
+	case UsagePolicyLast:
+		*dst = *src
+	case UsagePolicySum:
+		dst.PromptTokens += src.PromptTokens
+		dst.PromptTokenDetails.CachedTokens += src.PromptTokenDetails.CachedTokens
+		dst.CompletionTokens += src.CompletionTokens
+		dst.CompletionTokensDetails.ReasoningTokens += src.CompletionTokensDetails.ReasoningTokens
+		dst.TotalTokens += src.TotalTokens
+	default:
+		if src.PromptTokens > dst.PromptTokens {
+			dst.PromptTokens = src.PromptTokens
+		}
+		if src.CompletionTokens > dst.CompletionTokens {
+			dst.CompletionTokens = src.CompletionTokens
+		}
+
+		if src.TotalTokens > dst.TotalTokens {
+			dst.TotalTokens = src.TotalTokens
+		}
+
+		if src.PromptTokenDetails.CachedTokens > dst.PromptTokenDetails.CachedTokens {
+			dst.PromptTokenDetails.CachedTokens = src.PromptTokenDetails.CachedTokens
+		}
+	}
+}
+
 // ConcatMessages concat messages with the same role and name.
 // It will concat tool calls with the same index.
 // It will return an error if the messages have different roles or names.
@@ -1623,6 +2449,17 @@ func concatExtra(extraList []map[string]any) (map[string]any, error) {
 //
 // concatedMsg, err := ConcatMessages(msgs) // concatedMsg.Content will be full content of all messages
 func ConcatMessages(msgs []*Message) (*Message, error) {
+	return ConcatMessagesWithOptions(msgs)
+}
+
+// ConcatMessagesWithOptions is ConcatMessages with options, e.g. the merging of ResponseMeta
+// can be tuned by WithFinishReasonPolicy and WithUsagePolicy, and that of CreatedAt by WithCreatedAtPolicy.
+// e.g.
+//
+//	msg, err := ConcatMessagesWithOptions(msgs, WithUsagePolicy(UsagePolicySum))
+func ConcatMessagesWithOptions(msgs []*Message, opts ...ConcatMessagesOption) (*Message, error) {
+	o := getConcatMessagesOptions(opts...)
+
 	var (
 		contents                      []string
 		contentLen                    int
@@ -1638,6 +2475,9 @@ func ConcatMessages(msgs []*Message) (*Message, error) {
 
 	for idx, msg := range msgs {
 		if msg == nil {
+			if o.skipNilChunks {
+				continue
+			}
 			return nil, fmt.Errorf("unexpected nil chunk in message stream, index: %d", idx)
 		}
 
@@ -1693,6 +2533,30 @@ func ConcatMessages(msgs []*Message) (*Message, error) {
 			extraList = append(extraList, msg.Extra)
 		}
 
+		if len(msg.RawResponse) > 0 {
+			ret.RawResponse = msg.RawResponse
+		}
+
+		if len(msg.Annotations) > 0 {
+			ret.Annotations = append(ret.Annotations, msg.Annotations...)
+		}
+
+		if msg.Prefix {
+			ret.Prefix = true
+		}
+
+		if msg.CacheControl != nil {
+			ret.CacheControl = msg.CacheControl
+		}
+
+		if msg.CreatedAt != nil && (ret.CreatedAt == nil || o.createdAtPolicy == CreatedAtPolicyLast) {
+			ret.CreatedAt = msg.CreatedAt
+		}
+
+		if msg.ModelName != "" && ret.ModelName == "" {
+			ret.ModelName = msg.ModelName
+		}
+
 		// The 'MultiContent' field is deprecated but is kept for backward compatibility.
 		if len(msg.MultiContent) > 0 {
 			multiContentParts = append(multiContentParts, msg.MultiContent...)
@@ -1709,30 +2573,27 @@ func ConcatMessages(msgs []*Message) (*Message, error) {
 		}
 
 		if msg.ResponseMeta != nil && ret.ResponseMeta != nil {
-			// keep the last FinishReason with a valid value.
 			if msg.ResponseMeta.FinishReason != "" {
-				ret.ResponseMeta.FinishReason = msg.ResponseMeta.FinishReason
+				switch o.finishReasonPolicy {
+				case FinishReasonPolicyFirst:
+					if ret.ResponseMeta.FinishReason == "" {
+						ret.ResponseMeta.FinishReason = msg.ResponseMeta.FinishReason
+					}
+				default:
+					// keep the last FinishReason with a valid value.
+					ret.ResponseMeta.FinishReason = msg.ResponseMeta.FinishReason
+				}
 			}
 
 			if msg.ResponseMeta.Usage != nil {
 				if ret.ResponseMeta.Usage == nil {
 					ret.ResponseMeta.Usage = &TokenUsage{}
+					if o.usagePolicy == UsagePolicyFirst {
+						*ret.ResponseMeta.Usage = *msg.ResponseMeta.Usage
+					}
 				}
 
-				if msg.ResponseMeta.Usage.PromptTokens > ret.ResponseMeta.Usage.PromptTokens {
-					ret.ResponseMeta.Usage.PromptTokens = msg.ResponseMeta.Usage.PromptTokens
-				}
-				if msg.ResponseMeta.Usage.CompletionTokens > ret.ResponseMeta.Usage.CompletionTokens {
-					ret.ResponseMeta.Usage.CompletionTokens = msg.ResponseMeta.Usage.CompletionTokens
-				}
-
-				if msg.ResponseMeta.Usage.TotalTokens > ret.ResponseMeta.Usage.TotalTokens {
-					ret.ResponseMeta.Usage.TotalTokens = msg.ResponseMeta.Usage.TotalTokens
-				}
-
-				if msg.ResponseMeta.Usage.PromptTokenDetails.CachedTokens > ret.ResponseMeta.Usage.PromptTokenDetails.CachedTokens {
-					ret.ResponseMeta.Usage.PromptTokenDetails.CachedTokens = msg.ResponseMeta.Usage.PromptTokenDetails.CachedTokens
-				}
+				mergeTokenUsage(ret.ResponseMeta.Usage, msg.ResponseMeta.Usage, o.usagePolicy)
 			}
 
 			if msg.ResponseMeta.LogProbs != nil {
@@ -1772,7 +2633,13 @@ func ConcatMessages(msgs []*Message) (*Message, error) {
 	}
 
 	if len(toolCalls) > 0 {
-		merged, err := concatToolCalls(toolCalls)
+		var merged []ToolCall
+		var err error
+		if o.toolCallMatchKey == ToolCallMatchByID {
+			merged, err = concatToolCallsByID(toolCalls)
+		} else {
+			merged, err = concatToolCalls(toolCalls)
+		}
 		if err != nil {
 			return nil, err
 		}
@@ -1817,6 +2684,11 @@ func ConcatMessages(msgs []*Message) (*Message, error) {
 // ConcatMessageStream drains a stream of messages and returns a single
 // concatenated message representing the merged content.
 func ConcatMessageStream(s *StreamReader[*Message]) (*Message, error) {
+	return ConcatMessageStreamWithOptions(s)
+}
+
+// ConcatMessageStreamWithOptions is ConcatMessageStream with the options of ConcatMessagesWithOptions.
+func ConcatMessageStreamWithOptions(s *StreamReader[*Message], opts ...ConcatMessagesOption) (*Message, error) {
 	defer s.Close()
 
 	var msgs []*Message
@@ -1833,7 +2705,39 @@ func ConcatMessageStream(s *StreamReader[*Message]) (*Message, error) {
 		msgs = append(msgs, msg)
 	}
 
-	return ConcatMessages(msgs)
+	return ConcatMessagesWithOptions(msgs, opts...)
+}
+
+// SplitReasoningStream splits a stream of message deltas into a stream of ReasoningContent deltas and a stream of Content deltas,
+// so that the thinking process and the final answer can be rendered independently as they stream.
+// A chunk carrying both is split into the two streams, and chunks without the corresponding field are skipped.
+// Errors of the source stream are delivered to both streams.
+// The source stream becomes unusable after calling this, and both returned streams should be closed.
+// e.g.
+//
+//	reasoning, content := schema.SplitReasoningStream(sr)
+//	defer reasoning.Close()
+//	defer content.Close()
+//	go renderThinking(reasoning)
+//	renderAnswer(content)
+func SplitReasoningStream(sr *StreamReader[*Message]) (reasoning, content *StreamReader[string]) {
+	srs := sr.Copy(2)
+
+	reasoning = StreamReaderWithConvert(srs[0], func(m *Message) (string, error) {
+		if m == nil || m.ReasoningContent == "" {
+			return "", ErrNoValue
+		}
+		return m.ReasoningContent, nil
+	})
+
+	content = StreamReaderWithConvert(srs[1], func(m *Message) (string, error) {
+		if m == nil || m.Content == "" {
+			return "", ErrNoValue
+		}
+		return m.Content, nil
+	})
+
+	return reasoning, content
 }
 
 // custom jinja env
@@ -1848,6 +2752,147 @@ const (
 	jinjaFrom    = "from"
 )
 
+// disabledMustachePartials disables mustache partials, which load templates from files by default.
+type disabledMustachePartials struct{}
+
+func (disabledMustachePartials) Get(name string) (string, error) {
+	return "", fmt.Errorf("mustache partial[%s] has been disabled", name)
+}
+
+// renderMustache renders tpl with vs, and if strict, fails on a variable or section missing from vs before rendering,
+// instead of turning on mustache.AllowMissingVariables, which is global to the process.
+func renderMustache(tpl *mustache.Template, vs map[string]any, strict bool) (string, error) {
+	if strict {
+		if err := checkMustacheTags(tpl.Tags(), []reflect.Value{reflect.ValueOf(vs)}); err != nil {
+			return "", err
+		}
+	}
+	return tpl.Render(vs)
+}
+
+// checkMustacheTags checks the names of tags can be looked up in the context chain, innermost first, as mustache does.
+// The content of a section is checked against each context it would be rendered with, and skipped if it's not rendered.
+func checkMustacheTags(tags []mustache.Tag, chain []reflect.Value) error {
+	for _, tag := range tags {
+		if tag.Type() == mustache.Partial {
+			continue
+		}
+
+		v, ok := lookupMustacheName(chain, tag.Name())
+		if !ok {
+			return fmt.Errorf("missing variable %q", tag.Name())
+		}
+		if tag.Type() == mustache.Variable {
+			continue
+		}
+
+		empty := isMustacheEmpty(v)
+		if tag.Type() == mustache.InvertedSection {
+			if empty {
+				if err := checkMustacheTags(tag.Tags(), chain); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		if empty {
+			continue
+		}
+
+		var contexts []reflect.Value
+		switch iv := indirectValue(v); iv.Kind() {
+		case reflect.Slice, reflect.Array:
+			for i := 0; i < iv.Len(); i++ {
+				contexts = append(contexts, iv.Index(i))
+			}
+		case reflect.Func:
+			// lambdas render the section text themselves
+		default:
+			contexts = append(contexts, v)
+		}
+		for _, ctx := range contexts {
+			if err := checkMustacheTags(tag.Tags(), append([]reflect.Value{ctx}, chain...)); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// lookupMustacheName looks up the name, which can be a dotted path, in the context chain.
+// A value found by a method is reported as found but invalid, as the method is not called.
+func lookupMustacheName(chain []reflect.Value, name string) (reflect.Value, bool) {
+	if name == "." {
+		return chain[0], true
+	}
+
+	first, rest, dotted := strings.Cut(name, ".")
+	for _, ctx := range chain {
+		v, ok := lookupMustacheField(ctx, first)
+		if !ok {
+			continue
+		}
+		if !dotted {
+			return v, true
+		}
+		if !v.IsValid() {
+			return v, true
+		}
+		return lookupMustacheName([]reflect.Value{v}, rest)
+	}
+
+	return reflect.Value{}, false
+}
+
+func lookupMustacheField(v reflect.Value, name string) (reflect.Value, bool) {
+	for v.IsValid() {
+		if _, ok := v.Type().MethodByName(name); ok {
+			return reflect.Value{}, true
+		}
+		switch v.Kind() {
+		case reflect.Ptr, reflect.Interface:
+			v = v.Elem()
+		case reflect.Struct:
+			f := v.FieldByName(name)
+			return f, f.IsValid()
+		case reflect.Map:
+			if v.Type().Key().Kind() != reflect.String {
+				return reflect.Value{}, false
+			}
+			mv := v.MapIndex(reflect.ValueOf(name).Convert(v.Type().Key()))
+			return mv, mv.IsValid()
+		default:
+			return reflect.Value{}, false
+		}
+	}
+
+	return reflect.Value{}, false
+}
+
+// isMustacheEmpty reports whether mustache skips a section of the value, or renders its inverted section.
+func isMustacheEmpty(v reflect.Value) bool {
+	iv := indirectValue(v)
+	if !iv.IsValid() {
+		return true
+	}
+	switch iv.Kind() {
+	case reflect.Slice, reflect.Array:
+		return iv.Len() == 0
+	case reflect.String:
+		return strings.TrimSpace(iv.String()) == ""
+	default:
+		return iv.IsZero()
+	}
+}
+
+func indirectValue(v reflect.Value) reflect.Value {
+	for v.IsValid() && (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) {
+		v = v.Elem()
+	}
+	return v
+}
+
 func getJinjaEnv() (*gonja.Environment, error) {
 	jinjaEnvOnce.Do(func() {
 		jinjaEnv = gonja.NewEnvironment(config.DefaultConfig, gonja.DefaultLoader)
@@ -0,0 +1,507 @@
+/*
+ * Copyright 2026 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package schema
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/bytedance/sonic"
+)
+
+// ToMap converts the message into a map keyed by its JSON field names,
+// which is handy for structured logging and dynamic inspection.
+// It returns an error if the message is nil or cannot be encoded as JSON, e.g. an Extra value of an unsupported type.
+// e.g.
+//
+//	m := schema.UserMessage("hello")
+//	fields, err := m.ToMap() // map[string]any{"role": "user", "content": "hello"}
+func (m *Message) ToMap() (map[string]any, error) {
+	if m == nil {
+		return nil, fmt.Errorf("message is nil")
+	}
+
+	data, err := sonic.Marshal(m)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal message: %w", err)
+	}
+
+	var ret map[string]any
+	if err = sonic.Unmarshal(data, &ret); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal message to map: %w", err)
+	}
+
+	return ret, nil
+}
+
+// MessageFromMap converts a map produced by Message.ToMap, or any map keyed by the JSON field names of Message, back into a Message.
+func MessageFromMap(fields map[string]any) (*Message, error) {
+	data, err := sonic.Marshal(fields)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal message map: %w", err)
+	}
+
+	m := &Message{}
+	if err = sonic.Unmarshal(data, m); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal message map: %w", err)
+	}
+
+	return m, nil
+}
+
+type openAIMessage struct {
+	Role       RoleType         `json:"role"`
+	Content    any              `json:"content"`
+	Name       string           `json:"name,omitempty"`
+	ToolCalls  []openAIToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string           `json:"tool_call_id,omitempty"`
+	Prefix     bool             `json:"prefix,omitempty"`
+}
+
+type openAIToolCall struct {
+	ID       string             `json:"id"`
+	Type     string             `json:"type"`
+	Function openAIFunctionCall `json:"function"`
+}
+
+type openAIFunctionCall struct {
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"`
+}
+
+type openAIContentPart struct {
+	Type       string            `json:"type"`
+	Text       *string           `json:"text,omitempty"`
+	ImageURL   *openAIImageURL   `json:"image_url,omitempty"`
+	InputAudio *openAIInputAudio `json:"input_audio,omitempty"`
+	File       *openAIFile       `json:"file,omitempty"`
+}
+
+type openAIImageURL struct {
+	URL    string         `json:"url"`
+	Detail ImageURLDetail `json:"detail,omitempty"`
+}
+
+type openAIInputAudio struct {
+	Data   string `json:"data"`
+	Format string `json:"format"`
+}
+
+type openAIFile struct {
+	FileData string `json:"file_data,omitempty"`
+	Filename string `json:"filename,omitempty"`
+}
+
+// ToOpenAIJSON renders the message as an entry of the messages array of an OpenAI chat completion request.
+// The content is rendered as a string, or as an array of typed parts when the message carries multi-content.
+// Tool calls are rendered as tool_calls, and tool messages carry tool_call_id.
+// A prefill assistant message, see Message.Prefix, is rendered with "prefix": true, as accepted by OpenAI compatible
+// APIs supporting prefix completion, e.g. DeepSeek.
+// It returns an error if the message contains content OpenAI cannot accept, e.g. video.
+// e.g.
+//
+//	data, err := schema.UserMessage("hello").ToOpenAIJSON()
+//	// data: {"role":"user","content":"hello"}
+func (m *Message) ToOpenAIJSON() ([]byte, error) {
+	if m == nil {
+		return nil, fmt.Errorf("message is nil")
+	}
+
+	if m.Prefix && m.Role != Assistant {
+		return nil, fmt.Errorf("prefix is only supported for assistant message, role=%s", m.Role)
+	}
+
+	om := &openAIMessage{
+		Role:       m.Role,
+		Name:       m.Name,
+		ToolCallID: m.ToolCallID,
+		Prefix:     m.Prefix,
+	}
+
+	var (
+		parts []openAIContentPart
+		err   error
+	)
+	switch {
+	case len(m.UserInputMultiContent) > 0:
+		parts, err = inputPartsToOpenAI(m.UserInputMultiContent)
+	case len(m.AssistantGenMultiContent) > 0:
+		parts, err = outputPartsToOpenAI(m.AssistantGenMultiContent)
+	case len(m.MultiContent) > 0:
+		parts, err = chatMessagePartsToOpenAI(m.MultiContent)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if len(parts) > 0 {
+		om.Content = parts
+	} else if m.Content != "" || len(m.ToolCalls) == 0 {
+		om.Content = m.Content
+	}
+
+	for _, tc := range m.ToolCalls {
+		typ := tc.Type
+		if typ == "" {
+			typ = "function"
+		}
+		om.ToolCalls = append(om.ToolCalls, openAIToolCall{
+			ID:   tc.ID,
+			Type: typ,
+			Function: openAIFunctionCall{
+				Name:      tc.Function.Name,
+				Arguments: tc.Function.Arguments,
+			},
+		})
+	}
+
+	return sonic.Marshal(om)
+}
+
+func openAITextPart(text string) openAIContentPart {
+	return openAIContentPart{Type: "text", Text: &text}
+}
+
+func inputPartsToOpenAI(parts []MessageInputPart) ([]openAIContentPart, error) {
+	ret := make([]openAIContentPart, 0, len(parts))
+	for i, part := range parts {
+		switch part.Type {
+		case ChatMessagePartTypeText:
+			ret = append(ret, openAITextPart(part.Text))
+		case ChatMessagePartTypeImageURL:
+			if part.Image == nil {
+				return nil, fmt.Errorf("image of part[%d] is nil", i)
+			}
+			url, err := mediaURLOrDataURI(&part.Image.MessagePartCommon)
+			if err != nil {
+				return nil, fmt.Errorf("invalid image of part[%d]: %w", i, err)
+			}
+			ret = append(ret, openAIContentPart{
+				Type:     "image_url",
+				ImageURL: &openAIImageURL{URL: url, Detail: part.Image.Detail},
+			})
+		case ChatMessagePartTypeAudioURL:
+			if part.Audio == nil {
+				return nil, fmt.Errorf("audio of part[%d] is nil", i)
+			}
+			if part.Audio.Base64Data == nil {
+				return nil, fmt.Errorf("audio of part[%d] must be base64 data for OpenAI", i)
+			}
+			ret = append(ret, openAIContentPart{
+				Type: "input_audio",
+				InputAudio: &openAIInputAudio{
+					Data:   *part.Audio.Base64Data,
+					Format: audioFormatFromMIMEType(part.Audio.MIMEType),
+				},
+			})
+		case ChatMessagePartTypeFileURL:
+			if part.File == nil {
+				return nil, fmt.Errorf("file of part[%d] is nil", i)
+			}
+			data, err := mediaURLOrDataURI(&part.File.MessagePartCommon)
+			if err != nil {
+				return nil, fmt.Errorf("invalid file of part[%d]: %w", i, err)
+			}
+			ret = append(ret, openAIContentPart{
+				Type: "file",
+				File: &openAIFile{FileData: data, Filename: part.File.Name},
+			})
+		default:
+			return nil, fmt.Errorf("unsupported part type for OpenAI: %s", part.Type)
+		}
+	}
+
+	return ret, nil
+}
+
+func outputPartsToOpenAI(parts []MessageOutputPart) ([]openAIContentPart, error) {
+	ret := make([]openAIContentPart, 0, len(parts))
+	for _, part := range parts {
+		if part.Type != ChatMessagePartTypeText {
+			return nil, fmt.Errorf("unsupported assistant part type for OpenAI: %s", part.Type)
+		}
+		ret = append(ret, openAITextPart(part.Text))
+	}
+
+	return ret, nil
+}
+
+func chatMessagePartsToOpenAI(parts []ChatMessagePart) ([]openAIContentPart, error) {
+	ret := make([]openAIContentPart, 0, len(parts))
+	for i, part := range parts {
+		switch part.Type {
+		case ChatMessagePartTypeText:
+			ret = append(ret, openAITextPart(part.Text))
+		case ChatMessagePartTypeImageURL:
+			if part.ImageURL == nil {
+				return nil, fmt.Errorf("image url of part[%d] is nil", i)
+			}
+			ret = append(ret, openAIContentPart{
+				Type:     "image_url",
+				ImageURL: &openAIImageURL{URL: part.ImageURL.URL, Detail: part.ImageURL.Detail},
+			})
+		default:
+			return nil, fmt.Errorf("unsupported part type for OpenAI: %s", part.Type)
+		}
+	}
+
+	return ret, nil
+}
+
+// mediaURLOrDataURI returns the URL of the media, or builds a data URI from its base64 data.
+func mediaURLOrDataURI(common *MessagePartCommon) (string, error) {
+	if common.URL != nil && *common.URL != "" {
+		return *common.URL, nil
+	}
+	if common.Base64Data != nil && *common.Base64Data != "" {
+		if common.MIMEType == "" {
+			return "", fmt.Errorf("mime type is required for base64 data")
+		}
+		return fmt.Sprintf("data:%s;base64,%s", common.MIMEType, *common.Base64Data), nil
+	}
+
+	return "", fmt.Errorf("neither url nor base64 data is set")
+}
+
+// audioFormatFromMIMEType maps the mime type to the audio format name, e.g. "audio/wav" -> "wav".
+func audioFormatFromMIMEType(mimeType string) string {
+	format := strings.TrimPrefix(mimeType, "audio/")
+	switch format {
+	case "mpeg", "mp3":
+		return "mp3"
+	case "x-wav", "wave":
+		return "wav"
+	default:
+		return format
+	}
+}
+
+// ToAnthropicBlocks converts the message into the content blocks of an Anthropic Messages API message.
+// Text, image and file parts are converted to text, image and document blocks,
+// where base64 data is rendered as a base64 source and URL as a url source.
+// The deprecated MultiContent is converted in the same way, where an image URL of data URI is rendered as a base64 source.
+// Tool calls are converted to tool_use blocks with the arguments parsed as input.
+// A tool message is converted to a single tool_result block, which should be sent in a user role message.
+// Anthropic treats a trailing assistant message as the prefill of the response without any flag,
+// so for a prefill message, see Message.Prefix, the trailing whitespace of the last text block is trimmed as Anthropic requires.
+// e.g.
+//
+//	blocks, err := msg.ToAnthropicBlocks()
+//	// blocks: []any{map[string]any{"type": "text", "text": "hello"}}
+func (m *Message) ToAnthropicBlocks() ([]any, error) {
+	if m == nil {
+		return nil, fmt.Errorf("message is nil")
+	}
+
+	if m.Prefix && m.Role != Assistant {
+		return nil, fmt.Errorf("prefix is only supported for assistant message, role=%s", m.Role)
+	}
+
+	var (
+		blocks []any
+		err    error
+	)
+	switch {
+	case len(m.UserInputMultiContent) > 0:
+		blocks, err = inputPartsToAnthropic(m.UserInputMultiContent)
+	case len(m.AssistantGenMultiContent) > 0:
+		blocks, err = outputPartsToAnthropic(m.AssistantGenMultiContent)
+	case len(m.MultiContent) > 0:
+		blocks, err = chatMessagePartsToAnthropic(m.MultiContent)
+	default:
+		if m.Content != "" {
+			blocks = append(blocks, anthropicTextBlock(m.Content))
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if m.Role == Tool {
+		content := blocks
+		if content == nil {
+			content = []any{}
+		}
+		block := map[string]any{
+			"type":        "tool_result",
+			"tool_use_id": m.ToolCallID,
+			"content":     content,
+		}
+		if m.CacheControl != nil {
+			block["cache_control"] = anthropicCacheControl(m.CacheControl)
+		}
+		return []any{block}, nil
+	}
+
+	if m.Prefix && len(blocks) > 0 {
+		if block, ok := blocks[len(blocks)-1].(map[string]any); ok && block["type"] == "text" {
+			block["text"] = strings.TrimRightFunc(block["text"].(string), unicode.IsSpace)
+		}
+	}
+
+	for _, tc := range m.ToolCalls {
+		input := map[string]any{}
+		if tc.Function.Arguments != "" {
+			if err = sonic.UnmarshalString(tc.Function.Arguments, &input); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal arguments of tool call[%s]: %w", tc.ID, err)
+			}
+		}
+		blocks = append(blocks, map[string]any{
+			"type":  "tool_use",
+			"id":    tc.ID,
+			"name":  tc.Function.Name,
+			"input": input,
+		})
+	}
+
+	if m.CacheControl != nil && len(blocks) > 0 {
+		if block, ok := blocks[len(blocks)-1].(map[string]any); ok {
+			block["cache_control"] = anthropicCacheControl(m.CacheControl)
+		}
+	}
+
+	return blocks, nil
+}
+
+// anthropicCacheControl converts CacheControl to the cache_control of Anthropic, where the ttl is like "5m" or "1h".
+func anthropicCacheControl(cc *CacheControl) map[string]any {
+	typ := cc.Type
+	if typ == "" {
+		typ = CacheControlTypeEphemeral
+	}
+	ret := map[string]any{"type": typ}
+
+	switch {
+	case cc.TTL <= 0:
+	case cc.TTL%time.Hour == 0:
+		ret["ttl"] = fmt.Sprintf("%dh", cc.TTL/time.Hour)
+	case cc.TTL%time.Minute == 0:
+		ret["ttl"] = fmt.Sprintf("%dm", cc.TTL/time.Minute)
+	default:
+		ret["ttl"] = fmt.Sprintf("%ds", (cc.TTL+time.Second-1)/time.Second)
+	}
+
+	return ret
+}
+
+func anthropicTextBlock(text string) map[string]any {
+	return map[string]any{
+		"type": "text",
+		"text": text,
+	}
+}
+
+func inputPartsToAnthropic(parts []MessageInputPart) ([]any, error) {
+	ret := make([]any, 0, len(parts))
+	for i, part := range parts {
+		switch part.Type {
+		case ChatMessagePartTypeText:
+			ret = append(ret, anthropicTextBlock(part.Text))
+		case ChatMessagePartTypeImageURL:
+			if part.Image == nil {
+				return nil, fmt.Errorf("image of part[%d] is nil", i)
+			}
+			source, err := anthropicSource(&part.Image.MessagePartCommon)
+			if err != nil {
+				return nil, fmt.Errorf("invalid image of part[%d]: %w", i, err)
+			}
+			ret = append(ret, map[string]any{"type": "image", "source": source})
+		case ChatMessagePartTypeFileURL:
+			if part.File == nil {
+				return nil, fmt.Errorf("file of part[%d] is nil", i)
+			}
+			source, err := anthropicSource(&part.File.MessagePartCommon)
+			if err != nil {
+				return nil, fmt.Errorf("invalid file of part[%d]: %w", i, err)
+			}
+			ret = append(ret, map[string]any{"type": "document", "source": source})
+		default:
+			return nil, fmt.Errorf("unsupported part type for Anthropic: %s", part.Type)
+		}
+	}
+
+	return ret, nil
+}
+
+func outputPartsToAnthropic(parts []MessageOutputPart) ([]any, error) {
+	ret := make([]any, 0, len(parts))
+	for _, part := range parts {
+		if part.Type != ChatMessagePartTypeText {
+			return nil, fmt.Errorf("unsupported assistant part type for Anthropic: %s", part.Type)
+		}
+		ret = append(ret, anthropicTextBlock(part.Text))
+	}
+
+	return ret, nil
+}
+
+func chatMessagePartsToAnthropic(parts []ChatMessagePart) ([]any, error) {
+	ret := make([]any, 0, len(parts))
+	for i, part := range parts {
+		switch part.Type {
+		case ChatMessagePartTypeText:
+			ret = append(ret, anthropicTextBlock(part.Text))
+		case ChatMessagePartTypeImageURL:
+			if part.ImageURL == nil {
+				return nil, fmt.Errorf("image url of part[%d] is nil", i)
+			}
+			url := part.ImageURL.URL
+			common := &MessagePartCommon{URL: &url, MIMEType: part.ImageURL.MIMEType}
+			// a data URI is sent as a base64 source, as a url source must be fetchable
+			if rest := strings.TrimPrefix(url, "data:"); rest != url {
+				if mimeType, data, ok := strings.Cut(rest, ";base64,"); ok {
+					if mimeType == "" {
+						mimeType = part.ImageURL.MIMEType
+					}
+					common = &MessagePartCommon{Base64Data: &data, MIMEType: mimeType}
+				}
+			}
+			source, err := anthropicSource(common)
+			if err != nil {
+				return nil, fmt.Errorf("invalid image url of part[%d]: %w", i, err)
+			}
+			ret = append(ret, map[string]any{"type": "image", "source": source})
+		default:
+			return nil, fmt.Errorf("unsupported part type for Anthropic: %s", part.Type)
+		}
+	}
+
+	return ret, nil
+}
+
+func anthropicSource(common *MessagePartCommon) (map[string]any, error) {
+	if common.Base64Data != nil && *common.Base64Data != "" {
+		if common.MIMEType == "" {
+			return nil, fmt.Errorf("mime type is required for base64 data")
+		}
+		return map[string]any{
+			"type":       "base64",
+			"media_type": common.MIMEType,
+			"data":       *common.Base64Data,
+		}, nil
+	}
+	if common.URL != nil && *common.URL != "" {
+		return map[string]any{
+			"type": "url",
+			"url":  *common.URL,
+		}, nil
+	}
+
+	return nil, fmt.Errorf("neither url nor base64 data is set")
+}
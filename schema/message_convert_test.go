@@ -0,0 +1,356 @@
+/*
+ * Copyright 2026 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package schema
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bytedance/sonic"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/cloudwego/eino/internal/generic"
+)
+
+func TestMessageMap(t *testing.T) {
+	imageURL := "https://example.com/cat.png"
+	msg := &Message{
+		Role:    Assistant,
+		Content: "let me check",
+		UserInputMultiContent: []MessageInputPart{
+			{Type: ChatMessagePartTypeText, Text: "what is it?"},
+			{Type: ChatMessagePartTypeImageURL, Image: &MessageInputImage{
+				MessagePartCommon: MessagePartCommon{URL: &imageURL, MIMEType: "image/png"},
+				Detail:            ImageURLDetailHigh,
+			}},
+		},
+		ToolCalls: []ToolCall{
+			{
+				Index:    generic.PtrOf(0),
+				ID:       "call_1",
+				Type:     "function",
+				Function: FunctionCall{Name: "get_weather", Arguments: `{"city":"Paris"}`},
+			},
+		},
+		ResponseMeta: &ResponseMeta{FinishReason: "tool_calls"},
+		Extra:        map[string]any{"key": "value"},
+	}
+
+	fields, err := msg.ToMap()
+	assert.NoError(t, err)
+	assert.Equal(t, "assistant", fields["role"])
+	assert.Equal(t, "let me check", fields["content"])
+	toolCalls, ok := fields["tool_calls"].([]any)
+	assert.True(t, ok)
+	assert.Len(t, toolCalls, 1)
+	assert.Equal(t, "call_1", toolCalls[0].(map[string]any)["id"])
+	parts, ok := fields["user_input_multi_content"].([]any)
+	assert.True(t, ok)
+	assert.Len(t, parts, 2)
+
+	restored, err := MessageFromMap(fields)
+	assert.NoError(t, err)
+	assert.Equal(t, msg, restored)
+
+	_, err = (*Message)(nil).ToMap()
+	assert.Error(t, err)
+	_, err = (&Message{Role: User, Extra: map[string]any{"ch": make(chan int)}}).ToMap()
+	assert.Error(t, err)
+
+	_, err = MessageFromMap(map[string]any{"tool_calls": "invalid"})
+	assert.Error(t, err)
+}
+
+func TestMessageToOpenAIJSON(t *testing.T) {
+	t.Run("text", func(t *testing.T) {
+		data, err := UserMessage("hello").ToOpenAIJSON()
+		assert.NoError(t, err)
+		assert.JSONEq(t, `{"role":"user","content":"hello"}`, string(data))
+	})
+
+	t.Run("multimodal user message", func(t *testing.T) {
+		imageURL := "https://example.com/cat.png"
+		audioData := "UklGRg=="
+		msg := &Message{
+			Role: User,
+			UserInputMultiContent: []MessageInputPart{
+				{Type: ChatMessagePartTypeText, Text: "what is it?"},
+				{Type: ChatMessagePartTypeImageURL, Image: &MessageInputImage{
+					MessagePartCommon: MessagePartCommon{URL: &imageURL},
+					Detail:            ImageURLDetailLow,
+				}},
+				{Type: ChatMessagePartTypeAudioURL, Audio: &MessageInputAudio{
+					MessagePartCommon: MessagePartCommon{Base64Data: &audioData, MIMEType: "audio/wav"},
+				}},
+			},
+		}
+
+		data, err := msg.ToOpenAIJSON()
+		assert.NoError(t, err)
+		assert.JSONEq(t, `{
+			"role": "user",
+			"content": [
+				{"type": "text", "text": "what is it?"},
+				{"type": "image_url", "image_url": {"url": "https://example.com/cat.png", "detail": "low"}},
+				{"type": "input_audio", "input_audio": {"data": "UklGRg==", "format": "wav"}}
+			]
+		}`, string(data))
+	})
+
+	t.Run("base64 image", func(t *testing.T) {
+		imageData := "iVBORw0KGgo="
+		msg := &Message{
+			Role: User,
+			UserInputMultiContent: []MessageInputPart{
+				{Type: ChatMessagePartTypeImageURL, Image: &MessageInputImage{
+					MessagePartCommon: MessagePartCommon{Base64Data: &imageData, MIMEType: "image/png"},
+				}},
+			},
+		}
+
+		data, err := msg.ToOpenAIJSON()
+		assert.NoError(t, err)
+		assert.JSONEq(t, `{"role":"user","content":[{"type":"image_url","image_url":{"url":"data:image/png;base64,iVBORw0KGgo="}}]}`, string(data))
+	})
+
+	t.Run("assistant tool call", func(t *testing.T) {
+		msg := AssistantMessage("", []ToolCall{
+			{ID: "call_1", Function: FunctionCall{Name: "get_weather", Arguments: `{"city":"Paris"}`}},
+		})
+
+		data, err := msg.ToOpenAIJSON()
+		assert.NoError(t, err)
+		assert.JSONEq(t, `{
+			"role": "assistant",
+			"content": null,
+			"tool_calls": [
+				{"id": "call_1", "type": "function", "function": {"name": "get_weather", "arguments": "{\"city\":\"Paris\"}"}}
+			]
+		}`, string(data))
+	})
+
+	t.Run("tool message", func(t *testing.T) {
+		data, err := ToolMessage("sunny", "call_1", WithToolName("get_weather")).ToOpenAIJSON()
+		assert.NoError(t, err)
+		assert.JSONEq(t, `{"role":"tool","content":"sunny","tool_call_id":"call_1"}`, string(data))
+	})
+
+	t.Run("unsupported", func(t *testing.T) {
+		videoURL := "https://example.com/cat.mp4"
+		msg := &Message{
+			Role: User,
+			UserInputMultiContent: []MessageInputPart{
+				{Type: ChatMessagePartTypeVideoURL, Video: &MessageInputVideo{
+					MessagePartCommon: MessagePartCommon{URL: &videoURL},
+				}},
+			},
+		}
+
+		_, err := msg.ToOpenAIJSON()
+		assert.Error(t, err)
+	})
+}
+
+func TestMessageToAnthropicBlocks(t *testing.T) {
+	t.Run("text and image", func(t *testing.T) {
+		imageData := "iVBORw0KGgo="
+		imageURL := "https://example.com/cat.png"
+		msg := &Message{
+			Role: User,
+			UserInputMultiContent: []MessageInputPart{
+				{Type: ChatMessagePartTypeText, Text: "compare them"},
+				{Type: ChatMessagePartTypeImageURL, Image: &MessageInputImage{
+					MessagePartCommon: MessagePartCommon{Base64Data: &imageData, MIMEType: "image/png"},
+				}},
+				{Type: ChatMessagePartTypeImageURL, Image: &MessageInputImage{
+					MessagePartCommon: MessagePartCommon{URL: &imageURL},
+				}},
+			},
+		}
+
+		blocks, err := msg.ToAnthropicBlocks()
+		assert.NoError(t, err)
+		assert.Equal(t, []any{
+			map[string]any{"type": "text", "text": "compare them"},
+			map[string]any{"type": "image", "source": map[string]any{
+				"type": "base64", "media_type": "image/png", "data": "iVBORw0KGgo=",
+			}},
+			map[string]any{"type": "image", "source": map[string]any{
+				"type": "url", "url": "https://example.com/cat.png",
+			}},
+		}, blocks)
+	})
+
+	t.Run("deprecated multi content", func(t *testing.T) {
+		msg := &Message{
+			Role: User,
+			MultiContent: []ChatMessagePart{
+				{Type: ChatMessagePartTypeText, Text: "compare them"},
+				{Type: ChatMessagePartTypeImageURL, ImageURL: &ChatMessageImageURL{URL: "data:image/png;base64,iVBORw0KGgo="}},
+				{Type: ChatMessagePartTypeImageURL, ImageURL: &ChatMessageImageURL{URL: "https://example.com/cat.png"}},
+			},
+		}
+
+		blocks, err := msg.ToAnthropicBlocks()
+		assert.NoError(t, err)
+		assert.Equal(t, []any{
+			map[string]any{"type": "text", "text": "compare them"},
+			map[string]any{"type": "image", "source": map[string]any{
+				"type": "base64", "media_type": "image/png", "data": "iVBORw0KGgo=",
+			}},
+			map[string]any{"type": "image", "source": map[string]any{
+				"type": "url", "url": "https://example.com/cat.png",
+			}},
+		}, blocks)
+
+		msg.MultiContent = []ChatMessagePart{{Type: ChatMessagePartTypeAudioURL, AudioURL: &ChatMessageAudioURL{URL: "https://example.com/a.wav"}}}
+		_, err = msg.ToAnthropicBlocks()
+		assert.Error(t, err)
+	})
+
+	t.Run("tool use", func(t *testing.T) {
+		msg := AssistantMessage("checking", []ToolCall{
+			{ID: "toolu_1", Function: FunctionCall{Name: "get_weather", Arguments: `{"city":"Paris"}`}},
+		})
+
+		blocks, err := msg.ToAnthropicBlocks()
+		assert.NoError(t, err)
+		assert.Equal(t, []any{
+			map[string]any{"type": "text", "text": "checking"},
+			map[string]any{"type": "tool_use", "id": "toolu_1", "name": "get_weather", "input": map[string]any{"city": "Paris"}},
+		}, blocks)
+
+		msg.ToolCalls[0].Function.Arguments = "{"
+		_, err = msg.ToAnthropicBlocks()
+		assert.Error(t, err)
+	})
+
+	t.Run("tool result", func(t *testing.T) {
+		blocks, err := ToolMessage("sunny", "toolu_1").ToAnthropicBlocks()
+		assert.NoError(t, err)
+		assert.Equal(t, []any{
+			map[string]any{"type": "tool_result", "tool_use_id": "toolu_1", "content": []any{
+				map[string]any{"type": "text", "text": "sunny"},
+			}},
+		}, blocks)
+	})
+}
+
+func TestMessagePrefix(t *testing.T) {
+	msg := &Message{Role: Assistant, Content: "```json\n", Prefix: true}
+
+	data, err := sonic.Marshal(msg)
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), `"prefix":true`)
+	var restored Message
+	assert.NoError(t, sonic.Unmarshal(data, &restored))
+	assert.True(t, restored.Prefix)
+
+	fields, err := msg.ToMap()
+	assert.NoError(t, err)
+	assert.Equal(t, true, fields["prefix"])
+	fields, err = AssistantMessage("hi", nil).ToMap()
+	assert.NoError(t, err)
+	_, ok := fields["prefix"]
+	assert.False(t, ok)
+
+	data, err = msg.ToOpenAIJSON()
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"role":"assistant","content":"`+"```json\\n"+`","prefix":true}`, string(data))
+
+	blocks, err := msg.ToAnthropicBlocks()
+	assert.NoError(t, err)
+	assert.Equal(t, []any{map[string]any{"type": "text", "text": "```json"}}, blocks)
+	// the message itself is not modified
+	assert.Equal(t, "```json\n", msg.Content)
+
+	concated, err := ConcatMessages([]*Message{{Role: Assistant, Content: "a", Prefix: true}, {Content: "b"}})
+	assert.NoError(t, err)
+	assert.True(t, concated.Prefix)
+
+	_, err = (&Message{Role: User, Content: "hi", Prefix: true}).ToOpenAIJSON()
+	assert.Error(t, err)
+	_, err = (&Message{Role: User, Content: "hi", Prefix: true}).ToAnthropicBlocks()
+	assert.Error(t, err)
+	_, err = (&Message{Role: Tool, Content: "result", ToolCallID: "call_1", Prefix: true}).ToAnthropicBlocks()
+	assert.Error(t, err)
+}
+
+func TestMessageCacheControl(t *testing.T) {
+	msg := &Message{Role: System, Content: "a large static prompt", CacheControl: &CacheControl{TTL: time.Hour}}
+
+	data, err := sonic.Marshal(msg)
+	assert.NoError(t, err)
+	var restored Message
+	assert.NoError(t, sonic.Unmarshal(data, &restored))
+	assert.Equal(t, msg.CacheControl, restored.CacheControl)
+
+	blocks, err := msg.ToAnthropicBlocks()
+	assert.NoError(t, err)
+	assert.Equal(t, []any{map[string]any{
+		"type":          "text",
+		"text":          "a large static prompt",
+		"cache_control": map[string]any{"type": "ephemeral", "ttl": "1h"},
+	}}, blocks)
+
+	// the hint goes to the last block
+	blocks, err = (&Message{
+		Role:         Assistant,
+		Content:      "let me check",
+		ToolCalls:    []ToolCall{{ID: "call_1", Function: FunctionCall{Name: "search", Arguments: `{}`}}},
+		CacheControl: &CacheControl{Type: "ephemeral", TTL: 5 * time.Minute},
+	}).ToAnthropicBlocks()
+	assert.NoError(t, err)
+	assert.Len(t, blocks, 2)
+	assert.NotContains(t, blocks[0], "cache_control")
+	assert.Equal(t, map[string]any{"type": "ephemeral", "ttl": "5m"}, blocks[1].(map[string]any)["cache_control"])
+
+	blocks, err = (&Message{Role: Tool, Content: "result", ToolCallID: "call_1", CacheControl: &CacheControl{}}).ToAnthropicBlocks()
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]any{"type": "ephemeral"}, blocks[0].(map[string]any)["cache_control"])
+
+	// ignored by providers that don't support it
+	data, err = msg.ToOpenAIJSON()
+	assert.NoError(t, err)
+	assert.NotContains(t, string(data), "cache")
+}
+
+func TestMessageInputImageDetail(t *testing.T) {
+	imageURL := "https://example.com/cat.png"
+	img := &MessageInputImage{
+		MessagePartCommon: MessagePartCommon{URL: &imageURL},
+		Detail:            ImageURLDetailHigh,
+	}
+
+	data, err := sonic.Marshal(img)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"url":"https://example.com/cat.png","detail":"high"}`, string(data))
+
+	restored := &MessageInputImage{}
+	assert.NoError(t, sonic.Unmarshal(data, restored))
+	assert.Equal(t, img, restored)
+
+	msg := &Message{
+		Role: User,
+		UserInputMultiContent: []MessageInputPart{
+			{Type: ChatMessagePartTypeImageURL, Image: &MessageInputImage{MessagePartCommon: MessagePartCommon{URL: &imageURL}}},
+		},
+	}
+	data, err = msg.ToOpenAIJSON()
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"role":"user","content":[{"type":"image_url","image_url":{"url":"https://example.com/cat.png"}}]}`, string(data))
+}
@@ -0,0 +1,734 @@
+/*
+ * Copyright 2026 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package schema
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// TokenCounter counts the tokens a message takes in the context window.
+type TokenCounter func(msg *Message) (int, error)
+
+type trimOptions struct {
+	keepSystem bool
+	keepLastN  int
+}
+
+// TrimOption is the option for TrimMessages.
+type TrimOption func(*trimOptions)
+
+// WithTrimKeepSystem sets whether system messages are always kept by TrimMessages. Default is true.
+func WithTrimKeepSystem(keep bool) TrimOption {
+	return func(o *trimOptions) {
+		o.keepSystem = keep
+	}
+}
+
+// WithTrimKeepLastN makes TrimMessages always keep the last n messages. Default is 0.
+func WithTrimKeepLastN(n int) TrimOption {
+	return func(o *trimOptions) {
+		o.keepLastN = n
+	}
+}
+
+// TrimMessages drops the oldest messages until the total tokens counted by counter is no more than maxTokens.
+// The system messages, the last user message and the last n messages set by WithTrimKeepLastN are pinned and never dropped.
+// An assistant message with tool calls and the tool messages answering them, matched by ToolCall.ID and ToolCallID,
+// are kept or dropped together, so that no tool result is left without its call, and vice versa.
+// The order of the kept messages is preserved, and the input slice is not modified.
+// It returns an error if the pinned messages alone exceed maxTokens, or if any message is nil.
+// e.g.
+//
+//	trimmed, err := schema.TrimMessages(history, counter, 4096, schema.WithTrimKeepLastN(2))
+func TrimMessages(msgs []*Message, counter TokenCounter, maxTokens int, opts ...TrimOption) ([]*Message, error) {
+	o := &trimOptions{
+		keepSystem: true,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	// group[i] is the index of the first message of the group message i belongs to,
+	// i.e. the assistant message calling the tool for a tool message, and i itself otherwise
+	group := make([]int, len(msgs))
+	groupCounts := make([]int, len(msgs))
+	pinned := make([]bool, len(msgs))
+	callers := make(map[string]int)
+	total := 0
+	lastUser := -1
+	for i, msg := range msgs {
+		if msg == nil {
+			return nil, fmt.Errorf("message[%d] is nil", i)
+		}
+		n, err := counter(msg)
+		if err != nil {
+			return nil, fmt.Errorf("failed to count tokens of message[%d]: %w", i, err)
+		}
+		total += n
+
+		group[i] = i
+		switch msg.Role {
+		case Assistant:
+			for _, tc := range msg.ToolCalls {
+				if tc.ID != "" {
+					callers[tc.ID] = i
+				}
+			}
+		case Tool:
+			if caller, ok := callers[msg.ToolCallID]; ok {
+				group[i] = caller
+			}
+		}
+		groupCounts[group[i]] += n
+
+		if msg.Role == User {
+			lastUser = i
+		}
+		if o.keepSystem && msg.Role == System {
+			pinned[i] = true
+		}
+		if i >= len(msgs)-o.keepLastN {
+			pinned[i] = true
+		}
+	}
+	if lastUser >= 0 {
+		pinned[lastUser] = true
+	}
+	for i := range msgs {
+		if pinned[i] {
+			pinned[group[i]] = true
+		}
+	}
+
+	dropped := make([]bool, len(msgs))
+	for i := 0; i < len(msgs) && total > maxTokens; i++ {
+		if group[i] != i || pinned[i] {
+			continue
+		}
+		dropped[i] = true
+		total -= groupCounts[i]
+	}
+
+	if total > maxTokens {
+		return nil, fmt.Errorf("pinned messages take %d tokens, exceeding max tokens %d", total, maxTokens)
+	}
+
+	ret := make([]*Message, 0, len(msgs))
+	for i, msg := range msgs {
+		if !dropped[group[i]] {
+			ret = append(ret, msg)
+		}
+	}
+
+	return ret, nil
+}
+
+type dedupOptions struct {
+	nonConsecutive bool
+}
+
+// DedupOption is the option for DedupMessages.
+type DedupOption func(*dedupOptions)
+
+// WithDedupNonConsecutive makes DedupMessages also remove duplicates that are not adjacent,
+// keeping only the first occurrence of each message.
+func WithDedupNonConsecutive() DedupOption {
+	return func(o *dedupOptions) {
+		o.nonConsecutive = true
+	}
+}
+
+// DedupMessages removes the duplicate messages, e.g. system instructions repeated across turns, preserving the order.
+// By default only consecutive duplicates are removed, use WithDedupNonConsecutive to remove scattered ones.
+// Two messages are duplicates if they have the same role, name, content, multi-content, reasoning content,
+// tool calls and tool call id, while ResponseMeta and Extra are ignored.
+// The input slice is not modified.
+func DedupMessages(msgs []*Message, opts ...DedupOption) []*Message {
+	o := &dedupOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	ret := make([]*Message, 0, len(msgs))
+	for _, msg := range msgs {
+		dup := false
+		if o.nonConsecutive {
+			for _, kept := range ret {
+				if isDuplicateMessage(kept, msg) {
+					dup = true
+					break
+				}
+			}
+		} else if len(ret) > 0 {
+			dup = isDuplicateMessage(ret[len(ret)-1], msg)
+		}
+
+		if !dup {
+			ret = append(ret, msg)
+		}
+	}
+
+	return ret
+}
+
+func isDuplicateMessage(a, b *Message) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+
+	return a.Role == b.Role &&
+		a.Name == b.Name &&
+		a.Content == b.Content &&
+		a.ReasoningContent == b.ReasoningContent &&
+		a.ToolCallID == b.ToolCallID &&
+		a.ToolName == b.ToolName &&
+		reflect.DeepEqual(a.ToolCalls, b.ToolCalls) &&
+		reflect.DeepEqual(a.MultiContent, b.MultiContent) &&
+		reflect.DeepEqual(a.UserInputMultiContent, b.UserInputMultiContent) &&
+		reflect.DeepEqual(a.AssistantGenMultiContent, b.AssistantGenMultiContent)
+}
+
+// SplitByTokens splits the Content of the message into multiple messages, each taking no more than maxTokens counted by counter.
+// Content is split at paragraph boundaries first, then at sentence boundaries and finally at whitespace,
+// only going finer when a piece does not fit the budget on its own. Adjacent pieces are packed greedily into one chunk.
+// Each returned message keeps the Role and Name of m, and carries a part of Content with the surrounding whitespace trimmed.
+// It returns an error if a single word exceeds maxTokens.
+func (m *Message) SplitByTokens(counter TokenCounter, maxTokens int) ([]*Message, error) {
+	if maxTokens <= 0 {
+		return nil, fmt.Errorf("max tokens must be positive, got %d", maxTokens)
+	}
+
+	newChunk := func(content string) *Message {
+		return &Message{Role: m.Role, Name: m.Name, Content: content}
+	}
+	fits := func(content string) (bool, error) {
+		n, err := counter(newChunk(content))
+		if err != nil {
+			return false, fmt.Errorf("failed to count tokens: %w", err)
+		}
+		return n <= maxTokens, nil
+	}
+
+	ok, err := fits(m.Content)
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		return []*Message{newChunk(m.Content)}, nil
+	}
+
+	splitters := []func(string) []string{splitParagraphs, splitSentences, splitWords}
+
+	// pieces recursively splits text with finer splitters until every piece fits.
+	var pieces func(text string, level int) ([]string, error)
+	pieces = func(text string, level int) ([]string, error) {
+		var ret []string
+		for _, p := range splitters[level](text) {
+			ok, err := fits(p)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				ret = append(ret, p)
+				continue
+			}
+			if level == len(splitters)-1 {
+				return nil, fmt.Errorf("word %q exceeds max tokens %d", strings.TrimSpace(p), maxTokens)
+			}
+			sub, err := pieces(p, level+1)
+			if err != nil {
+				return nil, err
+			}
+			ret = append(ret, sub...)
+		}
+		return ret, nil
+	}
+
+	ps, err := pieces(m.Content, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	var ret []*Message
+	var current string
+	flush := func() {
+		if c := strings.TrimSpace(current); c != "" {
+			ret = append(ret, newChunk(c))
+		}
+		current = ""
+	}
+	for _, p := range ps {
+		ok, err := fits(strings.TrimSpace(current + p))
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			flush()
+		}
+		current += p
+	}
+	flush()
+
+	return ret, nil
+}
+
+// splitParagraphs splits text after each blank line, keeping the separators so that joining the pieces restores text.
+func splitParagraphs(text string) []string {
+	return strings.SplitAfter(text, "\n\n")
+}
+
+// splitSentences splits text after each sentence terminator and the whitespace following it.
+func splitSentences(text string) []string {
+	var ret []string
+	start := 0
+	for i := 0; i < len(text); {
+		r, size := utf8.DecodeRuneInString(text[i:])
+		i += size
+
+		end := false
+		switch r {
+		case '。', '！', '？', '\n':
+			end = true
+		case '.', '!', '?':
+			next, _ := utf8.DecodeRuneInString(text[i:])
+			end = i == len(text) || unicode.IsSpace(next)
+		}
+		if !end {
+			continue
+		}
+
+		// the whitespace following the terminator belongs to the sentence
+		for i < len(text) {
+			next, nextSize := utf8.DecodeRuneInString(text[i:])
+			if !unicode.IsSpace(next) {
+				break
+			}
+			i += nextSize
+		}
+		ret = append(ret, text[start:i])
+		start = i
+	}
+	if start < len(text) {
+		ret = append(ret, text[start:])
+	}
+	return ret
+}
+
+// splitWords splits text after each run of whitespace.
+func splitWords(text string) []string {
+	var ret []string
+	start := 0
+	inSpace := false
+	for i, r := range text {
+		if unicode.IsSpace(r) {
+			inSpace = true
+			continue
+		}
+		if inSpace && i > start {
+			ret = append(ret, text[start:i])
+			start = i
+		}
+		inSpace = false
+	}
+	if start < len(text) {
+		ret = append(ret, text[start:])
+	}
+	return ret
+}
+
+type transcriptOptions struct {
+	separator   string
+	redactMedia bool
+}
+
+// TranscriptOption is the option for FlattenTranscript.
+type TranscriptOption func(*transcriptOptions)
+
+// WithTranscriptSeparator sets the separator between messages in the transcript. Default is a blank line.
+func WithTranscriptSeparator(sep string) TranscriptOption {
+	return func(o *transcriptOptions) {
+		o.separator = sep
+	}
+}
+
+// WithTranscriptRedactMedia replaces the image, audio, video and file parts in the transcript with placeholders like "[image]",
+// so that urls and media metadata are left out.
+func WithTranscriptRedactMedia() TranscriptOption {
+	return func(o *transcriptOptions) {
+		o.redactMedia = true
+	}
+}
+
+// FlattenTranscript renders the conversation as a readable transcript for logging or prompt debugging,
+// joining the Message.String of each message with the separator. Nil messages are skipped.
+// e.g.
+//
+//	log.Println(schema.FlattenTranscript(msgs, schema.WithTranscriptRedactMedia()))
+func FlattenTranscript(msgs []*Message, opts ...TranscriptOption) string {
+	o := &transcriptOptions{
+		separator: "\n\n",
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	entries := make([]string, 0, len(msgs))
+	for _, msg := range msgs {
+		if msg == nil {
+			continue
+		}
+		if o.redactMedia {
+			msg = redactMessageMedia(msg)
+		}
+		entries = append(entries, msg.String())
+	}
+
+	return strings.Join(entries, o.separator)
+}
+
+// redactMessageMedia returns a shallow copy of msg whose media parts are replaced with text placeholders.
+func redactMessageMedia(msg *Message) *Message {
+	copied := *msg
+
+	if len(msg.UserInputMultiContent) > 0 {
+		copied.UserInputMultiContent = make([]MessageInputPart, len(msg.UserInputMultiContent))
+		for i, part := range msg.UserInputMultiContent {
+			if part.Type != ChatMessagePartTypeText {
+				part = MessageInputPart{Type: ChatMessagePartTypeText, Text: mediaPlaceholder(part.Type)}
+			}
+			copied.UserInputMultiContent[i] = part
+		}
+	}
+
+	if len(msg.AssistantGenMultiContent) > 0 {
+		copied.AssistantGenMultiContent = make([]MessageOutputPart, len(msg.AssistantGenMultiContent))
+		for i, part := range msg.AssistantGenMultiContent {
+			if part.Type != ChatMessagePartTypeText {
+				part = MessageOutputPart{Type: ChatMessagePartTypeText, Text: mediaPlaceholder(part.Type)}
+			}
+			copied.AssistantGenMultiContent[i] = part
+		}
+	}
+
+	if len(msg.MultiContent) > 0 {
+		copied.MultiContent = make([]ChatMessagePart, len(msg.MultiContent))
+		for i, part := range msg.MultiContent {
+			if part.Type != ChatMessagePartTypeText {
+				part = ChatMessagePart{Type: ChatMessagePartTypeText, Text: mediaPlaceholder(part.Type)}
+			}
+			copied.MultiContent[i] = part
+		}
+	}
+
+	return &copied
+}
+
+func mediaPlaceholder(typ ChatMessagePartType) string {
+	switch typ {
+	case ChatMessagePartTypeImageURL:
+		return "[image]"
+	case ChatMessagePartTypeAudioURL:
+		return "[audio]"
+	case ChatMessagePartTypeVideoURL:
+		return "[video]"
+	case ChatMessagePartTypeFileURL:
+		return "[file]"
+	default:
+		return "[media]"
+	}
+}
+
+type conversationStartOptions struct {
+	startRoles       []RoleType
+	requireUserFirst bool
+}
+
+// ConversationStartOption is the option for ValidateConversationStart.
+type ConversationStartOption func(*conversationStartOptions)
+
+// WithConversationStartRoles sets the roles allowed for the first message. Default is system and user.
+func WithConversationStartRoles(roles ...RoleType) ConversationStartOption {
+	return func(o *conversationStartOptions) {
+		o.startRoles = roles
+	}
+}
+
+// WithRequireUserFirst sets whether the first message after the leading system messages must be a user message. Default is true.
+// Disable it for providers accepting an assistant message right after the system prompt, e.g. a greeting.
+func WithRequireUserFirst(require bool) ConversationStartOption {
+	return func(o *conversationStartOptions) {
+		o.requireUserFirst = require
+	}
+}
+
+// ValidateConversationStart checks that msgs forms a valid start of a conversation before sending it to the model,
+// which catches a common cause of request rejection by the providers.
+// By default, the first message must be a system or user message, see WithConversationStartRoles,
+// and the first message after the leading system messages must be a user message, see WithRequireUserFirst.
+// A tool message is never allowed there, as there is no prior tool call for it to respond to.
+// e.g.
+//
+//	if err := schema.ValidateConversationStart(msgs); err != nil {
+//		return err
+//	}
+func ValidateConversationStart(msgs []*Message, opts ...ConversationStartOption) error {
+	o := &conversationStartOptions{
+		startRoles:       []RoleType{System, User},
+		requireUserFirst: true,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	if len(msgs) == 0 {
+		return fmt.Errorf("conversation is empty")
+	}
+	for i, msg := range msgs {
+		if msg == nil {
+			return fmt.Errorf("message[%d] is nil", i)
+		}
+	}
+
+	allowed := false
+	for _, role := range o.startRoles {
+		if msgs[0].Role == role {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		return fmt.Errorf("conversation cannot start with a %s message", msgs[0].Role)
+	}
+
+	for i, msg := range msgs {
+		if msg.Role == System {
+			continue
+		}
+		if msg.Role == Tool {
+			return fmt.Errorf("message[%d] is a tool message without a prior tool call", i)
+		}
+		if o.requireUserFirst && msg.Role != User {
+			return fmt.Errorf("the first non-system message must be a user message, got %s at message[%d]", msg.Role, i)
+		}
+		break
+	}
+
+	return nil
+}
+
+// MergeConsecutiveSameRole merges the adjacent messages sharing the same role and name into one,
+// for the providers rejecting consecutive messages of the same role.
+// The contents and the reasoning contents are joined by joiner, which defaults to joining with a blank line if nil,
+// and the multi-content parts are concatenated. The other fields are taken from the first message.
+// To keep the pairing of tool calls and tool results, tool messages are never merged,
+// and an assistant message with tool calls is never merged with the message after it.
+// The input slice and messages are not modified.
+// e.g.
+//
+//	msgs = schema.MergeConsecutiveSameRole(msgs, func(a, b string) string {
+//		return a + "\n" + b
+//	})
+func MergeConsecutiveSameRole(msgs []*Message, joiner func(a, b string) string) []*Message {
+	if joiner == nil {
+		joiner = func(a, b string) string {
+			return a + "\n\n" + b
+		}
+	}
+	join := func(a, b string) string {
+		if a == "" {
+			return b
+		}
+		if b == "" {
+			return a
+		}
+		return joiner(a, b)
+	}
+
+	ret := make([]*Message, 0, len(msgs))
+	merged := false // whether the last message in ret is a copy created by merging
+	for _, msg := range msgs {
+		if len(ret) == 0 || !canMergeMessages(ret[len(ret)-1], msg) {
+			ret = append(ret, msg)
+			merged = false
+			continue
+		}
+
+		last := ret[len(ret)-1]
+		if !merged {
+			cp := *last
+			cp.MultiContent = append([]ChatMessagePart(nil), last.MultiContent...)
+			cp.UserInputMultiContent = append([]MessageInputPart(nil), last.UserInputMultiContent...)
+			cp.AssistantGenMultiContent = append([]MessageOutputPart(nil), last.AssistantGenMultiContent...)
+			last = &cp
+			ret[len(ret)-1] = last
+			merged = true
+		}
+
+		last.Content = join(last.Content, msg.Content)
+		last.ReasoningContent = join(last.ReasoningContent, msg.ReasoningContent)
+		last.MultiContent = append(last.MultiContent, msg.MultiContent...)
+		last.UserInputMultiContent = append(last.UserInputMultiContent, msg.UserInputMultiContent...)
+		last.AssistantGenMultiContent = append(last.AssistantGenMultiContent, msg.AssistantGenMultiContent...)
+		last.ToolCalls = msg.ToolCalls
+	}
+
+	return ret
+}
+
+func canMergeMessages(prev, next *Message) bool {
+	if prev == nil || next == nil {
+		return false
+	}
+	return prev.Role == next.Role && prev.Name == next.Name && prev.Role != Tool && len(prev.ToolCalls) == 0
+}
+
+// WithoutToolCalls returns a copy of the message with the tool calls and the tool call id removed, while the content is kept,
+// e.g. for replaying the history to a model which shouldn't see the prior tool calls.
+// The original message is not modified.
+func (m *Message) WithoutToolCalls() *Message {
+	if m == nil {
+		return nil
+	}
+
+	cp := *m
+	cp.ToolCalls = nil
+	cp.ToolCallID = ""
+
+	return &cp
+}
+
+// FlattenGenContent returns a copy of the message with the text parts of AssistantGenMultiContent joined by newlines into Content,
+// for providers accepting only scalar content. The other parts are kept in AssistantGenMultiContent in order,
+// which is set to nil if all parts are text.
+// As in ToOpenAIJSON and ToAnthropicBlocks, AssistantGenMultiContent takes precedence over Content,
+// so the joined text replaces the original Content. If there is no text part, the copy is left as is.
+// The original message is not modified.
+func (m *Message) FlattenGenContent() *Message {
+	if m == nil {
+		return nil
+	}
+
+	cp := *m
+
+	var (
+		texts  []string
+		others []MessageOutputPart
+	)
+	for _, part := range m.AssistantGenMultiContent {
+		if part.Type == ChatMessagePartTypeText {
+			texts = append(texts, part.Text)
+		} else {
+			others = append(others, part)
+		}
+	}
+	if len(texts) == 0 {
+		return &cp
+	}
+
+	cp.Content = strings.Join(texts, "\n")
+	cp.AssistantGenMultiContent = others
+
+	return &cp
+}
+
+type textOnlyOptions struct {
+	toolCallArguments bool
+}
+
+// TextOnlyOption is the option for Message.TextOnly.
+type TextOnlyOption func(*textOnlyOptions)
+
+// WithTextOnlyToolCallArguments makes Message.TextOnly include the arguments of the tool calls, which are left out by default.
+func WithTextOnlyToolCallArguments() TextOnlyOption {
+	return func(o *textOnlyOptions) {
+		o.toolCallArguments = true
+	}
+}
+
+// TextOnly returns the textual content of the message joined by newlines, for estimating the text tokens, e.g. in a TokenCounter,
+// which consists of Content, ReasoningContent and the text parts of UserInputMultiContent, AssistantGenMultiContent and MultiContent.
+// The media parts, e.g. base64 images, are left out, and so are the tool calls unless WithTextOnlyToolCallArguments is set.
+// Empty texts are skipped.
+func (m *Message) TextOnly(opts ...TextOnlyOption) string {
+	if m == nil {
+		return ""
+	}
+
+	o := &textOnlyOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	var texts []string
+	appendText := func(text string) {
+		if text != "" {
+			texts = append(texts, text)
+		}
+	}
+
+	appendText(m.Content)
+	appendText(m.ReasoningContent)
+	for _, part := range m.UserInputMultiContent {
+		if part.Type == ChatMessagePartTypeText {
+			appendText(part.Text)
+		}
+	}
+	for _, part := range m.AssistantGenMultiContent {
+		if part.Type == ChatMessagePartTypeText {
+			appendText(part.Text)
+		}
+	}
+	for _, part := range m.MultiContent {
+		if part.Type == ChatMessagePartTypeText {
+			appendText(part.Text)
+		}
+	}
+	if o.toolCallArguments {
+		for _, tc := range m.ToolCalls {
+			appendText(tc.Function.Arguments)
+		}
+	}
+
+	return strings.Join(texts, "\n")
+}
+
+// FinalAnswer returns the final answer of the latest turn of a conversation, e.g. after an agent loop,
+// which is the last assistant message without tool calls and with non-empty content.
+// It scans backward, skipping the tool messages and the intermediate assistant messages, and stops at the last user message,
+// so that the answer of a previous turn is not returned for a turn that ended without one.
+// ok is false if no such message is found.
+func FinalAnswer(msgs []*Message) (answer *Message, ok bool) {
+	for i := len(msgs) - 1; i >= 0; i-- {
+		msg := msgs[i]
+		if msg == nil {
+			continue
+		}
+		if msg.Role == User {
+			return nil, false
+		}
+		if msg.Role == Assistant && len(msg.ToolCalls) == 0 &&
+			(msg.Content != "" || len(msg.AssistantGenMultiContent) > 0) {
+			return msg, true
+		}
+	}
+
+	return nil, false
+}
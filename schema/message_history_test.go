@@ -0,0 +1,391 @@
+/*
+ * Copyright 2026 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package schema
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTrimMessages(t *testing.T) {
+	counter := func(msg *Message) (int, error) {
+		return len(msg.Content), nil
+	}
+
+	sys := SystemMessage("sys")
+	u1 := UserMessage("user-1")
+	a1 := AssistantMessage("assistant-1", nil)
+	u2 := UserMessage("user-2")
+	a2 := AssistantMessage("assistant-2", nil)
+	msgs := []*Message{sys, u1, a1, u2, a2}
+
+	t.Run("under budget", func(t *testing.T) {
+		trimmed, err := TrimMessages(msgs, counter, 100)
+		assert.NoError(t, err)
+		assert.Equal(t, msgs, trimmed)
+	})
+
+	t.Run("drop oldest", func(t *testing.T) {
+		trimmed, err := TrimMessages(msgs, counter, 30)
+		assert.NoError(t, err)
+		assert.Equal(t, []*Message{sys, u2, a2}, trimmed)
+		assert.Len(t, msgs, 5)
+	})
+
+	t.Run("keep last user", func(t *testing.T) {
+		trimmed, err := TrimMessages(msgs, counter, 9)
+		assert.NoError(t, err)
+		assert.Equal(t, []*Message{sys, u2}, trimmed)
+	})
+
+	t.Run("keep last n", func(t *testing.T) {
+		trimmed, err := TrimMessages(msgs, counter, 20, WithTrimKeepLastN(1))
+		assert.NoError(t, err)
+		assert.Equal(t, []*Message{sys, u2, a2}, trimmed)
+	})
+
+	t.Run("drop system", func(t *testing.T) {
+		trimmed, err := TrimMessages(msgs, counter, 6, WithTrimKeepSystem(false))
+		assert.NoError(t, err)
+		assert.Equal(t, []*Message{u2}, trimmed)
+	})
+
+	t.Run("pinned exceed budget", func(t *testing.T) {
+		_, err := TrimMessages(msgs, counter, 5)
+		assert.Error(t, err)
+	})
+
+	t.Run("tool call groups", func(t *testing.T) {
+		call := AssistantMessage("call", []ToolCall{{ID: "call_1"}, {ID: "call_2"}})
+		r1 := ToolMessage("result-1", "call_1")
+		r2 := ToolMessage("result-2", "call_2")
+		history := []*Message{sys, u1, call, r1, r2, u2, a2}
+
+		trimmed, err := TrimMessages(history, counter, 40)
+		assert.NoError(t, err)
+		assert.Equal(t, []*Message{sys, call, r1, r2, u2, a2}, trimmed)
+
+		trimmed, err = TrimMessages(history, counter, 35)
+		assert.NoError(t, err)
+		assert.Equal(t, []*Message{sys, u2, a2}, trimmed)
+
+		// pinning a tool result pins its call
+		history = []*Message{sys, a1, call, r1, r2}
+		trimmed, err = TrimMessages(history, counter, 25, WithTrimKeepLastN(1))
+		assert.NoError(t, err)
+		assert.Equal(t, []*Message{sys, call, r1, r2}, trimmed)
+		_, err = TrimMessages(history, counter, 20, WithTrimKeepLastN(1))
+		assert.Error(t, err)
+	})
+
+	t.Run("nil message", func(t *testing.T) {
+		_, err := TrimMessages([]*Message{sys, nil, u1}, counter, 100)
+		assert.ErrorContains(t, err, "message[1] is nil")
+	})
+
+	t.Run("counter error", func(t *testing.T) {
+		_, err := TrimMessages(msgs, func(msg *Message) (int, error) {
+			return 0, errors.New("counter error")
+		}, 5)
+		assert.Error(t, err)
+	})
+}
+
+func TestDedupMessages(t *testing.T) {
+	sys := SystemMessage("you are a helpful assistant")
+	u1 := UserMessage("hi")
+	a1 := AssistantMessage("", []ToolCall{{ID: "call_1", Function: FunctionCall{Name: "get_time"}}})
+	a1Dup := AssistantMessage("", []ToolCall{{ID: "call_1", Function: FunctionCall{Name: "get_time"}}})
+	a1Dup.ResponseMeta = &ResponseMeta{FinishReason: "tool_calls"}
+	a2 := AssistantMessage("", []ToolCall{{ID: "call_2", Function: FunctionCall{Name: "get_time"}}})
+
+	t.Run("consecutive", func(t *testing.T) {
+		msgs := []*Message{sys, SystemMessage("you are a helpful assistant"), u1, a1, a1Dup, a2, sys}
+		assert.Equal(t, []*Message{sys, u1, a1, a2, sys}, DedupMessages(msgs))
+		assert.Len(t, msgs, 7)
+	})
+
+	t.Run("non consecutive", func(t *testing.T) {
+		msgs := []*Message{sys, u1, sys, UserMessage("hi"), a1, a2, a1Dup}
+		assert.Equal(t, []*Message{sys, u1, sys, UserMessage("hi"), a1, a2, a1Dup}, DedupMessages(msgs))
+		assert.Equal(t, []*Message{sys, u1, a1, a2}, DedupMessages(msgs, WithDedupNonConsecutive()))
+	})
+
+	t.Run("different roles", func(t *testing.T) {
+		msgs := []*Message{UserMessage("ok"), AssistantMessage("ok", nil)}
+		assert.Len(t, DedupMessages(msgs), 2)
+	})
+}
+
+func TestMessageSplitByTokens(t *testing.T) {
+	wordCounter := func(msg *Message) (int, error) {
+		return len(strings.Fields(msg.Content)), nil
+	}
+
+	t.Run("sentences", func(t *testing.T) {
+		msg := &Message{
+			Role: User,
+			Name: "bob",
+			Content: "One two three four five. Six seven eight nine ten! Eleven twelve thirteen fourteen fifteen? " +
+				"Sixteen seventeen eighteen nineteen twenty. Twenty-one two three four five. Twenty-six seven eight nine thirty.",
+		}
+
+		chunks, err := msg.SplitByTokens(wordCounter, 10)
+		assert.NoError(t, err)
+		assert.Equal(t, []*Message{
+			{Role: User, Name: "bob", Content: "One two three four five. Six seven eight nine ten!"},
+			{Role: User, Name: "bob", Content: "Eleven twelve thirteen fourteen fifteen? Sixteen seventeen eighteen nineteen twenty."},
+			{Role: User, Name: "bob", Content: "Twenty-one two three four five. Twenty-six seven eight nine thirty."},
+		}, chunks)
+	})
+
+	t.Run("paragraphs first", func(t *testing.T) {
+		msg := UserMessage("a b c.\n\nd e f. g h.\n\ni j k l m n o")
+
+		chunks, err := msg.SplitByTokens(wordCounter, 5)
+		assert.NoError(t, err)
+		var contents []string
+		for _, c := range chunks {
+			contents = append(contents, c.Content)
+		}
+		assert.Equal(t, []string{"a b c.", "d e f. g h.", "i j k l m", "n o"}, contents)
+	})
+
+	t.Run("fits", func(t *testing.T) {
+		chunks, err := UserMessage("short text").SplitByTokens(wordCounter, 5)
+		assert.NoError(t, err)
+		assert.Len(t, chunks, 1)
+		assert.Equal(t, "short text", chunks[0].Content)
+	})
+
+	t.Run("word too long", func(t *testing.T) {
+		charCounter := func(msg *Message) (int, error) {
+			return len(msg.Content), nil
+		}
+		_, err := UserMessage("a supercalifragilistic word").SplitByTokens(charCounter, 10)
+		assert.Error(t, err)
+	})
+
+	t.Run("invalid budget", func(t *testing.T) {
+		_, err := UserMessage("text").SplitByTokens(wordCounter, 0)
+		assert.Error(t, err)
+	})
+}
+
+func TestFlattenTranscript(t *testing.T) {
+	imgURL := "https://example.com/cat.png"
+	msgs := []*Message{
+		SystemMessage("you are a helpful assistant"),
+		{
+			Role: User,
+			UserInputMultiContent: []MessageInputPart{
+				{Type: ChatMessagePartTypeText, Text: "what is in the image?"},
+				{Type: ChatMessagePartTypeImageURL, Image: &MessageInputImage{MessagePartCommon: MessagePartCommon{URL: &imgURL}}},
+			},
+		},
+		nil,
+		AssistantMessage("a cat", nil),
+	}
+
+	transcript := FlattenTranscript(msgs)
+	assert.Equal(t, msgs[0].String()+"\n\n"+msgs[1].String()+"\n\n"+msgs[3].String(), transcript)
+	sysIdx := strings.Index(transcript, "system: you are a helpful assistant")
+	userIdx := strings.Index(transcript, "user: ")
+	assistantIdx := strings.Index(transcript, "assistant: a cat")
+	assert.True(t, sysIdx >= 0 && sysIdx < userIdx && userIdx < assistantIdx)
+	assert.Contains(t, transcript, imgURL)
+
+	redacted := FlattenTranscript(msgs, WithTranscriptSeparator("\n---\n"), WithTranscriptRedactMedia())
+	assert.NotContains(t, redacted, imgURL)
+	assert.Contains(t, redacted, "text: [image]")
+	assert.Equal(t, 2, strings.Count(redacted, "\n---\n"))
+	// the original message is not modified
+	assert.Equal(t, ChatMessagePartTypeImageURL, msgs[1].UserInputMultiContent[1].Type)
+}
+
+func TestValidateConversationStart(t *testing.T) {
+	assert.NoError(t, ValidateConversationStart([]*Message{UserMessage("hi"), AssistantMessage("hello", nil)}))
+	assert.NoError(t, ValidateConversationStart([]*Message{SystemMessage("be nice"), UserMessage("hi")}))
+
+	err := ValidateConversationStart([]*Message{ToolMessage("result", "call_1"), UserMessage("hi")})
+	assert.ErrorContains(t, err, "cannot start with a tool message")
+
+	err = ValidateConversationStart([]*Message{SystemMessage("be nice"), AssistantMessage("hello", nil)})
+	assert.ErrorContains(t, err, "must be a user message")
+	assert.NoError(t, ValidateConversationStart([]*Message{SystemMessage("be nice"), AssistantMessage("hello", nil)},
+		WithRequireUserFirst(false)))
+
+	err = ValidateConversationStart([]*Message{SystemMessage("be nice"), ToolMessage("result", "call_1")}, WithRequireUserFirst(false))
+	assert.ErrorContains(t, err, "without a prior tool call")
+
+	err = ValidateConversationStart([]*Message{SystemMessage("be nice"), UserMessage("hi")}, WithConversationStartRoles(User))
+	assert.ErrorContains(t, err, "cannot start with a system message")
+
+	assert.Error(t, ValidateConversationStart(nil))
+	assert.Error(t, ValidateConversationStart([]*Message{nil}))
+}
+
+func TestMergeConsecutiveSameRole(t *testing.T) {
+	first := UserMessage("hello")
+	msgs := []*Message{
+		SystemMessage("be nice"),
+		first,
+		UserMessage("how are you?"),
+		AssistantMessage("", []ToolCall{{ID: "call_1", Function: FunctionCall{Name: "mood"}}}),
+		AssistantMessage("ignored pairing", nil),
+		ToolMessage("fine", "call_1"),
+		ToolMessage("great", "call_2"),
+		AssistantMessage("I'm fine.", nil),
+		AssistantMessage("", []ToolCall{{ID: "call_3"}}),
+	}
+
+	merged := MergeConsecutiveSameRole(msgs, func(a, b string) string { return a + " " + b })
+	assert.Len(t, merged, 7)
+	assert.Equal(t, "hello how are you?", merged[1].Content)
+	assert.Equal(t, User, merged[1].Role)
+	// tool calls and tool messages keep their pairing
+	assert.Equal(t, "call_1", merged[2].ToolCalls[0].ID)
+	assert.Equal(t, "ignored pairing", merged[3].Content)
+	assert.Equal(t, "fine", merged[4].Content)
+	assert.Equal(t, "great", merged[5].Content)
+	// an assistant message without tool calls can be merged with the following one carrying tool calls
+	assert.Equal(t, "I'm fine.", merged[6].Content)
+	assert.Equal(t, "call_3", merged[6].ToolCalls[0].ID)
+
+	// the input is not modified
+	assert.Equal(t, "hello", first.Content)
+	assert.Len(t, msgs, 9)
+
+	merged = MergeConsecutiveSameRole([]*Message{UserMessage("a"), UserMessage("b")}, nil)
+	assert.Len(t, merged, 1)
+	assert.Equal(t, "a\n\nb", merged[0].Content)
+}
+
+func TestMessageWithoutToolCalls(t *testing.T) {
+	msg := AssistantMessage("let me check", []ToolCall{{ID: "call_1", Function: FunctionCall{Name: "search"}}})
+	stripped := msg.WithoutToolCalls()
+	assert.Nil(t, stripped.ToolCalls)
+	assert.Equal(t, "let me check", stripped.Content)
+	assert.Equal(t, Assistant, stripped.Role)
+	assert.Len(t, msg.ToolCalls, 1)
+
+	toolMsg := ToolMessage("sunny", "call_1", WithToolName("search"))
+	stripped = toolMsg.WithoutToolCalls()
+	assert.Empty(t, stripped.ToolCallID)
+	assert.Equal(t, "sunny", stripped.Content)
+	assert.Equal(t, "call_1", toolMsg.ToolCallID)
+
+	assert.Nil(t, (*Message)(nil).WithoutToolCalls())
+}
+
+func TestFinalAnswer(t *testing.T) {
+	toolCall := ToolCall{ID: "call_1", Function: FunctionCall{Name: "get_weather", Arguments: `{"city":"Paris"}`}}
+	answer := AssistantMessage("It is sunny in Paris.", nil)
+	msgs := []*Message{
+		SystemMessage("you are a helpful assistant"),
+		UserMessage("how is the weather in Paris?"),
+		AssistantMessage("", []ToolCall{toolCall}),
+		ToolMessage("sunny", "call_1"),
+		answer,
+	}
+
+	got, ok := FinalAnswer(msgs)
+	assert.True(t, ok)
+	assert.Same(t, answer, got)
+
+	// the turn ended with a tool message, and the answer of the previous turn is not returned
+	_, ok = FinalAnswer(append(msgs,
+		UserMessage("and in London?"),
+		AssistantMessage("", []ToolCall{toolCall}),
+		ToolMessage("rainy", "call_1"),
+	))
+	assert.False(t, ok)
+
+	// empty trailing assistant messages are skipped
+	got, ok = FinalAnswer(append(msgs, AssistantMessage("", nil)))
+	assert.True(t, ok)
+	assert.Same(t, answer, got)
+
+	_, ok = FinalAnswer(nil)
+	assert.False(t, ok)
+}
+
+func TestMessageFlattenGenContent(t *testing.T) {
+	imageURL := "https://example.com/chart.png"
+	image := MessageOutputPart{Type: ChatMessagePartTypeImageURL, Image: &MessageOutputImage{MessagePartCommon: MessagePartCommon{URL: &imageURL}}}
+	msg := &Message{
+		Role:    Assistant,
+		Content: "stale",
+		AssistantGenMultiContent: []MessageOutputPart{
+			{Type: ChatMessagePartTypeText, Text: "Here is the chart:"},
+			image,
+			{Type: ChatMessagePartTypeText, Text: "Sales grew 10%."},
+		},
+	}
+
+	flat := msg.FlattenGenContent()
+	assert.Equal(t, "Here is the chart:\nSales grew 10%.", flat.Content)
+	assert.Equal(t, []MessageOutputPart{image}, flat.AssistantGenMultiContent)
+	// the original message is not modified
+	assert.Equal(t, "stale", msg.Content)
+	assert.Len(t, msg.AssistantGenMultiContent, 3)
+
+	flat = (&Message{Role: Assistant, AssistantGenMultiContent: []MessageOutputPart{{Type: ChatMessagePartTypeText, Text: "hi"}}}).FlattenGenContent()
+	assert.Equal(t, "hi", flat.Content)
+	assert.Nil(t, flat.AssistantGenMultiContent)
+
+	flat = AssistantMessage("plain", nil).FlattenGenContent()
+	assert.Equal(t, "plain", flat.Content)
+
+	assert.Nil(t, (*Message)(nil).FlattenGenContent())
+}
+
+func TestMessageTextOnly(t *testing.T) {
+	b64 := "iVBORw0KGgoAAAANSUhEUgAAAAEAAAAB"
+	msg := &Message{
+		Role:             Assistant,
+		Content:          "content",
+		ReasoningContent: "reasoning",
+		UserInputMultiContent: []MessageInputPart{
+			{Type: ChatMessagePartTypeText, Text: "input text"},
+			{Type: ChatMessagePartTypeImageURL, Image: &MessageInputImage{MessagePartCommon: MessagePartCommon{Base64Data: &b64}}},
+		},
+		AssistantGenMultiContent: []MessageOutputPart{
+			{Type: ChatMessagePartTypeAudioURL, Audio: &MessageOutputAudio{MessagePartCommon: MessagePartCommon{Base64Data: &b64}}},
+			{Type: ChatMessagePartTypeText, Text: "output text"},
+			{Type: ChatMessagePartTypeText},
+		},
+		MultiContent: []ChatMessagePart{
+			{Type: ChatMessagePartTypeText, Text: "legacy text"},
+			{Type: ChatMessagePartTypeImageURL, ImageURL: &ChatMessageImageURL{URL: "data:image/png;base64," + b64}},
+		},
+		ToolCalls: []ToolCall{
+			{ID: "1", Function: FunctionCall{Name: "search", Arguments: `{"q":"eino"}`}},
+		},
+	}
+
+	assert.Equal(t, "content\nreasoning\ninput text\noutput text\nlegacy text", msg.TextOnly())
+	assert.NotContains(t, msg.TextOnly(), b64)
+	assert.Equal(t, "content\nreasoning\ninput text\noutput text\nlegacy text\n{\"q\":\"eino\"}",
+		msg.TextOnly(WithTextOnlyToolCallArguments()))
+
+	assert.Equal(t, "", (&Message{Role: Assistant, ToolCalls: msg.ToolCalls}).TextOnly())
+	assert.Equal(t, "", (*Message)(nil).TextOnly())
+}
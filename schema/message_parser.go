@@ -18,6 +18,8 @@ package schema
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"strings"
 
@@ -139,3 +141,64 @@ func (p *MessageJSONParser[T]) parse(data string) (parsed T, err error) {
 
 	return parsed, nil
 }
+
+const markdownCodeFence = "```"
+
+// ExtractJSON returns the JSON in the content of the message, e.g. the structured output of a model,
+// which is either the whole content, or wrapped in markdown code fences like ```json ... ```.
+// The whole content is checked first, so that bare JSON whose strings contain fences is kept as is.
+// Otherwise, if there are multiple fenced code blocks, the first one containing valid JSON is returned.
+// An error is returned if no valid JSON is found.
+func (m *Message) ExtractJSON() (json.RawMessage, error) {
+	if m == nil {
+		return nil, errors.New("message is nil")
+	}
+
+	content := strings.TrimSpace(m.Content)
+	if json.Valid([]byte(content)) {
+		return json.RawMessage(content), nil
+	}
+
+	blocks := fencedCodeBlocks(content)
+	if len(blocks) == 0 {
+		return nil, errors.New("message content is not valid JSON and has no fenced code block")
+	}
+
+	for _, block := range blocks {
+		block = strings.TrimSpace(block)
+		if json.Valid([]byte(block)) {
+			return json.RawMessage(block), nil
+		}
+	}
+
+	return nil, fmt.Errorf("none of the %d fenced code blocks in message content is valid JSON", len(blocks))
+}
+
+// fencedCodeBlocks returns the bodies of the markdown fenced code blocks in text, without the info strings like "json".
+// An unclosed block extends to the end of text.
+func fencedCodeBlocks(text string) []string {
+	var blocks []string
+	for {
+		start := strings.Index(text, markdownCodeFence)
+		if start < 0 {
+			return blocks
+		}
+		text = text[start+len(markdownCodeFence):]
+
+		// skip the info string, unless the block is closed on the same line, e.g. ```{"a": 1}```
+		line := text
+		if nl := strings.IndexByte(text, '\n'); nl >= 0 {
+			line = text[:nl+1]
+		}
+		if !strings.Contains(line, markdownCodeFence) {
+			text = text[len(line):]
+		}
+
+		end := strings.Index(text, markdownCodeFence)
+		if end < 0 {
+			return append(blocks, text)
+		}
+		blocks = append(blocks, text[:end])
+		text = text[end+len(markdownCodeFence):]
+	}
+}
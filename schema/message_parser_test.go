@@ -177,3 +177,73 @@ func TestMessageJSONParser(t *testing.T) {
 	})
 
 }
+
+func TestMessageExtractJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+		wantErr string
+	}{
+		{
+			name:    "fenced",
+			content: "Here is the result:\n```json\n{\"id\": 1, \"name\": \"a\"}\n```\nDone.",
+			want:    `{"id": 1, "name": "a"}`,
+		},
+		{
+			name:    "fenced without info string",
+			content: "```\n[1, 2]\n```",
+			want:    `[1, 2]`,
+		},
+		{
+			name:    "inline fence",
+			content: "```{\"id\": 1}```",
+			want:    `{"id": 1}`,
+		},
+		{
+			name:    "bare",
+			content: "  {\"id\": 1}\n",
+			want:    `{"id": 1}`,
+		},
+		{
+			name:    "bare with fences in strings",
+			content: "{\"code\": \"```py\\nprint(1)\\n```\"}",
+			want:    "{\"code\": \"```py\\nprint(1)\\n```\"}",
+		},
+		{
+			name:    "first valid of multiple fences",
+			content: "```go\nfmt.Println()\n```\nthen\n```json\n{\"id\": 2}\n```\n```json\n{\"id\": 3}\n```",
+			want:    `{"id": 2}`,
+		},
+		{
+			name:    "unclosed fence",
+			content: "```json\n{\"id\": 1}",
+			want:    `{"id": 1}`,
+		},
+		{
+			name:    "invalid bare",
+			content: "the answer is 42.",
+			wantErr: "not valid JSON",
+		},
+		{
+			name:    "invalid fenced",
+			content: "```json\n{\"id\": 1,}\n```",
+			wantErr: "none of the 1 fenced code blocks",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := (&Message{Role: Assistant, Content: tt.content}).ExtractJSON()
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, string(got))
+		})
+	}
+
+	_, err := (*Message)(nil).ExtractJSON()
+	assert.Error(t, err)
+}
@@ -18,9 +18,12 @@ package schema
 
 import (
 	"context"
+	"encoding/json"
+	"io"
 	"reflect"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 
@@ -44,6 +47,9 @@ func TestMessageTemplate(t *testing.T) {
 	ms, err = goTemplateMessage.Format(ctx, map[string]any{"question": question}, GoTemplate)
 	assert.Nil(t, err)
 	assert.True(t, reflect.DeepEqual(expected, ms))
+	ms, err = jinja2Message.Format(ctx, map[string]any{"question": question}, Mustache)
+	assert.Nil(t, err)
+	assert.True(t, reflect.DeepEqual(expected, ms))
 
 	mp := MessagesPlaceholder("chat_history", false)
 	m1 := UserMessage("how are you?")
@@ -57,6 +63,78 @@ func TestMessageTemplate(t *testing.T) {
 	assert.Equal(t, ms[1], m2)
 }
 
+func TestFormatType(t *testing.T) {
+	assert.Equal(t, "FString", FString.String())
+	assert.Equal(t, "GoTemplate", GoTemplate.String())
+	assert.Equal(t, "Jinja2", Jinja2.String())
+	assert.Equal(t, "Mustache", Mustache.String())
+	assert.Equal(t, "FormatType(7)", FormatType(7).String())
+
+	for _, ft := range []FormatType{FString, GoTemplate, Jinja2, Mustache} {
+		assert.True(t, ft.Valid(), ft.String())
+	}
+	assert.False(t, FormatType(7).Valid())
+
+	_, err := UserMessage("hello").Format(context.Background(), map[string]any{}, FormatType(7))
+	assert.EqualError(t, err, "unsupported format type: 7")
+}
+
+func TestMustacheTemplate(t *testing.T) {
+	ctx := context.Background()
+	msg := &Message{
+		Role:    User,
+		Content: "{{#items}}- {{name}}\n{{/items}}{{^items}}no items{{/items}}{{! a comment }}",
+		UserInputMultiContent: []MessageInputPart{
+			{Type: ChatMessagePartTypeText, Text: "question: {{question}}"},
+		},
+	}
+
+	ms, err := msg.Format(ctx, map[string]any{
+		"items":    []map[string]any{{"name": "a<b"}, {"name": "c"}},
+		"question": "what's new?",
+	}, Mustache)
+	assert.NoError(t, err)
+	assert.Equal(t, "- a<b\n- c\n", ms[0].Content)
+	assert.Equal(t, "question: what's new?", ms[0].UserInputMultiContent[0].Text)
+
+	ms, err = msg.Format(ctx, map[string]any{}, Mustache)
+	assert.NoError(t, err)
+	assert.Equal(t, "no items", ms[0].Content)
+	assert.Equal(t, "question: ", ms[0].UserInputMultiContent[0].Text)
+
+	_, err = UserMessage("{{> /etc/passwd}}").Format(ctx, map[string]any{}, Mustache)
+	assert.ErrorContains(t, err, "disabled")
+
+	_, err = UserMessage("{{#unclosed}}").Format(ctx, map[string]any{}, Mustache)
+	assert.Error(t, err)
+}
+
+func TestConditionalMessage(t *testing.T) {
+	ctx := context.Background()
+	tpl := ConditionalMessage("style", SystemMessage("answer in {style} style"))
+
+	msgs, err := tpl.Format(ctx, map[string]any{"style": "pirate"}, FString)
+	assert.NoError(t, err)
+	assert.Equal(t, []*Message{SystemMessage("answer in pirate style")}, msgs)
+
+	for _, vs := range []map[string]any{
+		{},
+		{"style": nil},
+		{"style": ""},
+		{"style": false},
+		{"style": 0},
+		{"style": []string{}},
+	} {
+		msgs, err = tpl.Format(ctx, vs, FString)
+		assert.NoError(t, err)
+		assert.Empty(t, msgs, vs)
+	}
+
+	msgs, err = ConditionalMessage("verbose", SystemMessage("be verbose")).Format(ctx, map[string]any{"verbose": true}, GoTemplate)
+	assert.NoError(t, err)
+	assert.Equal(t, []*Message{SystemMessage("be verbose")}, msgs)
+}
+
 func TestConcatMessage(t *testing.T) {
 	t.Run("tool_call_normal_append", func(t *testing.T) {
 		expectMsg := &Message{
@@ -410,6 +488,15 @@ func TestConcatMessage(t *testing.T) {
 		assert.Equal(t, msgs[0].ResponseMeta.LogProbs.Content[0], msg.ResponseMeta.LogProbs.Content[0])
 		assert.Equal(t, msgs[0].ResponseMeta.LogProbs.Content[1], msg.ResponseMeta.LogProbs.Content[1])
 		assert.Equal(t, msgs[1].ResponseMeta.LogProbs.Content[0], msg.ResponseMeta.LogProbs.Content[2])
+
+		token, ok := msg.ResponseMeta.LogProbs.MinConfidenceToken()
+		assert.True(t, ok)
+		assert.Equal(t, "❤️", token.Token)
+
+		_, ok = (&LogProbs{}).MinConfidenceToken()
+		assert.False(t, ok)
+		_, ok = msgs[2].ResponseMeta.LogProbs.MinConfidenceToken()
+		assert.False(t, ok)
 	})
 
 	t.Run("fix unexpected setting ResponseMeta of the first element in slice after ConcatMessages", func(t *testing.T) {
@@ -590,6 +677,283 @@ func TestConcatMessage(t *testing.T) {
 	})
 }
 
+func TestConcatMessagesResponseMetaPolicy(t *testing.T) {
+	newMsgs := func() []*Message {
+		return []*Message{
+			{
+				Role:    Assistant,
+				Content: "a",
+				ResponseMeta: &ResponseMeta{
+					Usage: &TokenUsage{PromptTokens: 5, CompletionTokens: 1, TotalTokens: 6},
+				},
+			},
+			{
+				Content: "b",
+				ResponseMeta: &ResponseMeta{
+					FinishReason: "length",
+					Usage:        &TokenUsage{PromptTokens: 5, CompletionTokens: 4, TotalTokens: 9},
+				},
+			},
+			{
+				Content: "c",
+				ResponseMeta: &ResponseMeta{
+					FinishReason: "stop",
+					Usage:        &TokenUsage{PromptTokens: 0, CompletionTokens: 2, TotalTokens: 2},
+				},
+			},
+		}
+	}
+
+	t.Run("default", func(t *testing.T) {
+		// ConcatMessages keeps its signature for the callers storing it as a function value
+		var concat func([]*Message) (*Message, error) = ConcatMessages
+		msg, err := concat(newMsgs())
+		assert.NoError(t, err)
+		assert.Equal(t, "abc", msg.Content)
+		assert.Equal(t, "stop", msg.ResponseMeta.FinishReason)
+		assert.Equal(t, &TokenUsage{PromptTokens: 5, CompletionTokens: 4, TotalTokens: 9}, msg.ResponseMeta.Usage)
+	})
+
+	t.Run("finish_reason_first", func(t *testing.T) {
+		msg, err := ConcatMessagesWithOptions(newMsgs(), WithFinishReasonPolicy(FinishReasonPolicyFirst))
+		assert.NoError(t, err)
+		assert.Equal(t, "length", msg.ResponseMeta.FinishReason)
+	})
+
+	t.Run("usage_sum", func(t *testing.T) {
+		msg, err := ConcatMessagesWithOptions(newMsgs(), WithUsagePolicy(UsagePolicySum))
+		assert.NoError(t, err)
+		assert.Equal(t, &TokenUsage{PromptTokens: 10, CompletionTokens: 7, TotalTokens: 17}, msg.ResponseMeta.Usage)
+	})
+
+	t.Run("usage_last", func(t *testing.T) {
+		msgs := newMsgs()
+		msg, err := ConcatMessagesWithOptions(msgs, WithUsagePolicy(UsagePolicyLast))
+		assert.NoError(t, err)
+		assert.Equal(t, &TokenUsage{PromptTokens: 0, CompletionTokens: 2, TotalTokens: 2}, msg.ResponseMeta.Usage)
+		assert.NotSame(t, msgs[2].ResponseMeta.Usage, msg.ResponseMeta.Usage)
+	})
+
+	t.Run("usage_first", func(t *testing.T) {
+		msg, err := ConcatMessagesWithOptions(newMsgs(), WithUsagePolicy(UsagePolicyFirst))
+		assert.NoError(t, err)
+		assert.Equal(t, &TokenUsage{PromptTokens: 5, CompletionTokens: 1, TotalTokens: 6}, msg.ResponseMeta.Usage)
+	})
+
+	t.Run("stream", func(t *testing.T) {
+		msg, err := ConcatMessageStreamWithOptions(StreamReaderFromArray(newMsgs()), WithUsagePolicy(UsagePolicySum))
+		assert.NoError(t, err)
+		assert.Equal(t, 17, msg.ResponseMeta.Usage.TotalTokens)
+	})
+}
+
+func TestConcatMessagesRawResponse(t *testing.T) {
+	msgs := []*Message{
+		{Role: Assistant, Content: "a", RawResponse: json.RawMessage(`{"id":"1","delta":"a"}`)},
+		{Content: "b"},
+		{Content: "c", RawResponse: json.RawMessage(`{"id":"1","delta":"c","finish_reason":"stop"}`)},
+	}
+
+	msg, err := ConcatMessages(msgs)
+	assert.NoError(t, err)
+	assert.Equal(t, "abc", msg.Content)
+	assert.Equal(t, json.RawMessage(`{"id":"1","delta":"c","finish_reason":"stop"}`), msg.RawResponse)
+
+	data, err := json.Marshal(msg)
+	assert.NoError(t, err)
+	var restored Message
+	assert.NoError(t, json.Unmarshal(data, &restored))
+	assert.JSONEq(t, `{"id":"1","delta":"c","finish_reason":"stop"}`, string(restored.RawResponse))
+}
+
+func TestConcatMessagesAnnotations(t *testing.T) {
+	msgs := []*Message{
+		{Role: Assistant, Content: "Eino is a framework"},
+		{Content: " [1]", Annotations: []Annotation{{Type: AnnotationTypeURLCitation, URL: "https://a.com", Title: "A", StartIndex: 0, EndIndex: 19}}},
+		{Content: " written in Go [2]", Annotations: []Annotation{{Type: AnnotationTypeURLCitation, URL: "https://b.com", Title: "B", StartIndex: 24, EndIndex: 38}}},
+		{Content: "."},
+	}
+
+	msg, err := ConcatMessages(msgs)
+	assert.NoError(t, err)
+	assert.Equal(t, []Annotation{
+		{Type: AnnotationTypeURLCitation, URL: "https://a.com", Title: "A", StartIndex: 0, EndIndex: 19},
+		{Type: AnnotationTypeURLCitation, URL: "https://b.com", Title: "B", StartIndex: 24, EndIndex: 38},
+	}, msg.Annotations)
+	// the chunks are not modified
+	assert.Len(t, msgs[1].Annotations, 1)
+}
+
+func TestConcatMessagesCreatedAt(t *testing.T) {
+	t0 := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	t1 := t0.Add(100 * time.Millisecond)
+	t2 := t0.Add(300 * time.Millisecond)
+	msgs := []*Message{
+		{Role: Assistant, Content: "a", CreatedAt: &t0},
+		{Content: "b", CreatedAt: &t1},
+		{Content: "c", CreatedAt: &t2},
+		{ResponseMeta: &ResponseMeta{FinishReason: "stop"}},
+	}
+
+	msg, err := ConcatMessages(msgs)
+	assert.NoError(t, err)
+	assert.Equal(t, t0, *msg.CreatedAt)
+
+	msg, err = ConcatMessagesWithOptions(msgs, WithCreatedAtPolicy(CreatedAtPolicyLast))
+	assert.NoError(t, err)
+	assert.Equal(t, t2, *msg.CreatedAt)
+
+	msg, err = ConcatMessages([]*Message{{Role: Assistant, Content: "a"}, {Content: "b"}})
+	assert.NoError(t, err)
+	assert.Nil(t, msg.CreatedAt)
+
+	data, err := json.Marshal(msg)
+	assert.NoError(t, err)
+	assert.NotContains(t, string(data), "created_at")
+}
+
+func TestConcatMessagesModelName(t *testing.T) {
+	msgs := []*Message{
+		{Role: Assistant, Content: "a"},
+		{Content: "b", ModelName: "gpt-4o"},
+		{Content: "c", ModelName: "gpt-4o-mini"},
+		{ResponseMeta: &ResponseMeta{FinishReason: "stop"}},
+	}
+
+	msg, err := ConcatMessages(msgs)
+	assert.NoError(t, err)
+	assert.Equal(t, "abc", msg.Content)
+	assert.Equal(t, "gpt-4o", msg.ModelName)
+
+	data, err := json.Marshal(msg)
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), `"model_name":"gpt-4o"`)
+}
+
+func TestConcatMessagesInterleavedAssistantGenMultiContent(t *testing.T) {
+	imgURL := "https://example.com/chart.png"
+	msgs := []*Message{
+		{Role: Assistant, AssistantGenMultiContent: []MessageOutputPart{{Type: ChatMessagePartTypeText, Text: "Here is "}}},
+		{AssistantGenMultiContent: []MessageOutputPart{{Type: ChatMessagePartTypeText, Text: "the chart:"}}},
+		{AssistantGenMultiContent: []MessageOutputPart{{Type: ChatMessagePartTypeImageURL, Image: &MessageOutputImage{MessagePartCommon: MessagePartCommon{URL: &imgURL}}}}},
+		{AssistantGenMultiContent: []MessageOutputPart{{Type: ChatMessagePartTypeText, Text: "It shows "}}},
+		{AssistantGenMultiContent: []MessageOutputPart{{Type: ChatMessagePartTypeText, Text: "growth."}}},
+	}
+
+	msg, err := ConcatMessages(msgs)
+	assert.NoError(t, err)
+	assert.Equal(t, []MessageOutputPart{
+		{Type: ChatMessagePartTypeText, Text: "Here is the chart:"},
+		{Type: ChatMessagePartTypeImageURL, Image: &MessageOutputImage{MessagePartCommon: MessagePartCommon{URL: &imgURL}}},
+		{Type: ChatMessagePartTypeText, Text: "It shows growth."},
+	}, msg.AssistantGenMultiContent)
+}
+
+func TestConcatMessagesName(t *testing.T) {
+	msg, err := ConcatMessages([]*Message{
+		{Role: Assistant, Content: "a"},
+		{Name: "bot", Content: "b"},
+		{Content: "c"},
+		{Name: "bot", Content: "d"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "bot", msg.Name)
+	assert.Equal(t, "abcd", msg.Content)
+
+	_, err = ConcatMessages([]*Message{
+		{Role: Assistant, Content: "a"},
+		{Name: "bot", Content: "b"},
+		{Name: "other", Content: "c"},
+	})
+	assert.ErrorContains(t, err, "different names")
+}
+
+func TestConcatMessagesSkipNilChunks(t *testing.T) {
+	msgs := []*Message{nil, {Role: Assistant, Content: "a"}, nil, {Content: "b"}, nil}
+
+	_, err := ConcatMessages(msgs)
+	assert.ErrorContains(t, err, "unexpected nil chunk")
+
+	msg, err := ConcatMessagesWithOptions(msgs, WithSkipNilChunks(true))
+	assert.NoError(t, err)
+	assert.Equal(t, &Message{Role: Assistant, Content: "ab"}, msg)
+
+	msg, err = ConcatMessageStreamWithOptions(StreamReaderFromArray(msgs), WithSkipNilChunks(true))
+	assert.NoError(t, err)
+	assert.Equal(t, "ab", msg.Content)
+}
+
+func TestConcatMessagesToolCallMatchByID(t *testing.T) {
+	msgs := []*Message{
+		{Role: Assistant, ToolCalls: []ToolCall{{ID: "call_1", Type: "function", Function: FunctionCall{Name: "get_weather", Arguments: `{"city":`}}}},
+		{ToolCalls: []ToolCall{{ID: "call_2", Type: "function", Function: FunctionCall{Name: "get_time"}}}},
+		{ToolCalls: []ToolCall{{ID: "call_1", Function: FunctionCall{Arguments: `"Paris"}`}}}},
+		{ToolCalls: []ToolCall{{ID: "call_2", Function: FunctionCall{Arguments: `{}`}}}},
+	}
+
+	msg, err := ConcatMessagesWithOptions(msgs, WithToolCallMatchKey(ToolCallMatchByID))
+	assert.NoError(t, err)
+	assert.Equal(t, []ToolCall{
+		{ID: "call_1", Type: "function", Function: FunctionCall{Name: "get_weather", Arguments: `{"city":"Paris"}`}},
+		{ID: "call_2", Type: "function", Function: FunctionCall{Name: "get_time", Arguments: `{}`}},
+	}, msg.ToolCalls)
+
+	// the default index based matching keeps nil-index chunks apart
+	msg, err = ConcatMessages(msgs)
+	assert.NoError(t, err)
+	assert.Len(t, msg.ToolCalls, 4)
+
+	t.Run("chunks without id continue the preceding call", func(t *testing.T) {
+		msg, err := ConcatMessagesWithOptions([]*Message{
+			{Role: Assistant, ToolCalls: []ToolCall{{Function: FunctionCall{Arguments: "orphan"}}}},
+			{ToolCalls: []ToolCall{{ID: "call_1", Function: FunctionCall{Name: "search", Arguments: `{"q":`}}}},
+			{ToolCalls: []ToolCall{{Function: FunctionCall{Arguments: `"eino"}`}}}},
+		}, WithToolCallMatchKey(ToolCallMatchByID))
+		assert.NoError(t, err)
+		assert.Equal(t, []ToolCall{
+			{Function: FunctionCall{Arguments: "orphan"}},
+			{ID: "call_1", Function: FunctionCall{Name: "search", Arguments: `{"q":"eino"}`}},
+		}, msg.ToolCalls)
+	})
+}
+
+func TestSplitReasoningStream(t *testing.T) {
+	sr := StreamReaderFromArray([]*Message{
+		{Role: Assistant, ReasoningContent: "let me "},
+		{ReasoningContent: "think"},
+		{ReasoningContent: ".", Content: "The answer"},
+		{Content: " is 42"},
+		{ResponseMeta: &ResponseMeta{FinishReason: "stop"}},
+	})
+
+	reasoning, content := SplitReasoningStream(sr)
+	defer reasoning.Close()
+	defer content.Close()
+
+	var contents []string
+	for {
+		chunk, err := content.Recv()
+		if err == io.EOF {
+			break
+		}
+		assert.NoError(t, err)
+		contents = append(contents, chunk)
+	}
+
+	var reasonings []string
+	for {
+		chunk, err := reasoning.Recv()
+		if err == io.EOF {
+			break
+		}
+		assert.NoError(t, err)
+		reasonings = append(reasonings, chunk)
+	}
+
+	assert.Equal(t, []string{"let me ", "think", "."}, reasonings)
+	assert.Equal(t, []string{"The answer", " is 42"}, contents)
+}
+
 func TestConcatToolCalls(t *testing.T) {
 	t.Run("atomic_field_in_first_chunk", func(t *testing.T) {
 		givenToolCalls := []ToolCall{
@@ -906,7 +1270,7 @@ func TestFormatMultiContent(t *testing.T) {
 	}
 
 	t.Run("empty input", func(t *testing.T) {
-		out, err := formatMultiContent(nil, vs, FString)
+		out, err := formatMultiContent(nil, vs, FString, FormatOptions{})
 		assert.NoError(t, err)
 		assert.Equal(t, []ChatMessagePart{}, out)
 	})
@@ -920,7 +1284,7 @@ func TestFormatMultiContent(t *testing.T) {
 			{Type: ChatMessagePartTypeFileURL, FileURL: &ChatMessageFileURL{URL: "http://file/{id}.txt"}},
 		}
 
-		out, err := formatMultiContent(in, vs, FString)
+		out, err := formatMultiContent(in, vs, FString, FormatOptions{})
 		assert.NoError(t, err)
 		if assert.Len(t, out, len(in)) {
 			assert.Equal(t, "hello eino", out[0].Text)
@@ -938,14 +1302,14 @@ func TestFormatMultiContent(t *testing.T) {
 			{Type: ChatMessagePartTypeVideoURL, VideoURL: nil},
 			{Type: ChatMessagePartTypeFileURL, FileURL: nil},
 		}
-		out, err := formatMultiContent(in, vs, FString)
+		out, err := formatMultiContent(in, vs, FString, FormatOptions{})
 		assert.NoError(t, err)
 		assert.Equal(t, in, out)
 	})
 
 	t.Run("missing var should error in GoTemplate", func(t *testing.T) {
 		in := []ChatMessagePart{{Type: ChatMessagePartTypeText, Text: "hi {{.who}}"}}
-		_, err := formatMultiContent(in, map[string]any{"name": "x"}, GoTemplate)
+		_, err := formatMultiContent(in, map[string]any{"name": "x"}, GoTemplate, FormatOptions{})
 		assert.Error(t, err)
 	})
 
@@ -964,7 +1328,7 @@ func TestFormatUserInputMultiContent(t *testing.T) {
 	}
 
 	t.Run("empty input", func(t *testing.T) {
-		out, err := formatUserInputMultiContent(nil, vs, FString)
+		out, err := formatUserInputMultiContent(nil, vs, FString, FormatOptions{})
 		assert.NoError(t, err)
 		assert.Equal(t, []MessageInputPart{}, out)
 	})
@@ -978,7 +1342,7 @@ func TestFormatUserInputMultiContent(t *testing.T) {
 			{Type: ChatMessagePartTypeFileURL, File: &MessageInputFile{MessagePartCommon: MessagePartCommon{URL: makeStrPtr("/f/{file}.txt"), Base64Data: makeStrPtr("{b64}")}}},
 		}
 
-		out, err := formatUserInputMultiContent(in, vs, FString)
+		out, err := formatUserInputMultiContent(in, vs, FString, FormatOptions{})
 		assert.NoError(t, err)
 		if assert.Len(t, out, len(in)) {
 			assert.Equal(t, "hello world", out[0].Text)
@@ -998,7 +1362,7 @@ func TestFormatUserInputMultiContent(t *testing.T) {
 		in := []MessageInputPart{
 			{Type: ChatMessagePartTypeImageURL, Image: &MessageInputImage{MessagePartCommon: MessagePartCommon{URL: &empty, Base64Data: &empty}}},
 		}
-		out, err := formatUserInputMultiContent(in, vs, FString)
+		out, err := formatUserInputMultiContent(in, vs, FString, FormatOptions{})
 		assert.NoError(t, err)
 		if assert.Len(t, out, 1) {
 			assert.NotNil(t, out[0].Image.URL)
@@ -1463,6 +1827,300 @@ func TestConcatToolResults(t *testing.T) {
 		assert.Equal(t, ToolPartTypeVideo, result.Parts[1].Type)
 		assert.Equal(t, ToolPartTypeAudio, result.Parts[2].Type)
 	})
+
+	t.Run("part_ordering", func(t *testing.T) {
+		imageURL := "https://example.com/image.png"
+		fileURL := "https://example.com/report.pdf"
+
+		chunks := []*ToolResult{
+			{
+				Parts: []ToolOutputPart{
+					{Type: ToolPartTypeImage, Image: &ToolOutputImage{MessagePartCommon: MessagePartCommon{URL: &imageURL}}},
+					{Type: ToolPartTypeText, Text: "first "},
+					{Type: ToolPartTypeText, Text: "text"},
+				},
+			},
+			{
+				Parts: []ToolOutputPart{
+					{Type: ToolPartTypeFile, File: &ToolOutputFile{MessagePartCommon: MessagePartCommon{URL: &fileURL}}},
+					{Type: ToolPartTypeText, Text: "second text"},
+				},
+			},
+		}
+
+		// ConcatToolResults keeps its signature for the callers storing it as a function value
+		var concat func([]*ToolResult) (*ToolResult, error) = ConcatToolResults
+		result, err := concat(chunks)
+		assert.NoError(t, err)
+		assert.Equal(t, []ToolPartType{ToolPartTypeImage, ToolPartTypeText, ToolPartTypeFile, ToolPartTypeText},
+			[]ToolPartType{result.Parts[0].Type, result.Parts[1].Type, result.Parts[2].Type, result.Parts[3].Type})
+
+		result, err = ConcatToolResultsWithOptions(chunks, WithPartOrdering([]ToolPartType{ToolPartTypeText, ToolPartTypeImage}))
+		assert.NoError(t, err)
+		assert.Len(t, result.Parts, 4)
+		assert.Equal(t, "first text", result.Parts[0].Text)
+		assert.Equal(t, "second text", result.Parts[1].Text)
+		assert.Equal(t, ToolPartTypeImage, result.Parts[2].Type)
+		assert.Equal(t, ToolPartTypeFile, result.Parts[3].Type)
+	})
+
+	t.Run("sources", func(t *testing.T) {
+		chunks := []*ToolResult{
+			{
+				Parts: []ToolOutputPart{{Type: ToolPartTypeText, Text: "Paris is "}},
+				Sources: []Source{
+					{URL: "https://a.com", Title: "A"},
+					{URL: "https://b.com", Title: "B"},
+				},
+			},
+			{
+				Sources: []Source{
+					{URL: "https://b.com", Title: "B again"},
+				},
+			},
+			{
+				Parts: []ToolOutputPart{{Type: ToolPartTypeText, Text: "sunny"}},
+				Sources: []Source{
+					{URL: "https://c.com", Title: "C", Snippet: "sunny all day"},
+				},
+			},
+		}
+
+		result, err := ConcatToolResults(chunks)
+		assert.NoError(t, err)
+		assert.Equal(t, []Source{
+			{URL: "https://a.com", Title: "A"},
+			{URL: "https://b.com", Title: "B"},
+			{URL: "https://c.com", Title: "C", Snippet: "sunny all day"},
+		}, result.Sources)
+		assert.Equal(t, "parts:\n  [0] text: Paris is \n  [1] text: sunny"+
+			"\nsources:\n  [0] A (https://a.com)\n  [1] B (https://b.com)\n  [2] C (https://c.com): sunny all day", result.String())
+
+		result, err = ConcatToolResults([]*ToolResult{{Sources: []Source{{URL: "https://a.com"}}}})
+		assert.NoError(t, err)
+		assert.Empty(t, result.Parts)
+		assert.Len(t, result.Sources, 1)
+	})
+}
+
+func TestConcatToolResultsProgress(t *testing.T) {
+	chunks := []*ToolResult{
+		{Parts: []ToolOutputPart{{Type: ToolPartTypeProgress, Progress: &ToolProgress{Percent: 25, Message: "fetching"}}}},
+		{Parts: []ToolOutputPart{
+			{Type: ToolPartTypeText, Text: "found "},
+			{Type: ToolPartTypeProgress, Progress: &ToolProgress{Percent: 50, Message: "parsing"}},
+			{Type: ToolPartTypeText, Text: "3 items"},
+		}},
+		{Parts: []ToolOutputPart{{Type: ToolPartTypeProgress, Progress: &ToolProgress{Percent: 100, Message: "done"}}}},
+		{Parts: []ToolOutputPart{{Type: ToolPartTypeText, Text: "."}}},
+	}
+
+	result, err := ConcatToolResults(chunks)
+	assert.NoError(t, err)
+	assert.Equal(t, []ToolOutputPart{
+		{Type: ToolPartTypeText, Text: "found 3 items"},
+		{Type: ToolPartTypeText, Text: "."},
+		{Type: ToolPartTypeProgress, Progress: &ToolProgress{Percent: 100, Message: "done"}},
+	}, result.Parts)
+	assert.Contains(t, result.String(), "progress: 100% done")
+
+	inputParts, err := result.ToMessageInputParts()
+	assert.NoError(t, err)
+	assert.Equal(t, []MessageInputPart{
+		{Type: ChatMessagePartTypeText, Text: "found 3 items"},
+		{Type: ChatMessagePartTypeText, Text: "."},
+	}, inputParts)
+}
+
+func TestConcatToolResultsJSON(t *testing.T) {
+	t.Run("round trip", func(t *testing.T) {
+		tr := &ToolResult{Parts: []ToolOutputPart{{Type: ToolPartTypeJSON, JSON: json.RawMessage(`{"temp":21,"unit":"C"}`)}}}
+		data, err := json.Marshal(tr)
+		assert.NoError(t, err)
+		assert.JSONEq(t, `{"parts":[{"type":"json","json":{"temp":21,"unit":"C"}}]}`, string(data))
+
+		var restored ToolResult
+		assert.NoError(t, json.Unmarshal(data, &restored))
+		assert.Equal(t, ToolPartTypeJSON, restored.Parts[0].Type)
+		assert.JSONEq(t, `{"temp":21,"unit":"C"}`, string(restored.Parts[0].JSON))
+
+		inputParts, err := restored.ToMessageInputParts()
+		assert.NoError(t, err)
+		assert.Equal(t, []MessageInputPart{{Type: ChatMessagePartTypeText, Text: `{"temp":21,"unit":"C"}`}}, inputParts)
+	})
+
+	t.Run("streamed fragments", func(t *testing.T) {
+		result, err := ConcatToolResults([]*ToolResult{
+			{Parts: []ToolOutputPart{{Type: ToolPartTypeText, Text: "weather: "}, {Type: ToolPartTypeJSON, JSON: json.RawMessage(`{"temp":`)}}},
+			{Parts: []ToolOutputPart{{Type: ToolPartTypeJSON, JSON: json.RawMessage(`21}`)}, {Type: ToolPartTypeText, Text: "done"}}},
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, []ToolOutputPart{
+			{Type: ToolPartTypeText, Text: "weather: "},
+			{Type: ToolPartTypeJSON, JSON: json.RawMessage(`{"temp":21}`)},
+			{Type: ToolPartTypeText, Text: "done"},
+		}, result.Parts)
+	})
+
+	t.Run("complete values", func(t *testing.T) {
+		// in the same chunk
+		result, err := ConcatToolResults([]*ToolResult{
+			{Parts: []ToolOutputPart{
+				{Type: ToolPartTypeJSON, JSON: json.RawMessage(`{"a":1}`)},
+				{Type: ToolPartTypeJSON, JSON: json.RawMessage(`{"b":2}`)},
+			}},
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, []ToolOutputPart{
+			{Type: ToolPartTypeJSON, JSON: json.RawMessage(`{"a":1}`)},
+			{Type: ToolPartTypeJSON, JSON: json.RawMessage(`{"b":2}`)},
+		}, result.Parts)
+
+		// in different chunks
+		result, err = ConcatToolResults([]*ToolResult{
+			{Parts: []ToolOutputPart{{Type: ToolPartTypeJSON, JSON: json.RawMessage(`{"a":1}`)}}},
+			{Parts: []ToolOutputPart{{Type: ToolPartTypeJSON, JSON: json.RawMessage(`{"b":2}`)}}},
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, []ToolOutputPart{
+			{Type: ToolPartTypeJSON, JSON: json.RawMessage(`{"a":1}`)},
+			{Type: ToolPartTypeJSON, JSON: json.RawMessage(`{"b":2}`)},
+		}, result.Parts)
+	})
+
+	t.Run("fragments and complete values", func(t *testing.T) {
+		result, err := ConcatToolResults([]*ToolResult{
+			{Parts: []ToolOutputPart{{Type: ToolPartTypeJSON, JSON: json.RawMessage(`{"a":`)}}},
+			{Parts: []ToolOutputPart{
+				{Type: ToolPartTypeJSON, JSON: json.RawMessage(`"x"`)},
+				{Type: ToolPartTypeJSON, JSON: json.RawMessage(`[1]`)},
+			}},
+			{Parts: []ToolOutputPart{{Type: ToolPartTypeJSON, JSON: json.RawMessage(`}`)}}},
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, []ToolOutputPart{
+			{Type: ToolPartTypeJSON, JSON: json.RawMessage(`{"a":"x"}`)},
+			{Type: ToolPartTypeJSON, JSON: json.RawMessage(`[1]`)},
+		}, result.Parts)
+	})
+
+	t.Run("incomplete", func(t *testing.T) {
+		_, err := ConcatToolResults([]*ToolResult{
+			{Parts: []ToolOutputPart{{Type: ToolPartTypeJSON, JSON: json.RawMessage(`{"a":`)}}},
+			{Parts: []ToolOutputPart{{Type: ToolPartTypeJSON, JSON: json.RawMessage(`1`)}}},
+		})
+		assert.ErrorContains(t, err, "don't form valid json")
+	})
+}
+
+func TestToolOutputPartsToMessageOutputParts(t *testing.T) {
+	url := "https://example.com/a"
+	data := "AAAA"
+	common := MessagePartCommon{URL: &url, MIMEType: "x/y"}
+	base64Common := MessagePartCommon{Base64Data: &data, MIMEType: "x/y"}
+	extra := map[string]any{"k": "v"}
+
+	toolParts := []ToolOutputPart{
+		{Type: ToolPartTypeText, Text: "hello", Extra: extra},
+		{Type: ToolPartTypeImage, Image: &ToolOutputImage{MessagePartCommon: common}},
+		{Type: ToolPartTypeAudio, Audio: &ToolOutputAudio{MessagePartCommon: base64Common}},
+		{Type: ToolPartTypeVideo, Video: &ToolOutputVideo{MessagePartCommon: common}},
+	}
+	msgParts := []MessageOutputPart{
+		{Type: ChatMessagePartTypeText, Text: "hello", Extra: extra},
+		{Type: ChatMessagePartTypeImageURL, Image: &MessageOutputImage{MessagePartCommon: common}},
+		{Type: ChatMessagePartTypeAudioURL, Audio: &MessageOutputAudio{MessagePartCommon: base64Common}},
+		{Type: ChatMessagePartTypeVideoURL, Video: &MessageOutputVideo{MessagePartCommon: common}},
+	}
+
+	for i := range toolParts {
+		converted := ToolOutputPartsToMessageOutputParts(toolParts[i : i+1])
+		assert.Equal(t, msgParts[i:i+1], converted, toolParts[i].Type)
+		assert.Equal(t, toolParts[i:i+1], MessageOutputPartsToToolOutputParts(converted), toolParts[i].Type)
+	}
+
+	// json becomes text, while file and progress are skipped
+	converted := ToolOutputPartsToMessageOutputParts([]ToolOutputPart{
+		{Type: ToolPartTypeJSON, JSON: json.RawMessage(`{"a":1}`)},
+		{Type: ToolPartTypeFile, File: &ToolOutputFile{MessagePartCommon: common}},
+		{Type: ToolPartTypeProgress, Progress: &ToolProgress{Percent: 50}},
+	})
+	assert.Equal(t, []MessageOutputPart{{Type: ChatMessagePartTypeText, Text: `{"a":1}`}}, converted)
+
+	assert.Nil(t, ToolOutputPartsToMessageOutputParts(nil))
+	assert.Nil(t, MessageOutputPartsToToolOutputParts(nil))
+}
+
+func TestMergeNamedToolResults(t *testing.T) {
+	imageURL := "https://example.com/sun.png"
+	results := map[string]*ToolResult{
+		"weather": {
+			Parts: []ToolOutputPart{
+				{Type: ToolPartTypeText, Text: "sunny"},
+				{Type: ToolPartTypeImage, Image: &ToolOutputImage{MessagePartCommon: MessagePartCommon{URL: &imageURL}}},
+				{Type: ToolPartTypeText, Text: "25°C"},
+			},
+			Sources: []Source{{URL: "https://weather.com"}},
+		},
+		"news": {
+			Parts: []ToolOutputPart{{Type: ToolPartTypeText, Text: "no news"}},
+		},
+		"failed": nil,
+	}
+
+	merged := MergeNamedToolResults(results, func(name string) string { return "## " + name })
+	assert.Equal(t, &ToolResult{
+		Parts: []ToolOutputPart{
+			{Type: ToolPartTypeText, Text: "## news\nno news"},
+			{Type: ToolPartTypeText, Text: "## weather\nsunny\n25°C"},
+			results["weather"].Parts[1],
+		},
+		Sources: []Source{{URL: "https://weather.com"}},
+	}, merged)
+
+	merged = MergeNamedToolResults(map[string]*ToolResult{"empty": {}}, nil)
+	assert.Equal(t, []ToolOutputPart{{Type: ToolPartTypeText, Text: "[empty]"}}, merged.Parts)
+
+	assert.Equal(t, &ToolResult{}, MergeNamedToolResults(nil, nil))
+}
+
+func TestToolResultStreamToText(t *testing.T) {
+	imageURL := "https://example.com/chart.png"
+	sr := StreamReaderFromArray([]*ToolResult{
+		{
+			Parts: []ToolOutputPart{
+				{Type: ToolPartTypeText, Text: "the chart "},
+				{Type: ToolPartTypeImage, Image: &ToolOutputImage{MessagePartCommon: MessagePartCommon{URL: &imageURL}}},
+				{Type: ToolPartTypeText, Text: "shows"},
+			},
+		},
+		{
+			Parts: []ToolOutputPart{
+				{Type: ToolPartTypeImage, Image: &ToolOutputImage{MessagePartCommon: MessagePartCommon{URL: &imageURL}}},
+			},
+		},
+		nil,
+		{
+			Parts: []ToolOutputPart{
+				{Type: ToolPartTypeText, Text: " growth"},
+			},
+		},
+	})
+
+	textStream := ToolResultStreamToText(sr)
+	defer textStream.Close()
+
+	var chunks []string
+	for {
+		chunk, err := textStream.Recv()
+		if err == io.EOF {
+			break
+		}
+		assert.NoError(t, err)
+		chunks = append(chunks, chunk)
+	}
+
+	assert.Equal(t, []string{"the chart shows", " growth"}, chunks)
 }
 
 func TestMessageString(t *testing.T) {
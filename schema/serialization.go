@@ -52,6 +52,9 @@ func init() {
 	RegisterName[MessagePartCommon]("_eino_message_part_common")
 	RegisterName[ImageURLDetail]("_eino_image_url_detail")
 	RegisterName[PromptTokenDetails]("_eino_prompt_token_details")
+	RegisterName[Annotation]("_eino_annotation")
+	RegisterName[AnnotationType]("_eino_annotation_type")
+	RegisterName[CacheControl]("_eino_cache_control")
 }
 
 // RegisterName registers a type with a specific name for serialization. This is
@@ -144,3 +147,12 @@ func Register[T any]() {
 		panic(err)
 	}
 }
+
+// ValidateRegistry reports problems found in the serialization registry, such as
+// names or types whose registration was rejected because they were already taken
+// by another entry, and interface types used in fields of registered types that
+// have no registered implementer.
+// It is intended to be called in tests to catch registration drift.
+func ValidateRegistry() []error {
+	return serialization.ValidateRegistry()
+}
@@ -19,9 +19,12 @@ package schema
 import (
 	"bytes"
 	"encoding/gob"
+	"encoding/json"
 	"fmt"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
@@ -189,3 +192,114 @@ func TestRegisterStructWithUUIDField(t *testing.T) {
 
 	assert.Equal(t, original.ID, result.ID)
 }
+
+func TestMessageCheckpointRoundTrip(t *testing.T) {
+	createdAt := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	msg := &Message{
+		Role:        Assistant,
+		Content:     "hello",
+		RawResponse: json.RawMessage(`{"id":"resp-1","choices":[{"index":0}]}`),
+		Annotations: []Annotation{{
+			Type:       AnnotationTypeURLCitation,
+			URL:        "https://example.com",
+			Title:      "example",
+			StartIndex: 1,
+			EndIndex:   3,
+			Extra:      map[string]any{"k": "v"},
+		}},
+		Prefix:       true,
+		CacheControl: &CacheControl{Type: CacheControlTypeEphemeral, TTL: time.Hour},
+		CreatedAt:    &createdAt,
+		ModelName:    "model-x",
+	}
+
+	s := &serialization.InternalSerializer{}
+	data, err := s.Marshal(msg)
+	assert.NoError(t, err)
+	var got *Message
+	assert.NoError(t, s.Unmarshal(data, &got))
+	assert.Equal(t, msg, got)
+
+	// in an interface-typed state, as saved by checkpoints
+	data, err = s.Marshal(map[string]any{"msg": msg})
+	assert.NoError(t, err)
+	var state map[string]any
+	assert.NoError(t, s.Unmarshal(data, &state))
+	assert.Equal(t, msg, state["msg"])
+}
+
+func TestInternalSerializerBatch(t *testing.T) {
+	type batchState struct {
+		Step  int
+		Notes []string
+	}
+	RegisterName[batchState]("_test_batch_state")
+
+	s := &serialization.InternalSerializer{}
+
+	msg := &Message{Role: Assistant, Content: "hello", ToolCalls: []ToolCall{{ID: "1", Function: FunctionCall{Name: "f", Arguments: "{}"}}}}
+	state := batchState{Step: 3, Notes: []string{"a", "b"}}
+	extra := map[string]any{"count": 2, "done": true}
+
+	data, err := s.MarshalBatch(msg, state, "text", extra)
+	assert.NoError(t, err)
+
+	var (
+		gotMsg   *Message
+		gotState batchState
+		gotText  string
+		gotExtra map[string]any
+	)
+	assert.NoError(t, s.UnmarshalBatch(data, &gotMsg, &gotState, &gotText, &gotExtra))
+	assert.Equal(t, msg, gotMsg)
+	assert.Equal(t, state, gotState)
+	assert.Equal(t, "text", gotText)
+	assert.Equal(t, extra, gotExtra)
+
+	err = s.UnmarshalBatch(data, &gotMsg, &gotState)
+	assert.ErrorContains(t, err, "value count mismatch")
+
+	err = s.UnmarshalBatch(data[:len(data)-1], &gotMsg, &gotState, &gotText, &gotExtra)
+	assert.ErrorContains(t, err, "data truncated")
+
+	data, err = s.MarshalBatch()
+	assert.NoError(t, err)
+	assert.NoError(t, s.UnmarshalBatch(data))
+}
+
+func TestValidateRegistry(t *testing.T) {
+	type conflictA struct{}
+	type conflictB struct{}
+	type stepper interface{ Step() }
+	type withInterface struct {
+		S stepper
+	}
+
+	// the registry is global and can't be reset, so only the errors of the entries added here are checked,
+	// and the registrations tolerate a previous run of this test
+	_ = serialization.GenericRegister[conflictA]("_test_conflict")
+	assert.Error(t, serialization.GenericRegister[conflictB]("_test_conflict"))
+	// registering the same pair again is not a conflict
+	assert.Error(t, serialization.GenericRegister[conflictA]("_test_conflict"))
+	_ = serialization.GenericRegister[withInterface]("_test_with_interface")
+
+	var conflictErrs, interfaceErrs []string
+	for _, err := range ValidateRegistry() {
+		switch msg := err.Error(); {
+		case strings.Contains(msg, "_test_conflict"):
+			conflictErrs = append(conflictErrs, msg)
+		case strings.Contains(msg, "withInterface"):
+			interfaceErrs = append(interfaceErrs, msg)
+		}
+	}
+
+	if assert.NotEmpty(t, conflictErrs) {
+		for _, msg := range conflictErrs {
+			assert.True(t, strings.HasSuffix(msg, "cannot register schema.conflictB"), msg)
+		}
+	}
+	if assert.Len(t, interfaceErrs, 1) {
+		assert.Contains(t, interfaceErrs[0], "stepper")
+		assert.Contains(t, interfaceErrs[0], "withInterface.S")
+	}
+}
@@ -0,0 +1,122 @@
+/*
+ * Copyright 2026 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package schema
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"runtime/debug"
+
+	"github.com/cloudwego/eino/internal/safe"
+)
+
+const sseDone = "[DONE]"
+
+// ParseSSEMessages reads server-sent events from r and turns them into a stream of messages.
+// The data lines of each event are joined and passed to decode, whose result is emitted as a chunk.
+// The stream ends when r reaches EOF or an event with data "[DONE]" is received.
+// Errors returned by decode are surfaced by Recv of the returned StreamReader, and parsing continues with the next event,
+// while a read error of r ends the stream after being surfaced.
+// Events without data, comments and other fields such as "event:" and "id:" are ignored.
+// e.g.
+//
+//	sr := schema.ParseSSEMessages(resp.Body, func(data []byte) (*schema.Message, error) {
+//		return decodeProviderChunk(data)
+//	})
+//	defer sr.Close()
+func ParseSSEMessages(r io.Reader, decode func([]byte) (*Message, error)) *StreamReader[*Message] {
+	sr, sw := Pipe[*Message](0)
+
+	go func() {
+		defer func() {
+			panicErr := recover()
+			if panicErr != nil {
+				_ = sw.Send(nil, safe.NewPanicErr(panicErr, debug.Stack()))
+			}
+
+			sw.Close()
+		}()
+
+		br := bufio.NewReader(r)
+		var data [][]byte
+
+		// dispatch returns true if the stream should stop.
+		dispatch := func() bool {
+			if len(data) == 0 {
+				return false
+			}
+			payload := bytes.Join(data, []byte("\n"))
+			data = data[:0]
+
+			if string(bytes.TrimSpace(payload)) == sseDone {
+				return true
+			}
+
+			msg, err := decode(payload)
+			return sw.Send(msg, err)
+		}
+
+		for {
+			line, err := br.ReadBytes('\n')
+			if len(line) > 0 {
+				line = bytes.TrimRight(line, "\r\n")
+				if len(line) == 0 {
+					if dispatch() {
+						return
+					}
+				} else if value, ok := cutSSEField(line, "data"); ok {
+					data = append(data, value)
+				}
+			}
+
+			if err != nil {
+				if dispatch() {
+					return
+				}
+				if err != io.EOF {
+					_ = sw.Send(nil, err)
+				}
+				return
+			}
+		}
+	}()
+
+	return sr
+}
+
+// cutSSEField returns the value of the line if it is the given field.
+func cutSSEField(line []byte, field string) ([]byte, bool) {
+	if !bytes.HasPrefix(line, []byte(field)) {
+		return nil, false
+	}
+
+	rest := line[len(field):]
+	if len(rest) == 0 {
+		return rest, true
+	}
+	if rest[0] != ':' {
+		return nil, false
+	}
+
+	rest = rest[1:]
+	if len(rest) > 0 && rest[0] == ' ' {
+		rest = rest[1:]
+	}
+
+	return rest, true
+}
@@ -0,0 +1,105 @@
+/*
+ * Copyright 2026 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package schema
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/bytedance/sonic"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSSEMessages(t *testing.T) {
+	decode := func(data []byte) (*Message, error) {
+		m := &Message{}
+		if err := sonic.Unmarshal(data, m); err != nil {
+			return nil, err
+		}
+		return m, nil
+	}
+
+	t.Run("done terminator", func(t *testing.T) {
+		body := ": keep-alive\n\n" +
+			"data: {\"role\":\"assistant\",\"content\":\"Hel\"}\n\n" +
+			"event: delta\r\nid: 2\r\ndata: {\"content\":\"lo\"}\r\n\r\n" +
+			"data: not json\n\n" +
+			"data: {\"content\":\n" +
+			"data: \"!\"}\n\n" +
+			"data: [DONE]\n\n" +
+			"data: {\"content\":\"ignored\"}\n\n"
+
+		sr := ParseSSEMessages(strings.NewReader(body), decode)
+		defer sr.Close()
+
+		var msgs []*Message
+		var decodeErrs int
+		for {
+			msg, err := sr.Recv()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				decodeErrs++
+				continue
+			}
+			msgs = append(msgs, msg)
+		}
+
+		assert.Equal(t, 1, decodeErrs)
+		msg, err := ConcatMessages(msgs)
+		assert.NoError(t, err)
+		assert.Equal(t, Assistant, msg.Role)
+		assert.Equal(t, "Hello!", msg.Content)
+	})
+
+	t.Run("eof without trailing blank line", func(t *testing.T) {
+		sr := ParseSSEMessages(strings.NewReader("data: {\"content\":\"hi\"}"), decode)
+		defer sr.Close()
+
+		msg, err := sr.Recv()
+		assert.NoError(t, err)
+		assert.Equal(t, "hi", msg.Content)
+		_, err = sr.Recv()
+		assert.Equal(t, io.EOF, err)
+	})
+
+	t.Run("read error", func(t *testing.T) {
+		readErr := errors.New("connection reset")
+		r := io.MultiReader(strings.NewReader("data: {\"content\":\"hi\"}\n\n"), &errReader{err: readErr})
+		sr := ParseSSEMessages(r, decode)
+		defer sr.Close()
+
+		msg, err := sr.Recv()
+		assert.NoError(t, err)
+		assert.Equal(t, "hi", msg.Content)
+		_, err = sr.Recv()
+		assert.ErrorIs(t, err, readErr)
+		_, err = sr.Recv()
+		assert.Equal(t, io.EOF, err)
+	})
+}
+
+type errReader struct {
+	err error
+}
+
+func (r *errReader) Read([]byte) (int, error) {
+	return 0, r.err
+}
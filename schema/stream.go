@@ -124,6 +124,32 @@ func (sw *StreamWriter[T]) Send(chunk T, err error) (closed bool) {
 	return sw.stm.send(chunk, err)
 }
 
+// SendAll sends the items to the stream in order, stopping early if the stream is closed by the receiver.
+// e.g.
+//
+//	closed := sw.SendAll(items)
+//	if closed {
+//		// the stream is closed
+//	}
+func (sw *StreamWriter[T]) SendAll(items []T) (closed bool) {
+	for _, item := range items {
+		if sw.stm.send(item, nil) {
+			return true
+		}
+	}
+	return false
+}
+
+// SendAllAndClose sends the items to the stream like SendAll, and then closes the StreamWriter.
+// e.g.
+//
+//	sr, sw := schema.Pipe[string](0)
+//	go sw.SendAllAndClose([]string{"a", "b", "c"})
+func (sw *StreamWriter[T]) SendAllAndClose(items []T) {
+	defer sw.Close()
+	sw.SendAll(items)
+}
+
 // Close notify the receiver that the stream sender has finished.
 // The stream receiver will get an error of io.EOF from StreamReader.Recv().
 // Notice: always remember to call Close() after sending all data.
@@ -291,6 +317,30 @@ func (sr *StreamReader[T]) SetAutomaticClose() {
 	}
 }
 
+// WithCleanup returns a StreamReader reading from sr, which invokes fn exactly once
+// when it's closed or when Recv reaches io.EOF, whichever comes first.
+// The original StreamReader should not be used after WithCleanup.
+// It is useful to release the resource backing the stream, e.g. an http response body.
+// e.g.
+//
+//	sr = sr.WithCleanup(func() {
+//		_ = resp.Body.Close()
+//	})
+//	defer sr.Close()
+func (sr *StreamReader[T]) WithCleanup(fn func()) *StreamReader[T] {
+	ret := newStreamReaderWithConvert(sr, func(a any) (T, error) {
+		t, _ := a.(T) // a is nil when T is an interface type holding nil
+		return t, nil
+	})
+
+	var once sync.Once
+	ret.srw.cleanup = func() {
+		once.Do(fn)
+	}
+
+	return ret
+}
+
 func (sr *StreamReader[T]) recvAny() (any, error) {
 	return sr.Recv()
 }
@@ -574,6 +624,9 @@ type streamReaderWithConvert[T any] struct {
 	convert func(any) (T, error)
 
 	errWrapper func(error) error
+
+	// cleanup is invoked on close and on io.EOF, set by StreamReader.WithCleanup.
+	cleanup func()
 }
 
 func newStreamReaderWithConvert[T any](origin iStreamReader, convert func(any) (T, error), opts ...ConvertOption) *StreamReader[T] {
@@ -636,6 +689,9 @@ func (srw *streamReaderWithConvert[T]) recv() (T, error) {
 		if err != nil {
 			var t T
 			if err == io.EOF {
+				if srw.cleanup != nil {
+					srw.cleanup()
+				}
 				return t, err
 			}
 			if srw.errWrapper != nil {
@@ -661,6 +717,9 @@ func (srw *streamReaderWithConvert[T]) recv() (T, error) {
 
 func (srw *streamReaderWithConvert[T]) close() {
 	srw.sr.Close()
+	if srw.cleanup != nil {
+		srw.cleanup()
+	}
 }
 
 type reader[T any] interface {
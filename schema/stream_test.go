@@ -855,3 +855,76 @@ func TestMergeNamedStreamReaders(t *testing.T) {
 		}
 	})
 }
+
+func TestStreamReaderWithCleanup(t *testing.T) {
+	t.Run("drain then close", func(t *testing.T) {
+		calls := 0
+		sr := StreamReaderFromArray([]int{1, 2}).WithCleanup(func() { calls++ })
+
+		for {
+			_, err := sr.Recv()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			assert.NoError(t, err)
+		}
+		assert.Equal(t, 1, calls)
+
+		_, err := sr.Recv()
+		assert.Equal(t, io.EOF, err)
+		sr.Close()
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("close before eof", func(t *testing.T) {
+		calls := 0
+		origin, sw := Pipe[string](1)
+		sr := origin.WithCleanup(func() { calls++ })
+
+		sw.Send("a", nil)
+		chunk, err := sr.Recv()
+		assert.NoError(t, err)
+		assert.Equal(t, "a", chunk)
+		assert.Equal(t, 0, calls)
+
+		sr.Close()
+		assert.Equal(t, 1, calls)
+		assert.True(t, sw.Send("b", nil))
+	})
+
+	t.Run("nil interface elements", func(t *testing.T) {
+		calls := 0
+		sr := StreamReaderFromArray([]error{nil, io.ErrUnexpectedEOF}).WithCleanup(func() { calls++ })
+		items, err := CollectBestEffort(sr)
+		assert.NoError(t, err)
+		assert.Equal(t, []error{nil, io.ErrUnexpectedEOF}, items)
+		assert.Equal(t, 1, calls)
+	})
+}
+
+func TestStreamWriterSendAll(t *testing.T) {
+	t.Run("send all and close", func(t *testing.T) {
+		sr, sw := Pipe[string](0)
+		go sw.SendAllAndClose([]string{"a", "b", "c"})
+
+		var got []string
+		for {
+			chunk, err := sr.Recv()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			assert.NoError(t, err)
+			got = append(got, chunk)
+		}
+		assert.Equal(t, []string{"a", "b", "c"}, got)
+		sr.Close()
+	})
+
+	t.Run("receiver closed", func(t *testing.T) {
+		sr, sw := Pipe[int](1)
+		sr.Close()
+		assert.True(t, sw.SendAll([]int{1, 2, 3}))
+		assert.False(t, sw.SendAll(nil))
+		sw.Close()
+	})
+}
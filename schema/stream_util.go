@@ -0,0 +1,378 @@
+/*
+ * Copyright 2026 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package schema
+
+import (
+	"io"
+	"runtime/debug"
+
+	"github.com/cloudwego/eino/internal"
+	"github.com/cloudwego/eino/internal/safe"
+)
+
+// CollectBestEffort drains the StreamReader and closes it, collecting every successfully received element.
+// Unlike stopping at the first error, it keeps receiving after an error, so that best-effort pipelines
+// (e.g. some chunks fail to parse while others succeed) can keep what succeeded.
+// All errors encountered are joined into the returned error, which is nil if there is none.
+// The individual errors can be matched against the joined error by errors.Is and errors.As.
+// e.g.
+//
+//	items, err := schema.CollectBestEffort(sr)
+//	if err != nil {
+//		log.Printf("some chunks failed: %v", err)
+//	}
+//	// items contains all chunks received without error
+func CollectBestEffort[T any](sr *StreamReader[T]) ([]T, error) {
+	defer sr.Close()
+
+	var (
+		items []T
+		errs  []error
+	)
+	for {
+		item, err := sr.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		items = append(items, item)
+	}
+
+	return items, internal.JoinErrors(errs...)
+}
+
+// Count drains the StreamReader and closes it, returning the number of elements received.
+// If an error occurs, it stops and returns the number of elements received before the error, along with the error.
+// e.g.
+//
+//	n, err := sr.Count()
+func (sr *StreamReader[T]) Count() (int, error) {
+	defer sr.Close()
+
+	n := 0
+	for {
+		_, err := sr.Recv()
+		if err == io.EOF {
+			return n, nil
+		}
+		if err != nil {
+			return n, err
+		}
+		n++
+	}
+}
+
+// ReduceStream drains the StreamReader and folds its elements into an accumulator starting from init, closing the reader at the end.
+// It stops at the first error returned by Recv or f, returning the accumulator folded so far along with the error.
+// e.g.
+//
+//	total, err := schema.ReduceStream(usageStream, 0, func(acc int, u *schema.TokenUsage) (int, error) {
+//		return acc + u.TotalTokens, nil
+//	})
+func ReduceStream[T, A any](sr *StreamReader[T], init A, f func(A, T) (A, error)) (A, error) {
+	defer sr.Close()
+
+	acc := init
+	for {
+		item, err := sr.Recv()
+		if err == io.EOF {
+			return acc, nil
+		}
+		if err != nil {
+			return acc, err
+		}
+
+		next, err := f(acc, item)
+		if err != nil {
+			return acc, err
+		}
+		acc = next
+	}
+}
+
+// DrainStringStream drains the string StreamReader into w and closes it, e.g. piping the output of a streamable tool
+// to an http.ResponseWriter or a buffer, returning the total bytes written.
+// It stops at the first error returned by Recv or w, returning the bytes written so far along with the error.
+// e.g.
+//
+//	n, err := schema.DrainStringStream(sr, w)
+func DrainStringStream(sr *StreamReader[string], w io.Writer) (int64, error) {
+	defer sr.Close()
+
+	var total int64
+	for {
+		chunk, err := sr.Recv()
+		if err == io.EOF {
+			return total, nil
+		}
+		if err != nil {
+			return total, err
+		}
+
+		n, err := io.WriteString(w, chunk)
+		total += int64(n)
+		if err != nil {
+			return total, err
+		}
+	}
+}
+
+// TapStream returns a StreamReader that calls fn on each element of sr as it passes through, e.g. for logging or tracing,
+// while the elements and errors are passed on unchanged.
+// fn is called lazily in the goroutine calling Recv, so it sees exactly the elements received downstream.
+// e.g.
+//
+//	sr = schema.TapStream(sr, func(chunk *schema.Message) {
+//		log.Printf("chunk: %s", chunk.Content)
+//	})
+func TapStream[T any](sr *StreamReader[T], fn func(T)) *StreamReader[T] {
+	return TapStreamErr(sr, fn, nil)
+}
+
+// TapStreamErr is like TapStream, and additionally calls onErr on each error received from sr other than io.EOF,
+// which usually terminates the stream. Either of fn and onErr can be nil.
+func TapStreamErr[T any](sr *StreamReader[T], fn func(T), onErr func(error)) *StreamReader[T] {
+	return StreamReaderWithConvert(sr, func(t T) (T, error) {
+		if fn != nil {
+			fn(t)
+		}
+		return t, nil
+	}, WithErrWrapper(func(err error) error {
+		if onErr != nil {
+			onErr(err)
+		}
+		return err
+	}))
+}
+
+// DedupConsecutiveStream returns a StreamReader that drops each element of sr equal to the one immediately before it,
+// e.g. the deltas resent by a flaky provider, before concatenating the stream. Equal elements which aren't adjacent are kept.
+// An error in between resets the comparison, so the element after it is always kept.
+// See DedupConsecutiveStreamFunc for the types not comparable by ==.
+func DedupConsecutiveStream[T comparable](sr *StreamReader[T]) *StreamReader[T] {
+	return DedupConsecutiveStreamFunc(sr, func(a, b T) bool {
+		return a == b
+	})
+}
+
+// DedupConsecutiveStreamFunc is like DedupConsecutiveStream, using equal to compare the adjacent elements,
+// e.g. comparing the Content of *Message chunks.
+func DedupConsecutiveStreamFunc[T any](sr *StreamReader[T], equal func(a, b T) bool) *StreamReader[T] {
+	var (
+		prev    T
+		hasPrev bool
+	)
+
+	return StreamReaderWithConvert(sr, func(t T) (T, error) {
+		if hasPrev && equal(prev, t) {
+			return t, ErrNoValue
+		}
+		prev, hasPrev = t, true
+		return t, nil
+	}, WithErrWrapper(func(err error) error {
+		hasPrev = false
+		return err
+	}))
+}
+
+// SafeUTF8Stream returns a StreamReader that only emits complete UTF-8 characters, e.g. for rendering streamed content in a UI,
+// where a multi-byte character split across chunks by the provider would otherwise show up as mojibake.
+// The incomplete trailing bytes of a chunk are held back and prepended to the next chunk, and chunks left empty are skipped.
+// The bytes still held back when sr ends or fails are emitted as is before the end or the error, so that no content is lost.
+// Closing the returned reader closes sr.
+func SafeUTF8Stream(sr *StreamReader[string]) *StreamReader[string] {
+	out, sw := Pipe[string](0)
+
+	go func() {
+		defer func() {
+			panicErr := recover()
+			if panicErr != nil {
+				_ = sw.Send("", safe.NewPanicErr(panicErr, debug.Stack()))
+			}
+
+			sr.Close()
+			sw.Close()
+		}()
+
+		var carry string
+		for {
+			chunk, err := sr.Recv()
+			if err != nil {
+				if carry != "" && sw.Send(carry, nil) {
+					return
+				}
+				if err != io.EOF {
+					_ = sw.Send("", err)
+				}
+				return
+			}
+
+			chunk = carry + chunk
+			n := len(chunk) - internal.IncompleteUTF8SuffixLen(chunk)
+			chunk, carry = chunk[:n], chunk[n:]
+			if chunk != "" && sw.Send(chunk, nil) {
+				return
+			}
+		}
+	}()
+
+	return out
+}
+
+// RetryStream returns a StreamReader that reads from the stream created by factory,
+// and re-invokes factory to continue reading from a new stream when the current one fails with an error
+// for which retryable returns true, until factory has been invoked maxAttempts times in total.
+// An error returned by factory itself is retried in the same way.
+// Any error not retried is surfaced by Recv and ends the stream.
+// Elements received before the failure have already been emitted, so if the new stream starts over from the beginning,
+// de-duplicating the emitted elements is the caller's concern.
+// e.g.
+//
+//	sr := schema.RetryStream(func() (*schema.StreamReader[string], error) {
+//		return client.Stream(ctx, req)
+//	}, isTransient, 3)
+//	defer sr.Close()
+func RetryStream[T any](factory func() (*StreamReader[T], error), retryable func(error) bool, maxAttempts int) *StreamReader[T] {
+	sr, sw := Pipe[T](0)
+
+	go func() {
+		var (
+			src  *StreamReader[T]
+			zero T
+		)
+		defer func() {
+			panicErr := recover()
+			if panicErr != nil {
+				_ = sw.Send(zero, safe.NewPanicErr(panicErr, debug.Stack()))
+			}
+
+			if src != nil {
+				src.Close()
+			}
+			sw.Close()
+		}()
+
+		for attempt := 1; ; attempt++ {
+			var err error
+			src, err = factory()
+			if err == nil {
+				for {
+					var item T
+					item, err = src.Recv()
+					if err == io.EOF {
+						return
+					}
+					if err != nil {
+						break
+					}
+					if sw.Send(item, nil) {
+						return
+					}
+				}
+
+				src.Close()
+				src = nil
+			}
+
+			if attempt >= maxAttempts || !retryable(err) {
+				_ = sw.Send(zero, err)
+				return
+			}
+		}
+	}()
+
+	return sr
+}
+
+// MergeStreamReadersOrdered merges readers into one StreamReader whose elements are ordered by less.
+// Each reader must be individually sorted by less, and the merged output is then globally sorted (a k-way merge).
+// Elements that compare equal are emitted in the order of readers.
+// Unlike MergeStreamReaders, it has to wait for the next element of every unfinished reader before emitting one,
+// so a slow reader holds back the merged output.
+// The first error received from any reader is surfaced by Recv and ends the stream.
+// e.g.
+//
+//	sr := schema.MergeStreamReadersOrdered(func(a, b *Event) bool {
+//		return a.Seq < b.Seq
+//	}, sr1, sr2, sr3)
+//	defer sr.Close()
+func MergeStreamReadersOrdered[T any](less func(a, b T) bool, readers ...*StreamReader[T]) *StreamReader[T] {
+	sr, sw := Pipe[T](0)
+
+	go func() {
+		var zero T
+		defer func() {
+			panicErr := recover()
+			if panicErr != nil {
+				_ = sw.Send(zero, safe.NewPanicErr(panicErr, debug.Stack()))
+			}
+
+			for _, r := range readers {
+				r.Close()
+			}
+			sw.Close()
+		}()
+
+		heads := make([]T, len(readers))
+		alive := make([]bool, len(readers))
+		next := func(i int) error {
+			item, err := readers[i].Recv()
+			if err == io.EOF {
+				alive[i] = false
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			heads[i], alive[i] = item, true
+			return nil
+		}
+
+		for i := range readers {
+			if err := next(i); err != nil {
+				_ = sw.Send(zero, err)
+				return
+			}
+		}
+
+		for {
+			minIdx := -1
+			for i := range readers {
+				if alive[i] && (minIdx < 0 || less(heads[i], heads[minIdx])) {
+					minIdx = i
+				}
+			}
+			if minIdx < 0 {
+				return
+			}
+
+			if sw.Send(heads[minIdx], nil) {
+				return
+			}
+			if err := next(minIdx); err != nil {
+				_ = sw.Send(zero, err)
+				return
+			}
+		}
+	}()
+
+	return sr
+}
@@ -0,0 +1,428 @@
+/*
+ * Copyright 2026 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package schema
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCollectBestEffort(t *testing.T) {
+	t.Run("alternating errors", func(t *testing.T) {
+		sr, sw := Pipe[int](10)
+		errs := make([]error, 0, 3)
+		go func() {
+			defer sw.Close()
+			for i := 0; i < 6; i++ {
+				if i%2 == 1 {
+					err := fmt.Errorf("chunk %d failed", i)
+					errs = append(errs, err)
+					sw.Send(0, err)
+					continue
+				}
+				sw.Send(i, nil)
+			}
+		}()
+
+		items, err := CollectBestEffort(sr)
+		assert.Equal(t, []int{0, 2, 4}, items)
+		assert.Error(t, err)
+		for _, e := range errs {
+			assert.True(t, errors.Is(err, e))
+		}
+		assert.Equal(t, "chunk 1 failed\nchunk 3 failed\nchunk 5 failed", err.Error())
+	})
+
+	t.Run("no error", func(t *testing.T) {
+		items, err := CollectBestEffort(StreamReaderFromArray([]string{"a", "b"}))
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"a", "b"}, items)
+	})
+}
+
+func TestStreamReaderCount(t *testing.T) {
+	n, err := StreamReaderFromArray([]int{1, 2, 3}).Count()
+	assert.NoError(t, err)
+	assert.Equal(t, 3, n)
+
+	sr, sw := Pipe[int](5)
+	streamErr := errors.New("stream error")
+	go func() {
+		defer sw.Close()
+		sw.Send(1, nil)
+		sw.Send(2, nil)
+		sw.Send(0, streamErr)
+		sw.Send(3, nil)
+	}()
+	n, err = sr.Count()
+	assert.ErrorIs(t, err, streamErr)
+	assert.Equal(t, 2, n)
+}
+
+func TestRetryStream(t *testing.T) {
+	errTransient := errors.New("connection reset")
+	isTransient := func(err error) bool { return errors.Is(err, errTransient) }
+
+	t.Run("retry succeeds", func(t *testing.T) {
+		calls := 0
+		sr := RetryStream(func() (*StreamReader[string], error) {
+			calls++
+			s, w := Pipe[string](3)
+			if calls == 1 {
+				w.Send("a", nil)
+				w.Send("", errTransient)
+			} else {
+				w.Send("b", nil)
+				w.Send("c", nil)
+			}
+			w.Close()
+			return s, nil
+		}, isTransient, 3)
+
+		items, err := CollectBestEffort(sr)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"a", "b", "c"}, items)
+		assert.Equal(t, 2, calls)
+	})
+
+	t.Run("factory error retried", func(t *testing.T) {
+		calls := 0
+		sr := RetryStream(func() (*StreamReader[int], error) {
+			calls++
+			if calls < 3 {
+				return nil, errTransient
+			}
+			return StreamReaderFromArray([]int{1, 2}), nil
+		}, isTransient, 3)
+
+		items, err := CollectBestEffort(sr)
+		assert.NoError(t, err)
+		assert.Equal(t, []int{1, 2}, items)
+	})
+
+	t.Run("attempts exhausted", func(t *testing.T) {
+		calls := 0
+		sr := RetryStream(func() (*StreamReader[int], error) {
+			calls++
+			return nil, errTransient
+		}, isTransient, 2)
+
+		items, err := CollectBestEffort(sr)
+		assert.ErrorIs(t, err, errTransient)
+		assert.Empty(t, items)
+		assert.Equal(t, 2, calls)
+	})
+
+	t.Run("not retryable", func(t *testing.T) {
+		errFatal := errors.New("bad request")
+		calls := 0
+		sr := RetryStream(func() (*StreamReader[int], error) {
+			calls++
+			s, w := Pipe[int](2)
+			w.Send(1, nil)
+			w.Send(0, errFatal)
+			w.Close()
+			return s, nil
+		}, isTransient, 3)
+
+		items, err := CollectBestEffort(sr)
+		assert.ErrorIs(t, err, errFatal)
+		assert.Equal(t, []int{1}, items)
+		assert.Equal(t, 1, calls)
+	})
+}
+
+func TestReduceStream(t *testing.T) {
+	sum := func(acc, n int) (int, error) { return acc + n, nil }
+
+	total, err := ReduceStream(StreamReaderFromArray([]int{1, 2, 3, 4}), 10, sum)
+	assert.NoError(t, err)
+	assert.Equal(t, 20, total)
+
+	total, err = ReduceStream(StreamReaderFromArray([]int{}), 0, sum)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, total)
+
+	s, err := ReduceStream(StreamReaderFromArray([]string{"a", "b"}), "", func(acc, s string) (string, error) {
+		return acc + s, nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "ab", s)
+
+	t.Run("recv error", func(t *testing.T) {
+		errRecv := errors.New("recv failed")
+		sr, sw := Pipe[int](3)
+		sw.Send(1, nil)
+		sw.Send(0, errRecv)
+		sw.Send(2, nil)
+		sw.Close()
+
+		total, err := ReduceStream(sr, 0, sum)
+		assert.ErrorIs(t, err, errRecv)
+		assert.Equal(t, 1, total)
+	})
+
+	t.Run("reducer error", func(t *testing.T) {
+		errNegative := errors.New("negative")
+		total, err := ReduceStream(StreamReaderFromArray([]int{1, -1, 2}), 0, func(acc, n int) (int, error) {
+			if n < 0 {
+				return 0, errNegative
+			}
+			return acc + n, nil
+		})
+		assert.ErrorIs(t, err, errNegative)
+		assert.Equal(t, 1, total)
+	})
+}
+
+type failingWriter struct {
+	limit int
+}
+
+func (w *failingWriter) Write(p []byte) (int, error) {
+	if len(p) > w.limit {
+		return w.limit, errors.New("short write")
+	}
+	w.limit -= len(p)
+	return len(p), nil
+}
+
+func TestDrainStringStream(t *testing.T) {
+	var buf bytes.Buffer
+	n, err := DrainStringStream(StreamReaderFromArray([]string{"hello", ", ", "世界"}), &buf)
+	assert.NoError(t, err)
+	assert.Equal(t, "hello, 世界", buf.String())
+	assert.Equal(t, int64(len("hello, 世界")), n)
+
+	t.Run("recv error", func(t *testing.T) {
+		errRecv := errors.New("recv failed")
+		sr, sw := Pipe[string](2)
+		sw.Send("ab", nil)
+		sw.Send("", errRecv)
+		sw.Close()
+
+		var buf bytes.Buffer
+		n, err := DrainStringStream(sr, &buf)
+		assert.ErrorIs(t, err, errRecv)
+		assert.Equal(t, int64(2), n)
+		assert.Equal(t, "ab", buf.String())
+	})
+
+	t.Run("write error", func(t *testing.T) {
+		n, err := DrainStringStream(StreamReaderFromArray([]string{"abc", "def"}), &failingWriter{limit: 4})
+		assert.ErrorContains(t, err, "short write")
+		assert.Equal(t, int64(4), n)
+	})
+}
+
+func TestTapStream(t *testing.T) {
+	var seen []int
+	sr := TapStream(StreamReaderFromArray([]int{1, 2, 3}), func(i int) {
+		seen = append(seen, i)
+	})
+
+	var got []int
+	for {
+		i, err := sr.Recv()
+		if err == io.EOF {
+			break
+		}
+		assert.NoError(t, err)
+		got = append(got, i)
+	}
+	assert.Equal(t, []int{1, 2, 3}, got)
+	assert.Equal(t, []int{1, 2, 3}, seen)
+
+	t.Run("with error", func(t *testing.T) {
+		errRecv := errors.New("recv failed")
+		in, sw := Pipe[int](3)
+		sw.Send(1, nil)
+		sw.Send(0, errRecv)
+		sw.Close()
+
+		var (
+			seen []int
+			errs []error
+		)
+		sr := TapStreamErr(in, func(i int) {
+			seen = append(seen, i)
+		}, func(err error) {
+			errs = append(errs, err)
+		})
+		defer sr.Close()
+
+		i, err := sr.Recv()
+		assert.NoError(t, err)
+		assert.Equal(t, 1, i)
+		_, err = sr.Recv()
+		assert.ErrorIs(t, err, errRecv)
+		_, err = sr.Recv()
+		assert.Equal(t, io.EOF, err)
+
+		assert.Equal(t, []int{1}, seen)
+		assert.Equal(t, []error{errRecv}, errs)
+	})
+}
+
+func TestDedupConsecutiveStream(t *testing.T) {
+	got, err := CollectBestEffort(DedupConsecutiveStream(StreamReaderFromArray([]string{"a", "b", "b", "b", "c", "b", "c", "c"})))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "b", "c", "b", "c"}, got)
+
+	t.Run("func", func(t *testing.T) {
+		msgs := []*Message{
+			AssistantMessage("hel", nil),
+			AssistantMessage("lo", nil),
+			AssistantMessage("lo", nil),
+			AssistantMessage("!", nil),
+		}
+		sr := DedupConsecutiveStreamFunc(StreamReaderFromArray(msgs), func(a, b *Message) bool {
+			return a.Content == b.Content
+		})
+		got, err := CollectBestEffort(sr)
+		assert.NoError(t, err)
+		msg, err := ConcatMessages(got)
+		assert.NoError(t, err)
+		assert.Equal(t, "hello!", msg.Content)
+	})
+
+	t.Run("error resets", func(t *testing.T) {
+		errRecv := errors.New("recv failed")
+		in, sw := Pipe[int](4)
+		sw.Send(1, nil)
+		sw.Send(0, errRecv)
+		sw.Send(1, nil)
+		sw.Send(1, nil)
+		sw.Close()
+
+		got, err := CollectBestEffort(DedupConsecutiveStream(in))
+		assert.ErrorIs(t, err, errRecv)
+		assert.Equal(t, []int{1, 1}, got)
+	})
+}
+
+func TestSafeUTF8Stream(t *testing.T) {
+	rocket := "🚀" // 4 bytes
+	heart := "❤️" // 6 bytes in two runes
+
+	collect := func(sr *StreamReader[string]) ([]string, error) {
+		defer sr.Close()
+		var chunks []string
+		for {
+			chunk, err := sr.Recv()
+			if err == io.EOF {
+				return chunks, nil
+			}
+			if err != nil {
+				return chunks, err
+			}
+			chunks = append(chunks, chunk)
+		}
+	}
+
+	chunks, err := collect(SafeUTF8Stream(StreamReaderFromArray([]string{
+		"go " + rocket[:3], rocket[3:] + " and " + heart[:1], heart[1:2], heart[2:],
+	})))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"go ", rocket + " and ", heart}, chunks)
+	for _, chunk := range chunks {
+		assert.True(t, utf8.ValidString(chunk))
+	}
+
+	t.Run("incomplete tail", func(t *testing.T) {
+		chunks, err := collect(SafeUTF8Stream(StreamReaderFromArray([]string{"a" + rocket[:2]})))
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"a", rocket[:2]}, chunks)
+	})
+
+	t.Run("error", func(t *testing.T) {
+		errRecv := errors.New("recv failed")
+		sr, sw := Pipe[string](2)
+		sw.Send(rocket[:1], nil)
+		sw.Send("", errRecv)
+		sw.Close()
+
+		chunks, err := collect(SafeUTF8Stream(sr))
+		assert.ErrorIs(t, err, errRecv)
+		assert.Equal(t, []string{rocket[:1]}, chunks)
+	})
+}
+
+func TestMergeStreamReadersOrdered(t *testing.T) {
+	less := func(a, b int) bool { return a < b }
+
+	t.Run("k-way merge", func(t *testing.T) {
+		sr2, sw2 := Pipe[int](0)
+		go func() {
+			defer sw2.Close()
+			for _, v := range []int{2, 4, 4, 9} {
+				sw2.Send(v, nil)
+			}
+		}()
+
+		sr := MergeStreamReadersOrdered(less,
+			StreamReaderFromArray([]int{1, 4, 7, 10}),
+			sr2,
+			StreamReaderFromArray([]int{0, 3, 8}),
+		)
+		defer sr.Close()
+
+		var got []int
+		for {
+			v, err := sr.Recv()
+			if err == io.EOF {
+				break
+			}
+			assert.NoError(t, err)
+			got = append(got, v)
+		}
+		assert.Equal(t, []int{0, 1, 2, 3, 4, 4, 4, 7, 8, 9, 10}, got)
+	})
+
+	t.Run("error ends the stream", func(t *testing.T) {
+		srErr, swErr := Pipe[int](2)
+		swErr.Send(5, nil)
+		swErr.Send(0, errors.New("boom"))
+		swErr.Close()
+
+		sr := MergeStreamReadersOrdered(less, StreamReaderFromArray([]int{1, 6}), srErr)
+		defer sr.Close()
+
+		v, err := sr.Recv()
+		assert.NoError(t, err)
+		assert.Equal(t, 1, v)
+		v, err = sr.Recv()
+		assert.NoError(t, err)
+		assert.Equal(t, 5, v)
+		_, err = sr.Recv()
+		assert.EqualError(t, err, "boom")
+		_, err = sr.Recv()
+		assert.Equal(t, io.EOF, err)
+	})
+
+	t.Run("no readers", func(t *testing.T) {
+		sr := MergeStreamReadersOrdered(less)
+		_, err := sr.Recv()
+		assert.Equal(t, io.EOF, err)
+	})
+}
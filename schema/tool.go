@@ -17,6 +17,12 @@
 package schema
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"sort"
 
 	"github.com/eino-contrib/jsonschema"
@@ -73,6 +79,46 @@ type ToolInfo struct {
 	*ParamsOneOf
 }
 
+// Hash returns the SHA-256 hex digest of the canonical form of the ToolInfo, which is stable across runs,
+// so that it can be used as the key for caching and invalidation based on tool definitions.
+// The canonical form is the JSON of the name, the description, the extra information and the parameters converted by ToJSONSchema,
+// with all object keys sorted, so that the order of the properties or the map iteration order doesn't affect the hash.
+func (t *ToolInfo) Hash() (string, error) {
+	if t == nil {
+		return "", errors.New("tool info is nil")
+	}
+
+	params, err := t.ParamsOneOf.ToJSONSchema()
+	if err != nil {
+		return "", fmt.Errorf("convert tool params to json schema failed: %w", err)
+	}
+
+	data, err := json.Marshal(struct {
+		Name   string             `json:"name"`
+		Desc   string             `json:"desc"`
+		Extra  map[string]any     `json:"extra,omitempty"`
+		Params *jsonschema.Schema `json:"params,omitempty"`
+	}{Name: t.Name, Desc: t.Desc, Extra: t.Extra, Params: params})
+	if err != nil {
+		return "", fmt.Errorf("marshal tool info failed: %w", err)
+	}
+
+	// round-trip through generic values, whose map keys are sorted by encoding/json
+	var generic any
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err = dec.Decode(&generic); err != nil {
+		return "", fmt.Errorf("canonicalize tool info failed: %w", err)
+	}
+	data, err = json.Marshal(generic)
+	if err != nil {
+		return "", fmt.Errorf("canonicalize tool info failed: %w", err)
+	}
+
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
 // ParameterInfo is the information of a parameter.
 // It is used to describe the parameters of a tool.
 type ParameterInfo struct {
@@ -88,8 +134,27 @@ type ParameterInfo struct {
 	Enum []string
 	// Whether the parameter is required.
 	Required bool
+	// The minimum number of items of the parameter, only for array.
+	MinItems *uint64
+	// The maximum number of items of the parameter, only for array.
+	MaxItems *uint64
+	// Whether the items of the parameter must be unique, only for array.
+	UniqueItems bool
+	// The format of the parameter, only for string, e.g. ParamFormatDateTime, emitted as the 'format' keyword of JSON Schema.
+	// It is a hint for the model, and any value is accepted besides the predefined ones.
+	Format string
 }
 
+// The common formats of string parameters defined by JSON Schema, see ParameterInfo.Format.
+const (
+	ParamFormatDateTime = "date-time"
+	ParamFormatDate     = "date"
+	ParamFormatTime     = "time"
+	ParamFormatEmail    = "email"
+	ParamFormatURI      = "uri"
+	ParamFormatUUID     = "uuid"
+)
+
 // ParamsOneOf is a union of the different methods user can choose which describe a tool's request parameters.
 // User must specify one and ONLY one method to describe the parameters.
 //  1. use NewParamsOneOfByParams(): an intuitive way to describe the parameters that covers most of the use-cases.
@@ -153,6 +218,7 @@ func paramInfoToJSONSchema(paramInfo *ParameterInfo) *jsonschema.Schema {
 	js := &jsonschema.Schema{
 		Type:        string(paramInfo.Type),
 		Description: paramInfo.Desc,
+		Format:      paramInfo.Format,
 	}
 
 	if len(paramInfo.Enum) > 0 {
@@ -166,6 +232,10 @@ func paramInfoToJSONSchema(paramInfo *ParameterInfo) *jsonschema.Schema {
 		js.Items = paramInfoToJSONSchema(paramInfo.ElemInfo)
 	}
 
+	js.MinItems = paramInfo.MinItems
+	js.MaxItems = paramInfo.MaxItems
+	js.UniqueItems = paramInfo.UniqueItems
+
 	if len(paramInfo.SubParams) > 0 {
 		required := make([]string, 0, len(paramInfo.SubParams))
 		js.Properties = orderedmap.New[string, *jsonschema.Schema]()
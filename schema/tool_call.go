@@ -0,0 +1,348 @@
+/*
+ * Copyright 2026 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package schema
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"runtime/debug"
+	"sort"
+	"strings"
+
+	"github.com/bytedance/sonic"
+	"github.com/google/uuid"
+
+	"github.com/cloudwego/eino/internal/safe"
+)
+
+const toolCallIDPrefix = "call_"
+
+// NewToolCallID generates a random, collision-resistant ID for tool calls that are not generated by a model.
+// e.g.
+//
+//	tc := schema.ToolCall{
+//		ID:       schema.NewToolCallID(), // e.g. call_5f1c0e6b0c3f4b7e9a0f8d6c2b1a4e3d
+//		Function: schema.FunctionCall{Name: "get_weather", Arguments: `{"city":"Paris"}`},
+//	}
+func NewToolCallID() string {
+	id := uuid.New()
+	return toolCallIDPrefix + hex.EncodeToString(id[:])
+}
+
+// NewToolCallIDFromSeed generates a tool call ID deterministically from the seed,
+// so that the same seed always produces the same ID, which is useful for reproducible test fixtures.
+// The ID has the same format as the one generated by NewToolCallID.
+func NewToolCallIDFromSeed(seed string) string {
+	sum := sha256.Sum256([]byte(seed))
+	return toolCallIDPrefix + hex.EncodeToString(sum[:16])
+}
+
+// Validate checks the structural correctness of the tool call before it is executed,
+// e.g. to catch malformed results of concatenating streamed chunks.
+// It requires Function.Name to be non-empty, Type to be "function" or empty (which defaults to "function"),
+// and Function.Arguments to be valid JSON. Empty Arguments is allowed for tools taking no argument.
+func (tc *ToolCall) Validate() error {
+	if tc == nil {
+		return fmt.Errorf("tool call is nil")
+	}
+	if tc.Function.Name == "" {
+		return fmt.Errorf("tool call[%s] has empty function name", tc.ID)
+	}
+	if tc.Type != "" && tc.Type != "function" {
+		return fmt.Errorf("tool call[%s] has unsupported type '%s', expected 'function'", tc.ID, tc.Type)
+	}
+	if tc.Function.Arguments != "" && !sonic.ValidString(tc.Function.Arguments) {
+		return fmt.Errorf("tool call[%s] of function '%s' has invalid JSON arguments: %s",
+			tc.ID, tc.Function.Name, tc.Function.Arguments)
+	}
+
+	return nil
+}
+
+// IsCompleteJSON reports whether s is a complete JSON object or array, e.g. the accumulated arguments of a streamed tool call.
+// Braces and brackets inside strings, including escaped quotes, are handled.
+// Partial input such as `{"city": "Par` returns false.
+func IsCompleteJSON(s string) bool {
+	trimmed := strings.TrimSpace(s)
+	if len(trimmed) == 0 || (trimmed[0] != '{' && trimmed[0] != '[') {
+		return false
+	}
+
+	depth := 0
+	inString := false
+	escaped := false
+	for i := 0; i < len(trimmed); i++ {
+		c := trimmed[i]
+		if inString {
+			switch {
+			case escaped:
+				escaped = false
+			case c == '\\':
+				escaped = true
+			case c == '"':
+				inString = false
+			}
+			continue
+		}
+
+		switch c {
+		case '"':
+			inString = true
+		case '{', '[':
+			depth++
+		case '}', ']':
+			depth--
+			if depth < 0 {
+				return false
+			}
+			if depth == 0 && i != len(trimmed)-1 {
+				return false
+			}
+		}
+	}
+
+	return depth == 0 && sonic.ValidString(trimmed)
+}
+
+// ToolCallAccumulator accumulates the tool call chunks of a streamed message,
+// and reports each tool call as soon as its arguments become complete JSON,
+// so that the tool call can be dispatched before the stream ends.
+// Chunks are grouped by ToolCall.Index, in the same way as ConcatMessages does.
+// e.g.
+//
+//	acc := schema.NewToolCallAccumulator()
+//	for {
+//		chunk, err := sr.Recv()
+//		if errors.Is(err, io.EOF) {
+//			break
+//		}
+//		completed, err := acc.Add(chunk.ToolCalls...)
+//		if err != nil {...}
+//		for _, tc := range completed {
+//			go execute(tc)
+//		}
+//	}
+//	rest, err := acc.Finish() // e.g. tool calls without arguments
+//	if err != nil {...}
+//	for _, tc := range rest {
+//		go execute(tc)
+//	}
+type ToolCallAccumulator struct {
+	chunks    map[int][]ToolCall
+	args      map[int]*strings.Builder
+	completed map[int]bool
+}
+
+// NewToolCallAccumulator creates a ToolCallAccumulator.
+func NewToolCallAccumulator() *ToolCallAccumulator {
+	return &ToolCallAccumulator{
+		chunks:    make(map[int][]ToolCall),
+		args:      make(map[int]*strings.Builder),
+		completed: make(map[int]bool),
+	}
+}
+
+// Add adds the tool call chunks, and returns the merged tool calls whose arguments become complete JSON after this addition,
+// sorted by index. Each tool call is returned only once, and chunks arriving after its completion are ignored.
+// Chunks without index are considered to be whole tool calls, and are returned if their arguments are complete JSON or empty.
+// Tool calls whose arguments never become complete JSON, e.g. those without arguments, are returned by Finish.
+func (a *ToolCallAccumulator) Add(chunks ...ToolCall) ([]ToolCall, error) {
+	var completed []ToolCall
+	var indexes []int
+	for _, chunk := range chunks {
+		if chunk.Index == nil {
+			if chunk.Function.Arguments == "" || IsCompleteJSON(chunk.Function.Arguments) {
+				completed = append(completed, chunk)
+			}
+			continue
+		}
+
+		index := *chunk.Index
+		if a.completed[index] {
+			continue
+		}
+
+		a.chunks[index] = append(a.chunks[index], chunk)
+		sb, ok := a.args[index]
+		if !ok {
+			sb = &strings.Builder{}
+			a.args[index] = sb
+		}
+		sb.WriteString(chunk.Function.Arguments)
+
+		if IsCompleteJSON(sb.String()) {
+			a.completed[index] = true
+			indexes = append(indexes, index)
+		}
+	}
+
+	merged, err := a.merge(indexes)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(completed, merged...), nil
+}
+
+// Finish is called when the stream ends, and returns the merged tool calls which have a name but haven't been returned by Add,
+// as their arguments never become complete JSON, e.g. those without arguments, sorted by index.
+// Tool calls without a name are dropped, as they can't be dispatched.
+func (a *ToolCallAccumulator) Finish() ([]ToolCall, error) {
+	var indexes []int
+	for index, chunks := range a.chunks {
+		for _, chunk := range chunks {
+			if chunk.Function.Name != "" {
+				indexes = append(indexes, index)
+				break
+			}
+		}
+	}
+
+	completed, err := a.merge(indexes)
+	if err != nil {
+		return nil, err
+	}
+	for index := range a.chunks {
+		a.completed[index] = true
+	}
+	a.chunks = make(map[int][]ToolCall)
+	a.args = make(map[int]*strings.Builder)
+
+	return completed, nil
+}
+
+// merge merges the chunks of the tool calls at indexes, marking them completed.
+func (a *ToolCallAccumulator) merge(indexes []int) ([]ToolCall, error) {
+	var merged []ToolCall
+	sort.Ints(indexes)
+	for _, index := range indexes {
+		tcs, err := concatToolCalls(a.chunks[index])
+		if err != nil {
+			return nil, err
+		}
+		merged = append(merged, tcs...)
+		a.completed[index] = true
+		delete(a.chunks, index)
+		delete(a.args, index)
+	}
+
+	return merged, nil
+}
+
+// WatchToolCalls returns a StreamReader passing through the chunks of sr, while firing onStart when a tool call begins,
+// i.e. the first non-empty Function.Name appears at its index, and onComplete when its accumulated arguments become complete JSON,
+// which enables live tool call UIs. Tool call chunks are grouped by ToolCall.Index, in the same way as ConcatMessages does.
+// Tool calls whose arguments never become complete JSON, e.g. those without arguments, are completed when the stream ends
+// with io.EOF, in the order of index. A tool call without index is considered whole, and is reported with its position
+// in the ToolCalls of the chunk. Either callback can be nil.
+// e.g.
+//
+//	sr = schema.WatchToolCalls(sr, func(index int, name string) {
+//		ui.ShowToolCall(index, name)
+//	}, func(index int, args string) {
+//		ui.ShowToolCallArgs(index, args)
+//	})
+func WatchToolCalls(sr *StreamReader[*Message], onStart func(index int, name string),
+	onComplete func(index int, args string)) *StreamReader[*Message] {
+	if onStart == nil {
+		onStart = func(int, string) {}
+	}
+	if onComplete == nil {
+		onComplete = func(int, string) {}
+	}
+
+	out, sw := Pipe[*Message](0)
+
+	go func() {
+		defer func() {
+			panicErr := recover()
+			if panicErr != nil {
+				_ = sw.Send(nil, safe.NewPanicErr(panicErr, debug.Stack()))
+			}
+
+			sr.Close()
+			sw.Close()
+		}()
+
+		started := make(map[int]bool)
+		completed := make(map[int]bool)
+		args := make(map[int]*strings.Builder)
+		for {
+			chunk, err := sr.Recv()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				_ = sw.Send(nil, err)
+				return
+			}
+
+			if chunk != nil {
+				for i, tc := range chunk.ToolCalls {
+					if tc.Index == nil {
+						onStart(i, tc.Function.Name)
+						onComplete(i, tc.Function.Arguments)
+						continue
+					}
+
+					index := *tc.Index
+					if !started[index] && tc.Function.Name != "" {
+						started[index] = true
+						onStart(index, tc.Function.Name)
+					}
+					if completed[index] {
+						continue
+					}
+
+					sb, ok := args[index]
+					if !ok {
+						sb = &strings.Builder{}
+						args[index] = sb
+					}
+					sb.WriteString(tc.Function.Arguments)
+					if started[index] && IsCompleteJSON(sb.String()) {
+						completed[index] = true
+						onComplete(index, sb.String())
+					}
+				}
+			}
+
+			if sw.Send(chunk, nil) {
+				return
+			}
+		}
+
+		indexes := make([]int, 0, len(started))
+		for index := range started {
+			if !completed[index] {
+				indexes = append(indexes, index)
+			}
+		}
+		sort.Ints(indexes)
+		for _, index := range indexes {
+			var a string
+			if sb, ok := args[index]; ok {
+				a = sb.String()
+			}
+			onComplete(index, a)
+		}
+	}()
+
+	return out
+}
@@ -0,0 +1,237 @@
+/*
+ * Copyright 2026 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package schema
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewToolCallID(t *testing.T) {
+	ids := make(map[string]bool)
+	for i := 0; i < 1000; i++ {
+		id := NewToolCallID()
+		assert.True(t, strings.HasPrefix(id, "call_"))
+		assert.Len(t, id, 37)
+		assert.False(t, ids[id])
+		ids[id] = true
+	}
+
+	id := NewToolCallIDFromSeed("fixture")
+	assert.Equal(t, id, NewToolCallIDFromSeed("fixture"))
+	assert.NotEqual(t, id, NewToolCallIDFromSeed("fixture2"))
+	assert.True(t, strings.HasPrefix(id, "call_"))
+	assert.Len(t, id, 37)
+}
+
+func TestToolCallValidate(t *testing.T) {
+	t.Run("valid", func(t *testing.T) {
+		tc := &ToolCall{ID: "call_1", Type: "function", Function: FunctionCall{Name: "get_weather", Arguments: `{"city":"Paris"}`}}
+		assert.NoError(t, tc.Validate())
+	})
+
+	t.Run("empty args and type", func(t *testing.T) {
+		tc := &ToolCall{ID: "call_1", Function: FunctionCall{Name: "get_time"}}
+		assert.NoError(t, tc.Validate())
+	})
+
+	t.Run("invalid json", func(t *testing.T) {
+		tc := &ToolCall{ID: "call_1", Type: "function", Function: FunctionCall{Name: "get_weather", Arguments: `{"city":"Par`}}
+		assert.ErrorContains(t, tc.Validate(), "invalid JSON arguments")
+	})
+
+	t.Run("empty name", func(t *testing.T) {
+		tc := &ToolCall{ID: "call_1", Function: FunctionCall{Arguments: `{}`}}
+		assert.ErrorContains(t, tc.Validate(), "empty function name")
+	})
+
+	t.Run("unsupported type", func(t *testing.T) {
+		tc := &ToolCall{ID: "call_1", Type: "custom", Function: FunctionCall{Name: "get_weather"}}
+		assert.ErrorContains(t, tc.Validate(), "unsupported type")
+	})
+
+	t.Run("nil", func(t *testing.T) {
+		var tc *ToolCall
+		assert.Error(t, tc.Validate())
+	})
+}
+
+func TestIsCompleteJSON(t *testing.T) {
+	cases := []struct {
+		input    string
+		complete bool
+	}{
+		{``, false},
+		{`{`, false},
+		{`{"city":`, false},
+		{`{"city": "Par`, false},
+		{`{"city": "Paris"`, false},
+		{`{"city": "Paris"}`, true},
+		{`  {"city": "Paris"}  `, true},
+		{`{"text": "a } b"`, false},
+		{`{"text": "a } b"}`, true},
+		{`{"text": "quote \" }"`, false},
+		{`{"text": "quote \" }"}`, true},
+		{`{"nested": {"list": [1, {"a": "]"}]}}`, true},
+		{`{"nested": {"list": [1, 2]}`, false},
+		{`[1, 2]`, true},
+		{`{}`, true},
+		{`{"a":}`, false},
+		{`{"a":1}}`, false},
+		{`{"a":1}{`, false},
+		{`"string"`, false},
+		{`123`, false},
+	}
+
+	for _, c := range cases {
+		assert.Equal(t, c.complete, IsCompleteJSON(c.input), c.input)
+	}
+}
+
+func TestToolCallAccumulator(t *testing.T) {
+	idx0, idx1 := 0, 1
+	acc := NewToolCallAccumulator()
+
+	completed, err := acc.Add(
+		ToolCall{Index: &idx0, ID: "call_0", Type: "function", Function: FunctionCall{Name: "get_weather", Arguments: `{"city":`}},
+		ToolCall{Index: &idx1, ID: "call_1", Type: "function", Function: FunctionCall{Name: "get_time"}},
+	)
+	assert.NoError(t, err)
+	assert.Empty(t, completed)
+
+	completed, err = acc.Add(
+		ToolCall{Index: &idx0, Function: FunctionCall{Arguments: ` "{Paris}"`}},
+		ToolCall{Index: &idx1, Function: FunctionCall{Arguments: `{"tz": "UTC"}`}},
+	)
+	assert.NoError(t, err)
+	assert.Len(t, completed, 1)
+	assert.Equal(t, "call_1", completed[0].ID)
+	assert.Equal(t, "get_time", completed[0].Function.Name)
+	assert.Equal(t, `{"tz": "UTC"}`, completed[0].Function.Arguments)
+
+	completed, err = acc.Add(ToolCall{Index: &idx0, Function: FunctionCall{Arguments: `}`}})
+	assert.NoError(t, err)
+	assert.Len(t, completed, 1)
+	assert.Equal(t, "call_0", completed[0].ID)
+	assert.Equal(t, `{"city": "{Paris}"}`, completed[0].Function.Arguments)
+
+	completed, err = acc.Add(ToolCall{Index: &idx0, Function: FunctionCall{Arguments: ` `}})
+	assert.NoError(t, err)
+	assert.Empty(t, completed)
+
+	completed, err = acc.Add(ToolCall{ID: "call_2", Function: FunctionCall{Name: "noop", Arguments: `{}`}})
+	assert.NoError(t, err)
+	assert.Len(t, completed, 1)
+
+	idx2 := 2
+	_, err = acc.Add(
+		ToolCall{Index: &idx2, ID: "call_a", Function: FunctionCall{Arguments: `{`}},
+		ToolCall{Index: &idx2, ID: "call_b", Function: FunctionCall{Arguments: `}`}},
+	)
+	assert.Error(t, err)
+}
+
+func TestToolCallAccumulatorFinish(t *testing.T) {
+	idx0, idx1, idx2 := 0, 1, 2
+	acc := NewToolCallAccumulator()
+
+	completed, err := acc.Add(
+		ToolCall{Index: &idx1, ID: "call_1", Type: "function", Function: FunctionCall{Name: "now"}},
+		ToolCall{Index: &idx0, ID: "call_0", Type: "function", Function: FunctionCall{Name: "get_weather", Arguments: `{"city":`}},
+		ToolCall{Index: &idx2, Function: FunctionCall{Arguments: `{`}},
+		ToolCall{ID: "call_3", Function: FunctionCall{Name: "ping"}},
+	)
+	assert.NoError(t, err)
+	if assert.Len(t, completed, 1) {
+		assert.Equal(t, "call_3", completed[0].ID)
+	}
+
+	completed, err = acc.Finish()
+	assert.NoError(t, err)
+	if assert.Len(t, completed, 2) {
+		assert.Equal(t, "call_0", completed[0].ID)
+		assert.Equal(t, `{"city":`, completed[0].Function.Arguments)
+		assert.Equal(t, "call_1", completed[1].ID)
+		assert.Equal(t, "", completed[1].Function.Arguments)
+	}
+
+	// the tool calls are returned only once
+	completed, err = acc.Add(ToolCall{Index: &idx1, Function: FunctionCall{Arguments: `{}`}})
+	assert.NoError(t, err)
+	assert.Empty(t, completed)
+	completed, err = acc.Finish()
+	assert.NoError(t, err)
+	assert.Empty(t, completed)
+}
+
+func TestWatchToolCalls(t *testing.T) {
+	idx := func(i int) *int { return &i }
+	chunks := []*Message{
+		{Role: Assistant, Content: "let me check"},
+		{Role: Assistant, ToolCalls: []ToolCall{{Index: idx(0), ID: "call_1", Function: FunctionCall{Name: "weather", Arguments: `{"city":`}}}},
+		{Role: Assistant, ToolCalls: []ToolCall{{Index: idx(1), ID: "call_2", Function: FunctionCall{Name: "time", Arguments: `{"tz":`}}}},
+		{Role: Assistant, ToolCalls: []ToolCall{{Index: idx(0), Function: FunctionCall{Arguments: `"Paris"}`}}}},
+		{Role: Assistant, ToolCalls: []ToolCall{{Index: idx(2), ID: "call_3", Function: FunctionCall{Name: "now"}}}},
+		{Role: Assistant, ToolCalls: []ToolCall{{Index: idx(1), Function: FunctionCall{Arguments: `"UTC"}`}}}},
+	}
+
+	var events []string
+	sr := WatchToolCalls(StreamReaderFromArray(chunks), func(index int, name string) {
+		events = append(events, fmt.Sprintf("start %d %s", index, name))
+	}, func(index int, args string) {
+		events = append(events, fmt.Sprintf("complete %d %s", index, args))
+	})
+	defer sr.Close()
+
+	var received []*Message
+	for {
+		chunk, err := sr.Recv()
+		if err == io.EOF {
+			break
+		}
+		assert.NoError(t, err)
+		received = append(received, chunk)
+	}
+
+	assert.Equal(t, chunks, received)
+	assert.Equal(t, []string{
+		"start 0 weather",
+		"start 1 time",
+		`complete 0 {"city":"Paris"}`,
+		"start 2 now",
+		`complete 1 {"tz":"UTC"}`,
+		"complete 2 ",
+	}, events)
+
+	t.Run("error", func(t *testing.T) {
+		origin, sw := Pipe[*Message](1)
+		sw.Send(nil, errors.New("broken"))
+		sw.Close()
+
+		sr := WatchToolCalls(origin, nil, nil)
+		defer sr.Close()
+		_, err := sr.Recv()
+		assert.EqualError(t, err, "broken")
+		_, err = sr.Recv()
+		assert.Equal(t, io.EOF, err)
+	})
+}
@@ -23,6 +23,7 @@ import (
 	"github.com/eino-contrib/jsonschema"
 	"github.com/smartystreets/goconvey/convey"
 	"github.com/stretchr/testify/assert"
+	orderedmap "github.com/wk8/go-ordered-map/v2"
 )
 
 func TestParamsOneOfToJSONSchema(t *testing.T) {
@@ -133,3 +134,105 @@ func TestParamsOneOfToJSONSchema(t *testing.T) {
 
 	})
 }
+
+func TestToolInfoHash(t *testing.T) {
+	newInfo := func() *ToolInfo {
+		return &ToolInfo{
+			Name:  "search",
+			Desc:  "search the web",
+			Extra: map[string]any{"b": 1, "a": "x"},
+			ParamsOneOf: NewParamsOneOfByParams(map[string]*ParameterInfo{
+				"query": {Type: String, Required: true},
+				"limit": {Type: Integer},
+			}),
+		}
+	}
+
+	h1, err := newInfo().Hash()
+	assert.NoError(t, err)
+	h2, err := newInfo().Hash()
+	assert.NoError(t, err)
+	assert.Equal(t, h1, h2)
+	assert.Len(t, h1, 64)
+
+	changed := newInfo()
+	changed.Desc = "search the internet"
+	h3, err := changed.Hash()
+	assert.NoError(t, err)
+	assert.NotEqual(t, h1, h3)
+
+	// property order of a json schema doesn't affect the hash
+	newSchemaInfo := func(keys ...string) *ToolInfo {
+		sc := &jsonschema.Schema{Type: string(Object), Properties: orderedmap.New[string, *jsonschema.Schema]()}
+		for _, k := range keys {
+			sc.Properties.Set(k, &jsonschema.Schema{Type: string(String)})
+		}
+		return &ToolInfo{Name: "search", ParamsOneOf: NewParamsOneOfByJSONSchema(sc)}
+	}
+	h4, err := newSchemaInfo("a", "b").Hash()
+	assert.NoError(t, err)
+	h5, err := newSchemaInfo("b", "a").Hash()
+	assert.NoError(t, err)
+	assert.Equal(t, h4, h5)
+
+	_, err = (*ToolInfo)(nil).Hash()
+	assert.Error(t, err)
+}
+
+func TestParameterInfoArrayConstraints(t *testing.T) {
+	minItems, maxItems := uint64(1), uint64(5)
+	params := NewParamsOneOfByParams(map[string]*ParameterInfo{
+		"tags": {
+			Type:        Array,
+			ElemInfo:    &ParameterInfo{Type: String},
+			MinItems:    &minItems,
+			MaxItems:    &maxItems,
+			UniqueItems: true,
+		},
+		"ids": {
+			Type:     Array,
+			ElemInfo: &ParameterInfo{Type: Integer},
+		},
+	})
+
+	js, err := params.ToJSONSchema()
+	assert.NoError(t, err)
+
+	tags, ok := js.Properties.Get("tags")
+	assert.True(t, ok)
+	data, err := json.Marshal(tags)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"type":"array","items":{"type":"string"},"minItems":1,"maxItems":5,"uniqueItems":true}`, string(data))
+
+	ids, ok := js.Properties.Get("ids")
+	assert.True(t, ok)
+	data, err = json.Marshal(ids)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"type":"array","items":{"type":"integer"}}`, string(data))
+}
+
+func TestParameterInfoFormat(t *testing.T) {
+	params := NewParamsOneOfByParams(map[string]*ParameterInfo{
+		"start": {Type: String, Desc: "the start time", Format: ParamFormatDateTime, Required: true},
+		"ids": {
+			Type:     Array,
+			ElemInfo: &ParameterInfo{Type: String, Format: ParamFormatUUID},
+		},
+		"name": {Type: String},
+	})
+
+	js, err := params.ToJSONSchema()
+	assert.NoError(t, err)
+
+	data, err := json.Marshal(js)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{
+		"type": "object",
+		"properties": {
+			"ids": {"type": "array", "items": {"type": "string", "format": "uuid"}},
+			"name": {"type": "string"},
+			"start": {"type": "string", "description": "the start time", "format": "date-time"}
+		},
+		"required": ["start"]
+	}`, string(data))
+}